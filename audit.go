@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tylerztl/fabric-mempool/handler"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check the audit log of the fee allocations and admin changes",
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the hash chain and the signatures of the audit log",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return VerifyAudit()
+	},
+}
+
+// VerifyAudit checks the audit log against the certificate of the user of
+// the mempool, both taken from app.yaml unless given by flags.
+func VerifyAudit() error {
+	file, cert := AuditFile, AuditCert
+	if file == "" {
+		var err error
+		if file, err = handler.AppConf.Mempool.StatePath(handler.AppConf.Audit.File); err != nil {
+			return err
+		}
+	}
+	if cert == "" {
+		cert = handler.AppConf.User.SignCert
+	}
+
+	certPEM, err := ioutil.ReadFile(cert)
+	if err != nil {
+		return errors.WithMessage(err, "could not read signer certificate")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	count, head, err := handler.VerifyAuditLog(f, certPEM)
+	if err != nil {
+		return errors.WithMessagef(err, "audit log %s is not valid", file)
+	}
+	// entries removed from the tail are only detected against the head
+	// recorded by a previous run
+	fmt.Printf("audit log %s is valid, %d entries, head %s\n", file, count, head)
+	return nil
+}
@@ -0,0 +1,78 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// AccountabilityInfo enables checking the txs fetched by every orderer are
+// committed in blocks, and sanctioning the orderers below the inclusion
+// ratio.
+type AccountabilityInfo struct {
+	// Deadline is how long a fetched tx has to be committed before it is
+	// missed by its orderer.
+	Deadline time.Duration `yaml:"deadline"`
+	// Interval is the period the inclusion ratios are checked on.
+	Interval time.Duration `yaml:"interval"`
+	// MinRatio is the ratio of the txs an orderer must get committed in an
+	// interval. (MEMPOOL_ACCOUNTABILITY_MIN_RATIO)
+	MinRatio float64 `yaml:"minRatio"`
+	// MinTxs is the number of txs committed or missed in an interval below
+	// which an orderer is not judged.
+	MinTxs int `yaml:"minTxs"`
+	// Clawback takes back the rewards of the txs missed by an orderer below
+	// the ratio.
+	Clawback bool `yaml:"clawback"`
+	// ReduceCapacity scales the capacity of an orderer below the ratio down
+	// to its ratio.
+	ReduceCapacity bool `yaml:"reduceCapacity"`
+}
+
+// DefaultAccountabilityInfo returns the settings completing a partial
+// accountability section.
+func DefaultAccountabilityInfo() *AccountabilityInfo {
+	return &AccountabilityInfo{
+		Deadline: 2 * time.Minute,
+		Interval: time.Minute,
+		MinRatio: 0.9,
+		MinTxs:   10,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (a *AccountabilityInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*a = *DefaultAccountabilityInfo()
+	type plain AccountabilityInfo
+	return unmarshal((*plain)(a))
+}
+
+// Validate checks the settings are consistent, channels are the channels
+// whose commits are followed.
+func (a *AccountabilityInfo) Validate(channels []string) error {
+	if a.Deadline <= 0 || a.Interval <= 0 {
+		return fmt.Errorf("deadline and interval must be positive, got %s and %s", a.Deadline, a.Interval)
+	}
+	if a.MinRatio < 0 || a.MinRatio > 1 {
+		return fmt.Errorf("minRatio must be in [0, 1], got %v", a.MinRatio)
+	}
+	if a.MinTxs < 1 {
+		return fmt.Errorf("minTxs must be positive, got %d", a.MinTxs)
+	}
+	if len(channels) == 0 {
+		return fmt.Errorf("channels can't be empty, no commit would be seen")
+	}
+	return nil
+}
+
+func (a *AccountabilityInfo) loadEnv() error {
+	if v := os.Getenv("MEMPOOL_ACCOUNTABILITY_MIN_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_ACCOUNTABILITY_MIN_RATIO: %s", err)
+		}
+		a.MinRatio = ratio
+	}
+	return nil
+}
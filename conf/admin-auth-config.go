@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// AdminAuthInfo requires the requests changing the orderers or the reward
+// epochs to be signed by an admin. The signer must be a member of the admin
+// MSP, and the common name of its certificate one of Admins.
+type AdminAuthInfo struct {
+	// MSPID is the MSP of the admins. (MEMPOOL_ADMIN_AUTH_MSPID)
+	MSPID string `yaml:"mspid"`
+	// CACerts are the root certificates of the admin MSP, they default to
+	// the ones of the crypto-config directory.
+	CACerts []string `yaml:"cacerts"`
+	// Admins are the common names of the admin certificates.
+	// (MEMPOOL_ADMIN_AUTH_ADMINS, comma separated)
+	Admins []string `yaml:"admins"`
+	// TimeWindow is how far the timestamp of an admin request may be off
+	// the local clock.
+	TimeWindow time.Duration `yaml:"timeWindow"`
+}
+
+// DefaultAdminAuthInfo returns the settings used when app.yaml has no
+// adminAuth section, or completing a partial one.
+func DefaultAdminAuthInfo() *AdminAuthInfo {
+	return &AdminAuthInfo{
+		MSPID:      "Org1MSP",
+		Admins:     []string{"Admin@org1.example.com"},
+		TimeWindow: time.Minute,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (a *AdminAuthInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*a = *DefaultAdminAuthInfo()
+	type plain AdminAuthInfo
+	return unmarshal((*plain)(a))
+}
+
+// Validate checks the settings are consistent.
+func (a *AdminAuthInfo) Validate() error {
+	if a.MSPID == "" {
+		return fmt.Errorf("mspid can't be empty")
+	}
+	if len(a.Admins) == 0 {
+		return fmt.Errorf("admins can't be empty")
+	}
+	if a.TimeWindow <= 0 {
+		return fmt.Errorf("timeWindow must be positive, got %s", a.TimeWindow)
+	}
+	return nil
+}
+
+func (a *AdminAuthInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_ADMIN_AUTH_MSPID"); v != "" {
+		a.MSPID = v
+	}
+	if v := os.Getenv("MEMPOOL_ADMIN_AUTH_ADMINS"); v != "" {
+		a.Admins = strings.Split(v, ",")
+	}
+}
@@ -25,6 +25,24 @@ type Application struct {
 	ReqTimeout int64          `yaml:"reqTimeout"`
 	Peer       *PeerInfo      `yaml:"peer"`
 	User       *UserInfo      `yaml:"user"`
+	Channels   []string       `yaml:"channels"`
+	Resubmit   *ResubmitInfo  `yaml:"resubmit"`
+	Mempool    *MempoolInfo   `yaml:"mempool"`
+	P2P        *P2PInfo       `yaml:"p2p"`
+	Raft       *RaftInfo      `yaml:"raft"`
+	Router     *RouterInfo    `yaml:"router"`
+	FetchAuth  *FetchAuthInfo `yaml:"fetchAuth"`
+	AdminAuth  *AdminAuthInfo `yaml:"adminAuth"`
+	Capacity   *CapacityInfo  `yaml:"capacity"`
+	Broadcast  *BroadcastInfo `yaml:"broadcast"`
+	// Distribution is the initial distribution strategy, the -d flag
+	// applies if it is missing.
+	Distribution *DistributeConfig `yaml:"distribution"`
+	Rewards      *RewardsInfo      `yaml:"rewards"`
+	Settlement   *SettlementInfo   `yaml:"settlement"`
+	Audit        *AuditInfo        `yaml:"audit"`
+	// Accountability checks the orderers get the txs they fetch committed.
+	Accountability *AccountabilityInfo `yaml:"accountability"`
 }
 
 type PeerInfo struct {
@@ -38,10 +56,24 @@ type UserInfo struct {
 	SignCert   string `yaml:"sign_cert"`
 }
 
+// ResubmitInfo controls how transactions invalidated at commit are handled.
+// Only transactions created by the mempool's own Invoke path can be
+// re-endorsed automatically, others are just reported to the submitter.
+type ResubmitInfo struct {
+	Auto       bool `yaml:"auto"`
+	MaxRetries int  `yaml:"maxRetries"`
+}
+
 type OrdererInfo struct {
 	Name string `yaml:"name"`
 	Host string `yaml:"host"`
 	Port uint16 `yaml:"port"`
+	// TLSCACert is the TLS root certificate of the orderer, it defaults to
+	// the one of the crypto-config directory when tlsEnabled is set.
+	TLSCACert string `yaml:"tls_ca_cert"`
+	// Identity is the common name of the certificate the orderer signs its
+	// fetch requests with, it defaults to Host.
+	Identity string `yaml:"identity"`
 }
 
 var appConfig = new(AppConf)
@@ -59,6 +91,134 @@ func init() {
 	if err = yaml.Unmarshal(yamlFile, appConfig); err != nil {
 		panic(fmt.Errorf("yamlFile.Unmarshal err[%s]", err))
 	}
+
+	if appConfig.Conf.Mempool == nil {
+		appConfig.Conf.Mempool = DefaultMempoolInfo()
+	}
+	if err = appConfig.Conf.Mempool.loadEnv(); err != nil {
+		panic(fmt.Errorf("mempool env err[%s]", err))
+	}
+	if err = appConfig.Conf.Mempool.Validate(); err != nil {
+		panic(fmt.Errorf("mempool config err[%s]", err))
+	}
+
+	if appConfig.Conf.P2P == nil && os.Getenv("MEMPOOL_P2P_LADDR") != "" {
+		appConfig.Conf.P2P = DefaultP2PInfo()
+	}
+	if appConfig.Conf.P2P != nil {
+		appConfig.Conf.P2P.loadEnv()
+		if err = appConfig.Conf.P2P.Validate(); err != nil {
+			panic(fmt.Errorf("p2p config err[%s]", err))
+		}
+	}
+
+	if appConfig.Conf.Raft != nil {
+		if err = appConfig.Conf.Raft.loadEnv(); err != nil {
+			panic(fmt.Errorf("raft env err[%s]", err))
+		}
+		if err = appConfig.Conf.Raft.Validate(); err != nil {
+			panic(fmt.Errorf("raft config err[%s]", err))
+		}
+		if appConfig.Conf.P2P != nil {
+			panic(fmt.Errorf("raft config err[p2p gossip and raft replication can't be enabled together]"))
+		}
+	}
+
+	if appConfig.Conf.Router == nil && os.Getenv("MEMPOOL_ROUTER_SHARDS") != "" {
+		appConfig.Conf.Router = DefaultRouterInfo()
+	}
+	if appConfig.Conf.Router != nil {
+		appConfig.Conf.Router.loadEnv()
+		if err = appConfig.Conf.Router.Validate(); err != nil {
+			panic(fmt.Errorf("router config err[%s]", err))
+		}
+	}
+
+	if appConfig.Conf.FetchAuth == nil && os.Getenv("MEMPOOL_FETCH_AUTH_MSPID") != "" {
+		appConfig.Conf.FetchAuth = DefaultFetchAuthInfo()
+	}
+	if appConfig.Conf.FetchAuth != nil {
+		appConfig.Conf.FetchAuth.loadEnv()
+		if err = appConfig.Conf.FetchAuth.Validate(); err != nil {
+			panic(fmt.Errorf("fetchAuth config err[%s]", err))
+		}
+	}
+
+	if appConfig.Conf.AdminAuth == nil {
+		appConfig.Conf.AdminAuth = DefaultAdminAuthInfo()
+	}
+	appConfig.Conf.AdminAuth.loadEnv()
+	if err = appConfig.Conf.AdminAuth.Validate(); err != nil {
+		panic(fmt.Errorf("adminAuth config err[%s]", err))
+	}
+
+	if appConfig.Conf.Capacity != nil {
+		if err = appConfig.Conf.Capacity.loadEnv(); err != nil {
+			panic(fmt.Errorf("capacity env err[%s]", err))
+		}
+		if err = appConfig.Conf.Capacity.Validate(); err != nil {
+			panic(fmt.Errorf("capacity config err[%s]", err))
+		}
+	}
+
+	if appConfig.Conf.Broadcast == nil {
+		appConfig.Conf.Broadcast = DefaultBroadcastInfo()
+	}
+	if err = appConfig.Conf.Broadcast.loadEnv(); err != nil {
+		panic(fmt.Errorf("broadcast env err[%s]", err))
+	}
+	if err = appConfig.Conf.Broadcast.Validate(); err != nil {
+		panic(fmt.Errorf("broadcast config err[%s]", err))
+	}
+
+	if appConfig.Conf.Distribution != nil {
+		if err = appConfig.Conf.Distribution.Validate(); err != nil {
+			panic(fmt.Errorf("distribution config err[%s]", err))
+		}
+	}
+
+	if appConfig.Conf.Rewards == nil {
+		appConfig.Conf.Rewards = DefaultRewardsInfo()
+	}
+	appConfig.Conf.Rewards.loadEnv()
+	if err = appConfig.Conf.Rewards.Validate(); err != nil {
+		panic(fmt.Errorf("rewards config err[%s]", err))
+	}
+
+	if appConfig.Conf.Settlement == nil && os.Getenv("MEMPOOL_SETTLEMENT_CHANNEL") != "" {
+		appConfig.Conf.Settlement = DefaultSettlementInfo()
+	}
+	if appConfig.Conf.Settlement != nil {
+		appConfig.Conf.Settlement.loadEnv()
+		if err = appConfig.Conf.Settlement.Validate(appConfig.Conf.Channels); err != nil {
+			panic(fmt.Errorf("settlement config err[%s]", err))
+		}
+		// every replica credits the txs fetched through it to its own
+		// reward ledger, so each would settle a part of the rewards
+		if appConfig.Conf.Raft != nil {
+			panic(fmt.Errorf("settlement config err[settlement and raft replication can't be enabled together]"))
+		}
+	}
+
+	if appConfig.Conf.Audit == nil {
+		appConfig.Conf.Audit = DefaultAuditInfo()
+	}
+	appConfig.Conf.Audit.loadEnv()
+	if err = appConfig.Conf.Audit.Validate(); err != nil {
+		panic(fmt.Errorf("audit config err[%s]", err))
+	}
+
+	if appConfig.Conf.Accountability == nil && os.Getenv("MEMPOOL_ACCOUNTABILITY_MIN_RATIO") != "" {
+		appConfig.Conf.Accountability = DefaultAccountabilityInfo()
+	}
+	if appConfig.Conf.Accountability != nil {
+		if err = appConfig.Conf.Accountability.loadEnv(); err != nil {
+			panic(fmt.Errorf("accountability env err[%s]", err))
+		}
+		if err = appConfig.Conf.Accountability.Validate(appConfig.Conf.Channels); err != nil {
+			panic(fmt.Errorf("accountability config err[%s]", err))
+		}
+	}
 }
 
 func GetAppConf() *AppConf {
@@ -0,0 +1,44 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+)
+
+// AuditInfo configures the audit log of the fee allocations and the admin
+// changes.
+type AuditInfo struct {
+	// File is the audit log, relative to the mempool rootDir unless
+	// absolute, or to ~/.fabric-mempool without rootDir.
+	// (MEMPOOL_AUDIT_FILE)
+	File string `yaml:"file"`
+}
+
+// DefaultAuditInfo returns the settings used when app.yaml has no audit
+// section, or completing a partial one.
+func DefaultAuditInfo() *AuditInfo {
+	return &AuditInfo{
+		File: "audit.log",
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (a *AuditInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*a = *DefaultAuditInfo()
+	type plain AuditInfo
+	return unmarshal((*plain)(a))
+}
+
+// Validate checks the settings are consistent.
+func (a *AuditInfo) Validate() error {
+	if a.File == "" {
+		return fmt.Errorf("file can't be empty")
+	}
+	return nil
+}
+
+func (a *AuditInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_AUDIT_FILE"); v != "" {
+		a.File = v
+	}
+}
@@ -0,0 +1,62 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// BroadcastInfo tunes the broadcast of the fetched txs to the orderers.
+type BroadcastInfo struct {
+	// Streams is the number of broadcast streams opened to every orderer.
+	// (MEMPOOL_BROADCAST_STREAMS)
+	Streams int `yaml:"streams"`
+	// Window is the number of txs sent on a stream without waiting for their
+	// acknowledgement. (MEMPOOL_BROADCAST_WINDOW)
+	Window int `yaml:"window"`
+}
+
+// DefaultBroadcastInfo returns the settings used when app.yaml has no
+// broadcast section, or completing a partial one.
+func DefaultBroadcastInfo() *BroadcastInfo {
+	return &BroadcastInfo{
+		Streams: 2,
+		Window:  32,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (b *BroadcastInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*b = *DefaultBroadcastInfo()
+	type plain BroadcastInfo
+	return unmarshal((*plain)(b))
+}
+
+// Validate checks the settings are consistent.
+func (b *BroadcastInfo) Validate() error {
+	if b.Streams <= 0 {
+		return fmt.Errorf("streams must be positive, got %d", b.Streams)
+	}
+	if b.Window <= 0 {
+		return fmt.Errorf("window must be positive, got %d", b.Window)
+	}
+	return nil
+}
+
+func (b *BroadcastInfo) loadEnv() error {
+	if v := os.Getenv("MEMPOOL_BROADCAST_STREAMS"); v != "" {
+		streams, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_BROADCAST_STREAMS: %s", err)
+		}
+		b.Streams = streams
+	}
+	if v := os.Getenv("MEMPOOL_BROADCAST_WINDOW"); v != "" {
+		window, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_BROADCAST_WINDOW: %s", err)
+		}
+		b.Window = window
+	}
+	return nil
+}
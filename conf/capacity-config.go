@@ -0,0 +1,70 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// CapacityInfo enables the adaptive capacity controller, which adjusts the
+// number of txs every orderer fetches at once from its broadcast
+// acknowledgement latency, fetch frequency, failures and block height
+// progress.
+type CapacityInfo struct {
+	// MinCapacity is the lowest capacity of an orderer. (MEMPOOL_CAPACITY_MIN)
+	MinCapacity int `yaml:"minCapacity"`
+	// MaxCapacity is the highest capacity of an orderer. (MEMPOOL_CAPACITY_MAX)
+	MaxCapacity int `yaml:"maxCapacity"`
+	// Interval is the period of the observations every decision is made from.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DefaultCapacityInfo returns the settings completing a partial capacity
+// section.
+func DefaultCapacityInfo() *CapacityInfo {
+	return &CapacityInfo{
+		MinCapacity: 1,
+		MaxCapacity: 1000,
+		Interval:    10 * time.Second,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (c *CapacityInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*c = *DefaultCapacityInfo()
+	type plain CapacityInfo
+	return unmarshal((*plain)(c))
+}
+
+// Validate checks the settings are consistent.
+func (c *CapacityInfo) Validate() error {
+	if c.MinCapacity <= 0 {
+		return fmt.Errorf("minCapacity must be positive, got %d", c.MinCapacity)
+	}
+	if c.MaxCapacity < c.MinCapacity {
+		return fmt.Errorf("maxCapacity %d is below minCapacity %d", c.MaxCapacity, c.MinCapacity)
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", c.Interval)
+	}
+	return nil
+}
+
+func (c *CapacityInfo) loadEnv() error {
+	if v := os.Getenv("MEMPOOL_CAPACITY_MIN"); v != "" {
+		min, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_CAPACITY_MIN: %s", err)
+		}
+		c.MinCapacity = min
+	}
+	if v := os.Getenv("MEMPOOL_CAPACITY_MAX"); v != "" {
+		max, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_CAPACITY_MAX: %s", err)
+		}
+		c.MaxCapacity = max
+	}
+	return nil
+}
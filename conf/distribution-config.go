@@ -1,17 +1,75 @@
 package conf
 
+import "fmt"
+
+// Names of the built-in distribution strategies.
+const (
+	// DistributeByEffort feeds all tax to the orderer which fetched the txs.
+	DistributeByEffort = "effort"
+	// DistributeEqually averages the tax over all orderers.
+	DistributeEqually = "equal"
+	// DistributeByCapacity shares the tax in proportion to the capacities.
+	DistributeByCapacity = "capacity"
+	// DistributeByUptime shares the tax in proportion to the time since the
+	// orderers joined.
+	DistributeByUptime = "uptime"
+	// DistributeByStake shares the tax in proportion to the Stakes table.
+	DistributeByStake = "stake"
+)
+
 type DistributeConfig struct {
 	// DistributionType used to tag witch method to distribute tax,0 means all tax feed to orderer which deal the order
 	// 1 means tax average to all orderer
-	DistributionType int `json:"allocation_rule"`
+	DistributionType int `json:"allocation_rule" yaml:"allocationRule"`
+	// Strategy is the name of the distribution strategy, it takes
+	// precedence over DistributionType when set.
+	Strategy string `json:"strategy,omitempty" yaml:"strategy"`
+	// Stakes are the weights of the orderers by name for the stake strategy,
+	// orderers missing from it get nothing.
+	Stakes map[string]int64 `json:"stakes,omitempty" yaml:"stakes"`
+}
+
+// StrategyName returns the name of the distribution strategy in use.
+func (d *DistributeConfig) StrategyName() string {
+	if d.Strategy != "" {
+		return d.Strategy
+	}
+	if d.DistributionType == 1 {
+		return DistributeEqually
+	}
+	return DistributeByEffort
 }
 
 func (d *DistributeConfig) String() string {
-	if d.DistributionType == 0 {
+	switch name := d.StrategyName(); name {
+	case DistributeByEffort:
 		return "effort-based allocation"
-	} else {
+	case DistributeEqually:
 		return "equal allocation"
+	case DistributeByCapacity:
+		return "allocation by capacity"
+	case DistributeByUptime:
+		return "allocation by uptime"
+	case DistributeByStake:
+		return "stake-weighted allocation"
+	default:
+		return name + " allocation"
+	}
+}
+
+// Validate checks the settings are consistent.
+func (d *DistributeConfig) Validate() error {
+	total := int64(0)
+	for orderer, stake := range d.Stakes {
+		if stake < 0 {
+			return fmt.Errorf("stake of orderer %s can't be negative, got %d", orderer, stake)
+		}
+		total += stake
 	}
+	if d.StrategyName() == DistributeByStake && total == 0 {
+		return fmt.Errorf("stakes can't be empty with the %s strategy", DistributeByStake)
+	}
+	return nil
 }
 
 type SortConfig struct {
@@ -26,15 +84,61 @@ func (d *SortConfig) String() string {
 	}
 }
 
+// OrdererCapacityConfig sets the capacity of an orderer by hand. The
+// capacity is pinned: the adaptive capacity controller leaves it alone until
+// Unpin hands it back, Capacity is ignored then.
 type OrdererCapacityConfig struct {
 	Orderer  string `json:"orderer"`
 	Capacity int    `json:"capacity"`
+	Unpin    bool   `json:"unpin,omitempty"`
 }
 
 type Feedback struct {
-	Orderer   string `json:"orderer"`
-	Capacity  int    `json:"capacity"`
-	FeeReward string `json:"fee_reward"`
+	Orderer  string `json:"orderer"`
+	State    string `json:"state"`
+	Capacity int    `json:"capacity"`
+	// CapacityPinned tells the capacity is set by hand.
+	CapacityPinned bool   `json:"capacity_pinned,omitempty"`
+	FeeReward      string `json:"fee_reward"`
+	// Health is the connectivity state of the connection to the orderer.
+	Health     string `json:"health"`
+	Reconnects int    `json:"reconnects"`
+	LastError  string `json:"last_error,omitempty"`
+	// CapacityDecision is the last decision of the adaptive capacity
+	// controller, if enabled.
+	CapacityDecision *CapacityDecision `json:"capacity_decision,omitempty"`
+	// Inclusion is the accountability of the orderer for the txs it
+	// fetched, if enabled.
+	Inclusion *Inclusion `json:"inclusion,omitempty"`
+}
+
+// Inclusion counts the txs fetched by an orderer which are committed in
+// blocks or missed.
+type Inclusion struct {
+	Pending  int     `json:"pending"`
+	Included int     `json:"included"`
+	Missed   int     `json:"missed"`
+	Ratio    float64 `json:"ratio"` // included over included and missed
+	// ClawedBack is the reward taken back for the txs missed.
+	ClawedBack string `json:"clawed_back"`
+	Sanctions  int    `json:"sanctions"`
+}
+
+// CapacityDecision is an adjustment of the capacity of an orderer, with the
+// observations it was made from.
+type CapacityDecision struct {
+	Time   int64  `json:"time"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Reason string `json:"reason"`
+
+	Fetches     int    `json:"fetches"`
+	FullFetches int    `json:"full_fetches"`
+	Acks        int    `json:"acks"`
+	AckLatency  string `json:"ack_latency"`
+	BatchTime   string `json:"batch_time"`
+	Failures    int    `json:"failures"`
+	BlockHeight uint64 `json:"block_height"`
 }
 
 type OrdererFeedback struct {
@@ -44,3 +148,9 @@ type OrdererFeedback struct {
 type TransactionFee struct {
 	Fee int `json:"fee"`
 }
+
+type MinFeeFeedback struct {
+	MinFee   string `json:"min_fee"`
+	Size     int    `json:"size"`
+	TxsBytes int64  `json:"txs_bytes"`
+}
@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// FetchAuthInfo requires the orderers to sign their fetch requests. The
+// signer must be a member of the orderer MSP, and the common name of its
+// certificate must be the identity of the orderer it fetches for.
+type FetchAuthInfo struct {
+	// MSPID is the MSP of the orderers. (MEMPOOL_FETCH_AUTH_MSPID)
+	MSPID string `yaml:"mspid"`
+	// CACerts are the root certificates of the orderer MSP, they default to
+	// the ones of the crypto-config directory.
+	CACerts []string `yaml:"cacerts"`
+	// TimeWindow is how far the timestamp of a fetch request may be off the
+	// local clock.
+	TimeWindow time.Duration `yaml:"timeWindow"`
+}
+
+// DefaultFetchAuthInfo returns the settings completing a partial fetchAuth
+// section.
+func DefaultFetchAuthInfo() *FetchAuthInfo {
+	return &FetchAuthInfo{
+		MSPID:      "OrdererMSP",
+		TimeWindow: time.Minute,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (f *FetchAuthInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*f = *DefaultFetchAuthInfo()
+	type plain FetchAuthInfo
+	return unmarshal((*plain)(f))
+}
+
+// Validate checks the settings are consistent.
+func (f *FetchAuthInfo) Validate() error {
+	if f.MSPID == "" {
+		return fmt.Errorf("mspid can't be empty")
+	}
+	if f.TimeWindow <= 0 {
+		return fmt.Errorf("timeWindow must be positive, got %s", f.TimeWindow)
+	}
+	return nil
+}
+
+func (f *FetchAuthInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_FETCH_AUTH_MSPID"); v != "" {
+		f.MSPID = v
+	}
+}
@@ -0,0 +1,277 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// MempoolInfo holds the tunables of the transaction pool.
+// Every field can be overridden by the MEMPOOL_* environment variable
+// named next to it.
+type MempoolInfo struct {
+	// RootDir is the data directory of the mempool, a temp dir is created
+	// if it is empty. (MEMPOOL_DATA)
+	RootDir string `yaml:"rootDir"`
+	// WalDir is the WAL directory, relative to RootDir unless absolute.
+	// The WAL is disabled if it is empty. (MEMPOOL_WAL_DIR)
+	WalDir string `yaml:"walDir"`
+	// Size is the maximum number of txs in the mempool. (MEMPOOL_SIZE)
+	Size int `yaml:"size"`
+	// MaxTxsBytes limits the total size of all txs in the mempool. (MEMPOOL_MAX_TXS_BYTES)
+	MaxTxsBytes int64 `yaml:"maxTxsBytes"`
+	// MaxTxBytes is the maximum size of a single tx. (MEMPOOL_MAX_TX_BYTES)
+	MaxTxBytes int `yaml:"maxTxBytes"`
+	// CacheSize is the number of seen txs remembered to reject duplicates. (MEMPOOL_CACHE_SIZE)
+	CacheSize int `yaml:"cacheSize"`
+	// Recheck txs left in the mempool after an update. (MEMPOOL_RECHECK)
+	Recheck bool `yaml:"recheck"`
+	// BundleTTL is how long a bundle stays in the mempool before it expires
+	// when the submitter doesn't ask otherwise, zero means forever. (MEMPOOL_BUNDLE_TTL)
+	BundleTTL time.Duration `yaml:"bundleTTL"`
+	// MaxBundleTxs is the maximum number of txs in a bundle. (MEMPOOL_MAX_BUNDLE_TXS)
+	MaxBundleTxs int `yaml:"maxBundleTxs"`
+	// TimestampLock holds txs whose channel header timestamp lies in the
+	// future until then. (MEMPOOL_TIMESTAMP_LOCK)
+	TimestampLock bool `yaml:"timestampLock"`
+	// MaxTimeLock is how far in the future a tx may be time-locked, zero
+	// means no limit. (MEMPOOL_MAX_TIME_LOCK)
+	MaxTimeLock time.Duration `yaml:"maxTimeLock"`
+	// MinFee configures the fee floor rising with the pool occupancy,
+	// there is no floor if it is nil.
+	MinFee *MinFeeInfo `yaml:"minFee"`
+	// Overflow spills the txs with the lowest fees to disk once Size or
+	// MaxTxsBytes is reached, full mempools reject txs if it is nil.
+	Overflow *OverflowInfo `yaml:"overflow"`
+}
+
+// OverflowInfo configures the on-disk tier of the mempool.
+type OverflowInfo struct {
+	// Dir is the leveldb directory, relative to RootDir unless absolute.
+	Dir string `yaml:"dir"`
+	// MaxTxs is the maximum number of txs spilled to disk.
+	MaxTxs int `yaml:"maxTxs"`
+	// MaxTxsBytes limits the total size of the txs spilled to disk.
+	MaxTxsBytes int64 `yaml:"maxTxsBytes"`
+}
+
+// DefaultOverflowInfo returns the settings completing a partial overflow section.
+func DefaultOverflowInfo() *OverflowInfo {
+	return &OverflowInfo{
+		Dir:         "overflow",
+		MaxTxs:      100000000,
+		MaxTxsBytes: 64 * 1024 * 1024 * 1024, // 64GB
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (o *OverflowInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*o = *DefaultOverflowInfo()
+	type plain OverflowInfo
+	return unmarshal((*plain)(o))
+}
+
+// Validate checks the overflow settings.
+func (o *OverflowInfo) Validate() error {
+	if o.Dir == "" {
+		return fmt.Errorf("overflow dir can't be empty")
+	}
+	if o.MaxTxs <= 0 {
+		return fmt.Errorf("overflow maxTxs must be positive, got %d", o.MaxTxs)
+	}
+	if o.MaxTxsBytes <= 0 {
+		return fmt.Errorf("overflow maxTxsBytes must be positive, got %d", o.MaxTxsBytes)
+	}
+	return nil
+}
+
+// MinFeeInfo describes the fee floor curve. The floor is Base until the
+// occupancy of the pool reaches Threshold, then it rises along Curve
+// (linear, quadratic, exponential or step) up to Max when the pool is full.
+type MinFeeInfo struct {
+	Curve     string  `yaml:"curve"`
+	Base      int64   `yaml:"base"`
+	Max       int64   `yaml:"max"`
+	Threshold float64 `yaml:"threshold"`
+}
+
+// Validate checks the fee floor settings.
+func (f *MinFeeInfo) Validate() error {
+	switch f.Curve {
+	case "linear", "quadratic", "exponential", "step":
+	default:
+		return fmt.Errorf("unknown minFee curve %q", f.Curve)
+	}
+	if f.Base < 0 || f.Max < f.Base {
+		return fmt.Errorf("invalid minFee range [%d, %d]", f.Base, f.Max)
+	}
+	if f.Threshold < 0 || f.Threshold >= 1 {
+		return fmt.Errorf("minFee threshold must be in [0, 1), got %v", f.Threshold)
+	}
+	return nil
+}
+
+// StatePath resolves the path of state which must survive restarts, such as
+// the reward ledger, relative to RootDir unless absolute. Without RootDir
+// the mempool runs in a new temp dir on every start, so the state is kept
+// under ~/.fabric-mempool instead.
+func (m *MempoolInfo) StatePath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	dir := m.RootDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("no rootDir nor home directory to keep %s in: %s", path, err)
+		}
+		dir = filepath.Join(home, ".fabric-mempool")
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// DefaultMempoolInfo returns the settings used when app.yaml has no mempool section.
+func DefaultMempoolInfo() *MempoolInfo {
+	return &MempoolInfo{
+		Size:         10000000,
+		MaxTxsBytes:  1024 * 1024 * 1024, // 1GB
+		MaxTxBytes:   1024 * 1024,        // 1MB
+		CacheSize:    1000,
+		Recheck:      true,
+		BundleTTL:    10 * time.Minute,
+		MaxBundleTxs: 16,
+		MaxTimeLock:  24 * time.Hour,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (m *MempoolInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*m = *DefaultMempoolInfo()
+	type plain MempoolInfo
+	return unmarshal((*plain)(m))
+}
+
+// Validate checks the settings are consistent.
+func (m *MempoolInfo) Validate() error {
+	if m.Size <= 0 {
+		return fmt.Errorf("size must be positive, got %d", m.Size)
+	}
+	if m.MaxTxsBytes <= 0 {
+		return fmt.Errorf("maxTxsBytes must be positive, got %d", m.MaxTxsBytes)
+	}
+	if m.MaxTxBytes <= 0 {
+		return fmt.Errorf("maxTxBytes must be positive, got %d", m.MaxTxBytes)
+	}
+	if int64(m.MaxTxBytes) > m.MaxTxsBytes {
+		return fmt.Errorf("maxTxBytes %d is greater than maxTxsBytes %d", m.MaxTxBytes, m.MaxTxsBytes)
+	}
+	if m.CacheSize < 0 {
+		return fmt.Errorf("cacheSize can't be negative, got %d", m.CacheSize)
+	}
+	if m.BundleTTL < 0 {
+		return fmt.Errorf("bundleTTL can't be negative, got %s", m.BundleTTL)
+	}
+	if m.MaxBundleTxs <= 0 {
+		return fmt.Errorf("maxBundleTxs must be positive, got %d", m.MaxBundleTxs)
+	}
+	if m.MaxTimeLock < 0 {
+		return fmt.Errorf("maxTimeLock can't be negative, got %s", m.MaxTimeLock)
+	}
+	if m.MinFee != nil {
+		if err := m.MinFee.Validate(); err != nil {
+			return err
+		}
+	}
+	if m.Overflow != nil {
+		return m.Overflow.Validate()
+	}
+	return nil
+}
+
+func (m *MempoolInfo) loadEnv() error {
+	if v := os.Getenv("MEMPOOL_DATA"); v != "" {
+		m.RootDir = v
+	}
+	if v := os.Getenv("MEMPOOL_WAL_DIR"); v != "" {
+		m.WalDir = v
+	}
+	if err := envInt("MEMPOOL_SIZE", &m.Size); err != nil {
+		return err
+	}
+	if err := envInt64("MEMPOOL_MAX_TXS_BYTES", &m.MaxTxsBytes); err != nil {
+		return err
+	}
+	if err := envInt("MEMPOOL_MAX_TX_BYTES", &m.MaxTxBytes); err != nil {
+		return err
+	}
+	if err := envInt("MEMPOOL_CACHE_SIZE", &m.CacheSize); err != nil {
+		return err
+	}
+	if err := envInt("MEMPOOL_MAX_BUNDLE_TXS", &m.MaxBundleTxs); err != nil {
+		return err
+	}
+	if err := envDuration("MEMPOOL_BUNDLE_TTL", &m.BundleTTL); err != nil {
+		return err
+	}
+	if err := envDuration("MEMPOOL_MAX_TIME_LOCK", &m.MaxTimeLock); err != nil {
+		return err
+	}
+	if err := envBool("MEMPOOL_TIMESTAMP_LOCK", &m.TimestampLock); err != nil {
+		return err
+	}
+	return envBool("MEMPOOL_RECHECK", &m.Recheck)
+}
+
+func envInt(key string, v *int) error {
+	s := os.Getenv(key)
+	if s == "" {
+		return nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, err)
+	}
+	*v = i
+	return nil
+}
+
+func envInt64(key string, v *int64) error {
+	s := os.Getenv(key)
+	if s == "" {
+		return nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, err)
+	}
+	*v = i
+	return nil
+}
+
+func envDuration(key string, v *time.Duration) error {
+	s := os.Getenv(key)
+	if s == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, err)
+	}
+	*v = d
+	return nil
+}
+
+func envBool(key string, v *bool) error {
+	s := os.Getenv(key)
+	if s == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, err)
+	}
+	*v = b
+	return nil
+}
@@ -0,0 +1,119 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// P2PInfo configures the gossip of txs between fabric-mempool instances.
+// Gossip is disabled without a p2p section, unless MEMPOOL_P2P_LADDR is set.
+type P2PInfo struct {
+	// ListenAddress is where peers connect to. (MEMPOOL_P2P_LADDR)
+	ListenAddress string `yaml:"listenAddress"`
+	// ExternalAddress is advertised to peers instead of ListenAddress if it
+	// is set. (MEMPOOL_P2P_EXTERNAL_ADDRESS)
+	ExternalAddress string `yaml:"externalAddress"`
+	// PersistentPeers are dialed at startup and redialed when the
+	// connection is lost, as id@host:port. (MEMPOOL_P2P_PERSISTENT_PEERS,
+	// comma separated)
+	PersistentPeers []string `yaml:"persistentPeers"`
+	// NodeKey is the file of the node key, which is generated if missing.
+	// The node ID of a peer is derived from its key. (MEMPOOL_P2P_NODE_KEY)
+	NodeKey string `yaml:"nodeKey"`
+	// Network must be the same for all instances gossiping together.
+	Network string `yaml:"network"`
+	// Moniker is a readable name of the node shown to peers.
+	Moniker string `yaml:"moniker"`
+	// AllowDuplicateIP allows several peers from the same IP.
+	AllowDuplicateIP bool `yaml:"allowDuplicateIP"`
+	// Scoring punishes peers sending duplicate, invalid or oversize txs.
+	Scoring *PeerScoreInfo `yaml:"scoring"`
+}
+
+// PeerScoreInfo sets the weight each duplicate, invalid and oversize tx adds
+// to the score of the peer which sent it. From ThrottleScore the txs of the
+// peer are dropped, from DisconnectScore it is disconnected, zero disables
+// either. Scores halve every HalfLife.
+type PeerScoreInfo struct {
+	DuplicateWeight float64       `yaml:"duplicateWeight"`
+	InvalidWeight   float64       `yaml:"invalidWeight"`
+	OversizeWeight  float64       `yaml:"oversizeWeight"`
+	ThrottleScore   float64       `yaml:"throttleScore"`
+	DisconnectScore float64       `yaml:"disconnectScore"`
+	HalfLife        time.Duration `yaml:"halfLife"`
+}
+
+// Validate checks the peer score settings.
+func (s *PeerScoreInfo) Validate() error {
+	if s.DuplicateWeight < 0 || s.InvalidWeight < 0 || s.OversizeWeight < 0 {
+		return fmt.Errorf("scoring weights can't be negative")
+	}
+	if s.ThrottleScore < 0 || s.DisconnectScore < 0 {
+		return fmt.Errorf("scoring thresholds can't be negative")
+	}
+	if s.HalfLife < 0 {
+		return fmt.Errorf("scoring halfLife can't be negative, got %s", s.HalfLife)
+	}
+	return nil
+}
+
+// DefaultP2PInfo returns the settings completing a partial p2p section.
+func DefaultP2PInfo() *P2PInfo {
+	return &P2PInfo{
+		ListenAddress: "tcp://0.0.0.0:26656",
+		NodeKey:       "node_key.json",
+		Network:       "fabric-mempool",
+		Moniker:       "fabric-mempool",
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (p *P2PInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*p = *DefaultP2PInfo()
+	type plain P2PInfo
+	return unmarshal((*plain)(p))
+}
+
+// Validate checks the settings are consistent.
+func (p *P2PInfo) Validate() error {
+	if p.ListenAddress == "" {
+		return fmt.Errorf("listenAddress can't be empty")
+	}
+	if p.NodeKey == "" {
+		return fmt.Errorf("nodeKey can't be empty")
+	}
+	if p.Network == "" {
+		return fmt.Errorf("network can't be empty")
+	}
+	for _, peer := range p.PersistentPeers {
+		if !strings.Contains(peer, "@") {
+			return fmt.Errorf("persistent peer %q must be id@host:port", peer)
+		}
+	}
+	if p.Scoring != nil {
+		return p.Scoring.Validate()
+	}
+	return nil
+}
+
+func (p *P2PInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_P2P_LADDR"); v != "" {
+		p.ListenAddress = v
+	}
+	if v := os.Getenv("MEMPOOL_P2P_EXTERNAL_ADDRESS"); v != "" {
+		p.ExternalAddress = v
+	}
+	if v := os.Getenv("MEMPOOL_P2P_PERSISTENT_PEERS"); v != "" {
+		p.PersistentPeers = nil
+		for _, peer := range strings.Split(v, ",") {
+			if peer = strings.TrimSpace(peer); peer != "" {
+				p.PersistentPeers = append(p.PersistentPeers, peer)
+			}
+		}
+	}
+	if v := os.Getenv("MEMPOOL_P2P_NODE_KEY"); v != "" {
+		p.NodeKey = v
+	}
+}
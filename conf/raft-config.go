@@ -0,0 +1,132 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RaftInfo enables the high availability mode, in which several mempool
+// replicas replicate admissions, leases and removals through a Raft log.
+// Any replica accepts transactions, only the leader serves orderers.
+type RaftInfo struct {
+	// ID of this replica, it must be one of Peers. (MEMPOOL_RAFT_ID)
+	ID uint64 `yaml:"id"`
+	// Peers are all replicas of the cluster, this one included.
+	Peers []*RaftPeerInfo `yaml:"peers"`
+	// ListenAddr is the address the Raft service listens on, apart from the
+	// mempool service so that only the peers reach it. (MEMPOOL_RAFT_LADDR)
+	ListenAddr string `yaml:"listenAddr"`
+	// Key is the private key of the TLS certificate of this replica, the
+	// cert of its peer entry. (MEMPOOL_RAFT_KEY)
+	Key string `yaml:"key"`
+	// DataDir keeps the Raft WAL and snapshots. (MEMPOOL_RAFT_DATA)
+	DataDir string `yaml:"dataDir"`
+	// TickInterval is the duration of a Raft tick.
+	TickInterval time.Duration `yaml:"tickInterval"`
+	// ElectionTicks is the number of ticks without a heartbeat after which
+	// a follower starts an election.
+	ElectionTicks int `yaml:"electionTicks"`
+	// HeartbeatTicks is the number of ticks between heartbeats of the leader.
+	HeartbeatTicks int `yaml:"heartbeatTicks"`
+	// SnapshotEntries is the number of entries applied between snapshots,
+	// the log before a snapshot is compacted.
+	SnapshotEntries uint64 `yaml:"snapshotEntries"`
+	// ProposeTimeout bounds how long a request waits for its change to be
+	// replicated.
+	ProposeTimeout time.Duration `yaml:"proposeTimeout"`
+}
+
+// RaftPeerInfo is a replica of the cluster.
+type RaftPeerInfo struct {
+	ID uint64 `yaml:"id"`
+	// Addr is the address of the Raft service of the replica.
+	Addr string `yaml:"addr"`
+	// Cert is the TLS certificate of the replica, the replicas accept each
+	// other by these certificates only.
+	Cert string `yaml:"cert"`
+}
+
+// DefaultRaftInfo returns the settings completing a partial raft section.
+func DefaultRaftInfo() *RaftInfo {
+	return &RaftInfo{
+		DataDir:         "raft",
+		TickInterval:    100 * time.Millisecond,
+		ElectionTicks:   10,
+		HeartbeatTicks:  1,
+		SnapshotEntries: 10000,
+		ProposeTimeout:  5 * time.Second,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (r *RaftInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*r = *DefaultRaftInfo()
+	type plain RaftInfo
+	return unmarshal((*plain)(r))
+}
+
+// Validate checks the settings are consistent.
+func (r *RaftInfo) Validate() error {
+	if r.ID == 0 {
+		return fmt.Errorf("id must be positive")
+	}
+	found := false
+	ids := make(map[uint64]bool, len(r.Peers))
+	for _, peer := range r.Peers {
+		if peer.ID == 0 || peer.Addr == "" || peer.Cert == "" {
+			return fmt.Errorf("peer %d must have a positive id, an addr and a cert", peer.ID)
+		}
+		if ids[peer.ID] {
+			return fmt.Errorf("duplicate peer id %d", peer.ID)
+		}
+		ids[peer.ID] = true
+		found = found || peer.ID == r.ID
+	}
+	if !found {
+		return fmt.Errorf("id %d is not one of the peers", r.ID)
+	}
+	if r.ListenAddr == "" {
+		return fmt.Errorf("listenAddr can't be empty")
+	}
+	if r.Key == "" {
+		return fmt.Errorf("key can't be empty")
+	}
+	if r.DataDir == "" {
+		return fmt.Errorf("dataDir can't be empty")
+	}
+	if r.TickInterval <= 0 {
+		return fmt.Errorf("tickInterval must be positive, got %s", r.TickInterval)
+	}
+	if r.HeartbeatTicks <= 0 || r.ElectionTicks <= r.HeartbeatTicks {
+		return fmt.Errorf("electionTicks %d must be greater than heartbeatTicks %d", r.ElectionTicks, r.HeartbeatTicks)
+	}
+	if r.SnapshotEntries == 0 {
+		return fmt.Errorf("snapshotEntries must be positive")
+	}
+	if r.ProposeTimeout <= 0 {
+		return fmt.Errorf("proposeTimeout must be positive, got %s", r.ProposeTimeout)
+	}
+	return nil
+}
+
+func (r *RaftInfo) loadEnv() error {
+	if v := os.Getenv("MEMPOOL_RAFT_ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid MEMPOOL_RAFT_ID: %s", err)
+		}
+		r.ID = id
+	}
+	if v := os.Getenv("MEMPOOL_RAFT_DATA"); v != "" {
+		r.DataDir = v
+	}
+	if v := os.Getenv("MEMPOOL_RAFT_LADDR"); v != "" {
+		r.ListenAddr = v
+	}
+	if v := os.Getenv("MEMPOOL_RAFT_KEY"); v != "" {
+		r.Key = v
+	}
+	return nil
+}
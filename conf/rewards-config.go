@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// RewardsInfo configures the ledger of the fee rewards of the orderers.
+type RewardsInfo struct {
+	// Dir is the leveldb directory, relative to the mempool rootDir unless
+	// absolute, or to ~/.fabric-mempool without rootDir.
+	// (MEMPOOL_REWARDS_DIR)
+	Dir string `yaml:"dir"`
+	// Epoch is the length of a reward epoch, zero means epochs are only
+	// rolled over on request.
+	Epoch time.Duration `yaml:"epoch"`
+}
+
+// DefaultRewardsInfo returns the settings used when app.yaml has no rewards
+// section, or completing a partial one.
+func DefaultRewardsInfo() *RewardsInfo {
+	return &RewardsInfo{
+		Dir: "rewards",
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (r *RewardsInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*r = *DefaultRewardsInfo()
+	type plain RewardsInfo
+	return unmarshal((*plain)(r))
+}
+
+// Validate checks the settings are consistent.
+func (r *RewardsInfo) Validate() error {
+	if r.Dir == "" {
+		return fmt.Errorf("dir can't be empty")
+	}
+	if r.Epoch < 0 {
+		return fmt.Errorf("epoch can't be negative, got %s", r.Epoch)
+	}
+	return nil
+}
+
+func (r *RewardsInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_REWARDS_DIR"); v != "" {
+		r.Dir = v
+	}
+}
@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// ShardByTxKey spreads the txs of every channel over all shards.
+	ShardByTxKey = "txKey"
+	// ShardByChannel keeps all txs of a channel on the same shard.
+	ShardByChannel = "channel"
+)
+
+// RouterInfo configures the router of the sharded mode, in which txs are
+// partitioned across mempool nodes and the router serves the Mempool
+// service in front of them.
+type RouterInfo struct {
+	// ShardBy is the partition key of the txs, txKey or channel.
+	ShardBy string `yaml:"shardBy"`
+	// Shards are the gRPC addresses of the mempool nodes, the partition of a
+	// tx depends on their order. (MEMPOOL_ROUTER_SHARDS, comma separated)
+	Shards []string `yaml:"shards"`
+	// ReqTimeout bounds every request to a shard.
+	ReqTimeout time.Duration `yaml:"reqTimeout"`
+}
+
+// DefaultRouterInfo returns the settings completing a partial router section.
+func DefaultRouterInfo() *RouterInfo {
+	return &RouterInfo{
+		ShardBy:    ShardByTxKey,
+		ReqTimeout: 5 * time.Second,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (r *RouterInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*r = *DefaultRouterInfo()
+	type plain RouterInfo
+	return unmarshal((*plain)(r))
+}
+
+// Validate checks the settings are consistent.
+func (r *RouterInfo) Validate() error {
+	if r.ShardBy != ShardByTxKey && r.ShardBy != ShardByChannel {
+		return fmt.Errorf("shardBy must be %s or %s, got %q", ShardByTxKey, ShardByChannel, r.ShardBy)
+	}
+	if len(r.Shards) == 0 {
+		return fmt.Errorf("shards can't be empty")
+	}
+	addrs := make(map[string]bool, len(r.Shards))
+	for _, addr := range r.Shards {
+		if addr == "" {
+			return fmt.Errorf("shard addr can't be empty")
+		}
+		if addrs[addr] {
+			return fmt.Errorf("duplicate shard %s", addr)
+		}
+		addrs[addr] = true
+	}
+	if r.ReqTimeout <= 0 {
+		return fmt.Errorf("reqTimeout must be positive, got %s", r.ReqTimeout)
+	}
+	return nil
+}
+
+func (r *RouterInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_ROUTER_SHARDS"); v != "" {
+		r.Shards = strings.Split(v, ",")
+	}
+}
@@ -0,0 +1,75 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// SettlementInfo enables paying the rewards of every ended epoch out on
+// chain. The settlement chaincode is invoked with the function, the epoch
+// number and the JSON rewards of the orderers by name, it must ignore an
+// epoch already settled since a settlement unconfirmed at a restart is
+// submitted again.
+type SettlementInfo struct {
+	// Channel is the channel of the chaincode, it must be one of the
+	// channels whose commits are followed. (MEMPOOL_SETTLEMENT_CHANNEL)
+	Channel string `yaml:"channel"`
+	// Chaincode is the name of the settlement chaincode.
+	// (MEMPOOL_SETTLEMENT_CHAINCODE)
+	Chaincode string `yaml:"chaincode"`
+	Function  string `yaml:"function"`
+	// FeeLimit is the fee limit of the settlement txs.
+	FeeLimit string `yaml:"feeLimit"`
+	// Interval is the period the ended epochs are checked for settlement.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout is how long a settlement waits for its commit before it is
+	// looked up on the peer, and submitted again if it is not committed
+	// valid.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// DefaultSettlementInfo returns the settings completing a partial
+// settlement section.
+func DefaultSettlementInfo() *SettlementInfo {
+	return &SettlementInfo{
+		Chaincode: "settlement",
+		Function:  "settle",
+		FeeLimit:  "0",
+		Interval:  time.Minute,
+		Timeout:   10 * time.Minute,
+	}
+}
+
+// UnmarshalYAML fills the fields missing in app.yaml with their defaults.
+func (s *SettlementInfo) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = *DefaultSettlementInfo()
+	type plain SettlementInfo
+	return unmarshal((*plain)(s))
+}
+
+// Validate checks the settings are consistent, channels are the channels
+// whose commits are followed.
+func (s *SettlementInfo) Validate(channels []string) error {
+	if s.Chaincode == "" || s.Function == "" {
+		return fmt.Errorf("chaincode and function can't be empty")
+	}
+	if s.Interval <= 0 || s.Timeout <= 0 {
+		return fmt.Errorf("interval and timeout must be positive, got %s and %s", s.Interval, s.Timeout)
+	}
+	for _, channel := range channels {
+		if channel == s.Channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("channel %q is not in channels, its commits wouldn't be confirmed", s.Channel)
+}
+
+func (s *SettlementInfo) loadEnv() {
+	if v := os.Getenv("MEMPOOL_SETTLEMENT_CHANNEL"); v != "" {
+		s.Channel = v
+	}
+	if v := os.Getenv("MEMPOOL_SETTLEMENT_CHAINCODE"); v != "" {
+		s.Chaincode = v
+	}
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// RuleClawback is the rule of the credits taking back the rewards of the
+// txs an orderer fetched and missed.
+const RuleClawback = "clawback"
+
+// Accountability follows the txs fetched by every orderer into the blocks
+// committed, a tx not committed within the deadline is missed by its
+// orderer. Once per interval, the orderers whose txs are committed below
+// the inclusion ratio are sanctioned: the rewards of their missed txs are
+// taken back and their capacity is scaled down to their ratio, as enabled.
+type Accountability struct {
+	info     *conf.AccountabilityInfo
+	handler  *Handler
+	channels map[string]bool // followed by the commit listeners
+
+	mtx       sync.Mutex
+	pending   map[string]*fetchedTx      // by txId
+	intervals map[string]*inclusions     // by orderer, since the last check
+	totals    map[string]*conf.Inclusion // by orderer
+	quit      chan struct{}
+}
+
+type fetchedTx struct {
+	orderer string
+	at      time.Time
+	shares  map[string]*big.Int // rewards credited for the tx
+}
+
+type inclusions struct {
+	included int
+	missed   map[string]*fetchedTx
+}
+
+func NewAccountability(info *conf.AccountabilityInfo, handler *Handler) *Accountability {
+	channels := make(map[string]bool, len(AppConf.Channels))
+	for _, channelID := range AppConf.Channels {
+		channels[channelID] = true
+	}
+	return &Accountability{
+		info:      info,
+		handler:   handler,
+		channels:  channels,
+		pending:   make(map[string]*fetchedTx),
+		intervals: make(map[string]*inclusions),
+		totals:    make(map[string]*conf.Inclusion),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start checks the inclusion ratios in the background until Stop is called.
+func (a *Accountability) Start() {
+	go func() {
+		ticker := time.NewTicker(a.info.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.check()
+			case <-a.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (a *Accountability) Stop() {
+	close(a.quit)
+}
+
+func (a *Accountability) interval(orderer string) *inclusions {
+	if _, ok := a.intervals[orderer]; !ok {
+		a.intervals[orderer] = &inclusions{missed: make(map[string]*fetchedTx)}
+	}
+	return a.intervals[orderer]
+}
+
+func (a *Accountability) total(orderer string) *conf.Inclusion {
+	if _, ok := a.totals[orderer]; !ok {
+		a.totals[orderer] = &conf.Inclusion{ClawedBack: "0"}
+	}
+	return a.totals[orderer]
+}
+
+// fetched starts following txId fetched by orderer, shares are the rewards
+// credited for it. A tx fetched again is followed for its last orderer. The
+// txs of a channel not followed are never seen committed, so they are left
+// out rather than missed.
+func (a *Accountability) fetched(tx []byte, txId, orderer string, shares map[string]*big.Int) {
+	if !a.follows(tx) {
+		return
+	}
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if previous, ok := a.pending[txId]; ok {
+		a.total(previous.orderer).Pending--
+	}
+	a.pending[txId] = &fetchedTx{orderer: orderer, at: time.Now(), shares: shares}
+	a.total(orderer).Pending++
+}
+
+// follows checks the blocks of the channel of tx are delivered.
+func (a *Accountability) follows(tx []byte) bool {
+	env, err := protoutil.UnmarshalEnvelope(tx)
+	if err != nil {
+		return false
+	}
+	channelID, err := protoutil.ChannelID(env)
+	if err != nil {
+		return false
+	}
+	return a.channels[channelID]
+}
+
+// committed credits the orderer of txId with its inclusion, whatever its
+// validation code since ordering it was the job of the orderer.
+func (a *Accountability) committed(txId string) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	tx, ok := a.pending[txId]
+	if !ok {
+		return
+	}
+	delete(a.pending, txId)
+	total := a.total(tx.orderer)
+	total.Pending--
+	total.Included++
+	a.interval(tx.orderer).included++
+}
+
+// Inclusion returns the inclusion counts of orderer.
+func (a *Accountability) Inclusion(orderer string) *conf.Inclusion {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	inclusion := *a.total(orderer)
+	if settled := inclusion.Included + inclusion.Missed; settled > 0 {
+		inclusion.Ratio = float64(inclusion.Included) / float64(settled)
+	}
+	return &inclusion
+}
+
+// check expires the txs past the deadline and sanctions the orderers below
+// the inclusion ratio over the interval.
+func (a *Accountability) check() {
+	a.mtx.Lock()
+	for txId, tx := range a.pending {
+		if time.Since(tx.at) < a.info.Deadline {
+			continue
+		}
+		delete(a.pending, txId)
+		total := a.total(tx.orderer)
+		total.Pending--
+		total.Missed++
+		a.interval(tx.orderer).missed[txId] = tx
+	}
+	intervals := a.intervals
+	a.intervals = make(map[string]*inclusions)
+	a.mtx.Unlock()
+
+	for orderer, interval := range intervals {
+		settled := interval.included + len(interval.missed)
+		if settled < a.info.MinTxs {
+			continue
+		}
+		ratio := float64(interval.included) / float64(settled)
+		if ratio >= a.info.MinRatio {
+			continue
+		}
+		logger.Info("Orderer missed its inclusion ratio", "ordererName", orderer, "ratio", ratio,
+			"minRatio", a.info.MinRatio, "included", interval.included, "missed", len(interval.missed))
+		a.sanction(orderer, ratio, interval.missed)
+	}
+}
+
+func (a *Accountability) sanction(orderer string, ratio float64, missed map[string]*fetchedTx) {
+	clawedBack := new(big.Int)
+	if a.info.Clawback {
+		for txId, tx := range missed {
+			if share, ok := tx.shares[orderer]; ok && share.Sign() > 0 {
+				a.handler.clawback(txId, orderer, share)
+				clawedBack.Add(clawedBack, share)
+			}
+		}
+	}
+
+	var from, to int
+	if client := a.handler.fetcher.GetOrderer(orderer); client != nil && a.info.ReduceCapacity {
+		from = client.Capacity()
+		to = int(float64(from) * ratio)
+		if min := a.minCapacity(); to < min {
+			to = min
+		}
+		client.setCapacity(to)
+	}
+
+	a.mtx.Lock()
+	total := a.total(orderer)
+	total.Sanctions++
+	if sum, ok := new(big.Int).SetString(total.ClawedBack, 10); ok {
+		total.ClawedBack = sum.Add(sum, clawedBack).String()
+	}
+	a.mtx.Unlock()
+
+	a.handler.audit(AuditSanction, map[string]interface{}{
+		"orderer":       orderer,
+		"ratio":         ratio,
+		"missed":        len(missed),
+		"clawed_back":   clawedBack.String(),
+		"capacity_from": from,
+		"capacity_to":   to,
+	})
+	logger.Info("Sanctioned orderer", "ordererName", orderer, "clawedBack", clawedBack, "capacityFrom", from, "capacityTo", to)
+}
+
+func (a *Accountability) minCapacity() int {
+	if AppConf.Capacity != nil {
+		return AppConf.Capacity.MinCapacity
+	}
+	return 1
+}
+
+// clawback takes back the reward credited to orderer for txId, the shares of
+// the other orderers are left to them since they did not miss the tx. The
+// debit is recorded in the current epoch, so its settlement nets it.
+func (h *Handler) clawback(txId, orderer string, share *big.Int) {
+	debit := new(big.Int).Neg(share)
+	if err := h.rewards.Credit(txId, RuleClawback, map[string]*big.Int{orderer: debit}); err != nil {
+		logger.Error("Could not record fee reward clawback", "txId", txId, "error", err)
+		return
+	}
+	if client := h.fetcher.GetOrderer(orderer); client != nil {
+		client.AddTax(debit)
+	}
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys of the signature of an admin request.
+const (
+	AdminCreatorKey   = "admin-creator-bin"
+	AdminSignatureKey = "admin-signature-bin"
+	AdminTimestampKey = "admin-timestamp"
+)
+
+// Headers of the signature of an admin request to the rest server, the
+// creator and the signature are base64 encoded.
+const (
+	AdminCreatorHeader   = "X-Admin-Creator"
+	AdminSignatureHeader = "X-Admin-Signature"
+	AdminTimestampHeader = "X-Admin-Timestamp"
+)
+
+// Operations an admin signs.
+const (
+	AdminAddOrderer          = "AddOrderer"
+	AdminDrainOrderer        = "DrainOrderer"
+	AdminRemoveOrderer       = "RemoveOrderer"
+	AdminRolloverRewardEpoch = "RolloverRewardEpoch"
+	AdminChangeDistribute    = "ChangeDistribute"
+	AdminChangeSortSwitch    = "ChangeSortSwitch"
+	AdminChangeCapacity      = "ChangeOrdererCapacity"
+)
+
+// AdminMessage returns the bytes an admin signs to make operation with
+// request, nil for the reward epochs. They hold the digest of the whole
+// request, so that none of its fields can be changed. timestamp is in unix
+// nanoseconds.
+func AdminMessage(operation string, request interface{}, timestamp int64) ([]byte, error) {
+	bz, err := adminRequestBytes(request)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(bz)
+	return []byte(fmt.Sprintf("admin %s %x %d", operation, digest, timestamp)), nil
+}
+
+// adminRequestBytes encodes the protos deterministically, and the settings
+// of the rest server in JSON.
+func adminRequestBytes(request interface{}) ([]byte, error) {
+	switch req := request.(type) {
+	case nil:
+		return nil, nil
+	case proto.Message:
+		buf := proto.NewBuffer(nil)
+		buf.SetDeterministic(true)
+		if err := buf.Marshal(req); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return json.Marshal(req)
+	}
+}
+
+func signAdmin(signer *Crypto, operation string, request interface{}) (creator, signature []byte, timestamp int64, err error) {
+	timestamp = time.Now().UnixNano()
+	message, err := AdminMessage(operation, request, timestamp)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	signature, err = signer.Sign(message)
+	return signer.Creator, signature, timestamp, err
+}
+
+// SignAdminRequest attaches the signature of signer over operation with
+// request to the outgoing metadata of ctx.
+func SignAdminRequest(ctx context.Context, signer *Crypto, operation string, request interface{}) (context.Context, error) {
+	creator, signature, timestamp, err := signAdmin(signer, operation, request)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx,
+		AdminCreatorKey, string(creator),
+		AdminSignatureKey, string(signature),
+		AdminTimestampKey, strconv.FormatInt(timestamp, 10),
+	), nil
+}
+
+// SignAdminHTTPRequest sets the headers of the signature of signer over
+// operation with request to req, request is the body of req decoded.
+func SignAdminHTTPRequest(req *http.Request, signer *Crypto, operation string, request interface{}) error {
+	creator, signature, timestamp, err := signAdmin(signer, operation, request)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(AdminCreatorHeader, base64.StdEncoding.EncodeToString(creator))
+	req.Header.Set(AdminSignatureHeader, base64.StdEncoding.EncodeToString(signature))
+	req.Header.Set(AdminTimestampHeader, strconv.FormatInt(timestamp, 10))
+	return nil
+}
+
+// adminContext passes the signature headers of a rest request on as the
+// incoming metadata of an admin request.
+func adminContext(ctx *gin.Context) context.Context {
+	md := metadata.MD{}
+	for key, header := range map[string]string{
+		AdminCreatorKey:   AdminCreatorHeader,
+		AdminSignatureKey: AdminSignatureHeader,
+	} {
+		if v, err := base64.StdEncoding.DecodeString(ctx.GetHeader(header)); err == nil && len(v) > 0 {
+			md.Set(key, string(v))
+		}
+	}
+	if v := ctx.GetHeader(AdminTimestampHeader); v != "" {
+		md.Set(AdminTimestampKey, v)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// adminAuth authenticates the admin requests.
+type adminAuth struct {
+	info   *conf.AdminAuthInfo
+	roots  *x509.CertPool
+	admins map[string]bool
+
+	mtx      sync.Mutex
+	signedAt map[string]int64 // by admin, of the last request
+}
+
+func newAdminAuth(info *conf.AdminAuthInfo) (*adminAuth, error) {
+	roots, err := loadCACerts(info.CACerts, "peerOrganizations/org1.example.com/msp/cacerts/*")
+	if err != nil {
+		return nil, err
+	}
+	admins := make(map[string]bool, len(info.Admins))
+	for _, admin := range info.Admins {
+		admins[admin] = true
+	}
+	return &adminAuth{info: info, roots: roots, admins: admins, signedAt: make(map[string]int64)}, nil
+}
+
+// authenticate checks the request of ctx is signed by an admin for operation
+// with request, and returns the admin.
+func (a *adminAuth) authenticate(ctx context.Context, operation string, request interface{}) (string, error) {
+	if _, err := adminRequestBytes(request); err != nil {
+		return "", errors.WithMessage(err, "invalid admin request")
+	}
+	keys := signatureKeys{AdminCreatorKey, AdminSignatureKey, AdminTimestampKey}
+	cert, timestamp, err := keys.verify(ctx, "admin request", a.info.MSPID, a.roots, a.info.TimeWindow, func(timestamp int64) []byte {
+		message, _ := AdminMessage(operation, request, timestamp)
+		return message
+	})
+	if err != nil {
+		return "", err
+	}
+	admin := cert.Subject.CommonName
+	if !a.admins[admin] {
+		return "", errors.Errorf("admin request signed by %s, not by an admin", admin)
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if timestamp <= a.signedAt[admin] {
+		return "", errors.Errorf("replayed admin request of %s", admin)
+	}
+	a.signedAt[admin] = timestamp
+	return admin, nil
+}
+
+// authorize checks the request of ctx is signed by an admin for operation
+// with request, and returns the admin.
+func (h *Handler) authorize(ctx context.Context, operation string, request interface{}) (string, error) {
+	admin, err := h.adminAuth.authenticate(ctx, operation, request)
+	if err != nil {
+		logger.Error("Rejected unauthenticated admin request", "operation", operation, "error", err)
+		return "", err
+	}
+	return admin, nil
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"google.golang.org/grpc/metadata"
+)
+
+func newTestAdminAuth(ca *testCA) *adminAuth {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	info := conf.DefaultAdminAuthInfo()
+	return &adminAuth{info: info, roots: roots, admins: map[string]bool{"Admin@org1.example.com": true}, signedAt: make(map[string]int64)}
+}
+
+// signedAdmin returns the incoming context of operation with request signed
+// by signer.
+func signedAdmin(t *testing.T, signer *Crypto, operation string, request interface{}) context.Context {
+	ctx, err := SignAdminRequest(context.Background(), signer, operation, request)
+	require.NoError(t, err)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestAdminAuthAuthenticate(t *testing.T) {
+	ca := newTestCA(t)
+	admin := ca.issue(t, "Org1MSP", "Admin@org1.example.com")
+	orderer0 := &protos.OrdererRequest{Name: "orderer0"}
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		err  string
+	}{
+		{"admin", signedAdmin(t, admin, AdminDrainOrderer, orderer0), ""},
+		{"not signed", context.Background(), "admin request is not signed"},
+		{"not an admin", signedAdmin(t, ca.issue(t, "Org1MSP", "User1@org1.example.com"), AdminDrainOrderer, orderer0), "not by an admin"},
+		{"wrong msp", signedAdmin(t, ca.issue(t, "Org2MSP", "Admin@org1.example.com"), AdminDrainOrderer, orderer0), "not a member of Org1MSP"},
+		{"untrusted ca", signedAdmin(t, newTestCA(t).issue(t, "Org1MSP", "Admin@org1.example.com"), AdminDrainOrderer, orderer0), "not a member of Org1MSP"},
+		{"other operation", signedAdmin(t, admin, AdminRemoveOrderer, orderer0), "invalid admin request"},
+		{"other orderer", signedAdmin(t, admin, AdminDrainOrderer, &protos.OrdererRequest{Name: "orderer1"}), "invalid admin request"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := newTestAdminAuth(ca).authenticate(c.ctx, AdminDrainOrderer, orderer0)
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+
+	t.Run("other endpoint", func(t *testing.T) {
+		// the signature covers the whole request, not only the name
+		req := &protos.AddOrdererRequest{Name: "orderer1", Host: "orderer1.example.com", Port: 7050, TlsCaCert: []byte("ca")}
+		ctx := signedAdmin(t, admin, AdminAddOrderer, req)
+		forged := *req
+		forged.Host = "attacker.example.com"
+		_, err := newTestAdminAuth(ca).authenticate(ctx, AdminAddOrderer, &forged)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid admin request")
+		_, err = newTestAdminAuth(ca).authenticate(ctx, AdminAddOrderer, req)
+		assert.NoError(t, err)
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		auth := newTestAdminAuth(ca)
+		ctx := signedAdmin(t, admin, AdminRolloverRewardEpoch, nil)
+		name, err := auth.authenticate(ctx, AdminRolloverRewardEpoch, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Admin@org1.example.com", name)
+		_, err = auth.authenticate(ctx, AdminRolloverRewardEpoch, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "replayed")
+	})
+}
+
+func TestAdminContext(t *testing.T) {
+	ca := newTestCA(t)
+	admin := ca.issue(t, "Org1MSP", "Admin@org1.example.com")
+	req := httptest.NewRequest(http.MethodDelete, "/orderers/orderer0", nil)
+	require.NoError(t, SignAdminHTTPRequest(req, admin, AdminRemoveOrderer, &protos.OrdererRequest{Name: "orderer0"}))
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = req
+
+	_, err := newTestAdminAuth(ca).authenticate(adminContext(ctx), AdminRemoveOrderer, &protos.OrdererRequest{Name: "orderer0"})
+	assert.NoError(t, err)
+}
+
+func TestOrdererAdminRequiresAdmin(t *testing.T) {
+	h := &Handler{adminAuth: newTestAdminAuth(newTestCA(t))}
+	_, err := h.AddOrderer(context.Background(), &protos.AddOrdererRequest{Name: "orderer1"})
+	assert.EqualError(t, err, "admin request is not signed")
+	_, err = h.DrainOrderer(context.Background(), &protos.OrdererRequest{Name: "orderer0"})
+	assert.EqualError(t, err, "admin request is not signed")
+	_, err = h.RemoveOrderer(context.Background(), &protos.OrdererRequest{Name: "orderer0"})
+	assert.EqualError(t, err, "admin request is not signed")
+	_, err = h.RolloverRewardEpoch(context.Background())
+	assert.EqualError(t, err, "admin request is not signed")
+	err = h.ChangeDistribute(context.Background(), &conf.DistributeConfig{Strategy: conf.DistributeByStake, Stakes: map[string]int64{"orderer0": 1}})
+	assert.EqualError(t, err, "admin request is not signed")
+	err = h.ChangeSortSwitch(context.Background(), &conf.SortConfig{SortSwitch: true})
+	assert.EqualError(t, err, "admin request is not signed")
+	err = h.ChangeOrdererCapacity(context.Background(), &conf.OrdererCapacityConfig{Orderer: "orderer0", Capacity: 1})
+	assert.EqualError(t, err, "admin request is not signed")
+}
+
+func TestChangeDistributeSigned(t *testing.T) {
+	ca := newTestCA(t)
+	admin := ca.issue(t, "Org1MSP", "Admin@org1.example.com")
+	h := &Handler{adminAuth: newTestAdminAuth(ca), distributeConfig: &conf.DistributeConfig{}, auditLog: &AuditLog{entries: make(chan *AuditEntry, 1)}}
+
+	config := &conf.DistributeConfig{Strategy: conf.DistributeByStake, Stakes: map[string]int64{"orderer0": 1}}
+	ctx := signedAdmin(t, admin, AdminChangeDistribute, config)
+	// the stakes are signed with the strategy
+	forged := &conf.DistributeConfig{Strategy: conf.DistributeByStake, Stakes: map[string]int64{"orderer1": 1}}
+	assert.Error(t, h.ChangeDistribute(ctx, forged))
+	require.NoError(t, h.ChangeDistribute(ctx, config))
+	assert.Equal(t, conf.DistributeByStake, h.distributeConfig.StrategyName())
+}
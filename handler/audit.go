@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Types of the audit log entries.
+const (
+	// AuditAllocation is the sharing of the fee of a fetched tx.
+	AuditAllocation = "allocation"
+	// AuditDistribution is a change of the distribution strategy.
+	AuditDistribution = "distribution"
+	// AuditSort is a change of the sorting switch.
+	AuditSort = "sort"
+	// AuditCapacity is a capacity of an orderer pinned or unpinned by an
+	// admin.
+	AuditCapacity = "capacity"
+	// AuditSanction is a sanction of an orderer below its inclusion ratio.
+	AuditSanction = "sanction"
+	// AuditOrderer is an orderer added, drained or removed by an admin.
+	AuditOrderer = "orderer"
+	// AuditRollover is a reward epoch ended by an admin.
+	AuditRollover = "rollover"
+	// AuditDropped counts the entries dropped while the queue was full.
+	AuditDropped = "dropped"
+)
+
+// AuditEntry is a record of the audit log, chained to the previous record
+// by its hash and covered by the signature of the mempool.
+type AuditEntry struct {
+	Seq       uint64          `json:"seq"`
+	Time      int64           `json:"time"` // unix nanoseconds
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+	Signature []byte          `json:"signature"` // over the hash, on the last entry of a batch only
+}
+
+// digest hashes the entry but its hash and signature.
+func (e *AuditEntry) digest() []byte {
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	var n [16]byte
+	binary.BigEndian.PutUint64(n[:], e.Seq)
+	binary.BigEndian.PutUint64(n[8:], uint64(e.Time))
+	h.Write(n[:])
+	h.Write([]byte(e.Type))
+	h.Write([]byte{0})
+	h.Write(e.Data)
+	return h.Sum(nil)
+}
+
+// Sizes of the queue of the audit entries and of the batches written.
+const (
+	AuditQueueSize = 4096
+	AuditBatchSize = 256
+)
+
+// AuditLog appends the fee allocations and the admin changes to a file of
+// JSON entries, one per line. Every entry holds the hash of the previous one
+// so that an entry altered, removed or inserted breaks the chain. The
+// entries are written by batches in the background, and only the last entry
+// of a batch is signed: its signature covers the entries chained before it.
+// Removing entries from the tail leaves a valid chain, which is only
+// detected against a seq and a head hash kept elsewhere, such as the ones
+// printed by the audit verify command.
+//
+// Appending never blocks the fetches: the entries appended while the queue
+// is full are dropped, and their number is recorded in the chain by a
+// dropped entry.
+type AuditLog struct {
+	file    *os.File
+	signer  *Crypto
+	entries chan *AuditEntry
+	done    chan struct{}
+	dropped uint64 // atomic, since the last dropped entry
+
+	// used by the writer only, once opened
+	seq      uint64
+	lastHash string
+}
+
+// OpenAuditLog opens the audit log at path to append the entries signed by
+// signer, following the entries of a previous run. The tail of a batch torn
+// by a crash, after the last signed entry, is truncated.
+func OpenAuditLog(path string, signer *Crypto) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AuditLog{
+		file:    file,
+		signer:  signer,
+		entries: make(chan *AuditEntry, AuditQueueSize),
+		done:    make(chan struct{}),
+	}
+	if err := a.recover(); err != nil {
+		file.Close()
+		return nil, errors.WithMessagef(err, "corrupt audit log %s", path)
+	}
+	go a.write()
+	return a, nil
+}
+
+// recover follows the last signed entry of the file, and truncates the
+// entries after it. Those are left by a batch partly written, the last line
+// may be torn.
+func (a *AuditLog) recover() error {
+	reader := bufio.NewReader(a.file)
+	var offset, end int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(line) == 0 {
+			break
+		}
+		offset += int64(len(line))
+		entry := &AuditEntry{}
+		if jsonErr := json.Unmarshal(line, entry); jsonErr != nil {
+			if err == io.EOF {
+				// torn last line
+				break
+			}
+			return errors.WithMessagef(jsonErr, "invalid audit entry at offset %d", offset-int64(len(line)))
+		}
+		if len(entry.Signature) > 0 {
+			a.seq, a.lastHash, end = entry.Seq, entry.Hash, offset
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if end == offset {
+		return nil
+	}
+	logger.Error("Truncating the torn tail of the audit log", "seq", a.seq, "offset", end, "size", offset)
+	return a.file.Truncate(end)
+}
+
+// Append queues an entry of data, it is dropped while the queue is full.
+func (a *AuditLog) Append(typ string, data interface{}) error {
+	bz, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	// the data is hashed as written, which is compacted by the encoding of
+	// the entry
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, bz); err != nil {
+		return err
+	}
+	select {
+	case a.entries <- &AuditEntry{Time: time.Now().UnixNano(), Type: typ, Data: compact.Bytes()}:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	return nil
+}
+
+// write appends the queued entries by batches until the log is closed.
+func (a *AuditLog) write() {
+	defer close(a.done)
+	for entry := range a.entries {
+		batch := []*AuditEntry{entry}
+	drain:
+		for len(batch) < AuditBatchSize {
+			select {
+			case entry, ok := <-a.entries:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, entry)
+			default:
+				break drain
+			}
+		}
+		if dropped := a.droppedEntry(); dropped != nil {
+			batch = append([]*AuditEntry{dropped}, batch...)
+		}
+		if err := a.writeBatch(batch); err != nil {
+			logger.Error("Could not append audit entries", "entries", len(batch), "error", err)
+		}
+	}
+	if dropped := a.droppedEntry(); dropped != nil {
+		if err := a.writeBatch([]*AuditEntry{dropped}); err != nil {
+			logger.Error("Could not append audit entries", "entries", 1, "error", err)
+		}
+	}
+}
+
+// droppedEntry returns the entry recording the entries dropped since the
+// last one, nil if none was.
+func (a *AuditLog) droppedEntry() *AuditEntry {
+	dropped := atomic.SwapUint64(&a.dropped, 0)
+	if dropped == 0 {
+		return nil
+	}
+	logger.Error("Dropped audit entries, the queue was full", "entries", dropped)
+	return &AuditEntry{
+		Time: time.Now().UnixNano(),
+		Type: AuditDropped,
+		Data: []byte(fmt.Sprintf(`{"entries":%d}`, dropped)),
+	}
+}
+
+// writeBatch chains the entries of batch to the log, signs the last one and
+// writes them at once. The log is left as it was on error.
+func (a *AuditLog) writeBatch(batch []*AuditEntry) error {
+	seq, lastHash := a.seq, a.lastHash
+	buf := &bytes.Buffer{}
+	for i, entry := range batch {
+		seq++
+		entry.Seq, entry.PrevHash = seq, lastHash
+		hash := entry.digest()
+		entry.Hash = hex.EncodeToString(hash)
+		if i == len(batch)-1 {
+			var err error
+			if entry.Signature, err = a.signer.Sign(hash); err != nil {
+				return err
+			}
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+		lastHash = entry.Hash
+	}
+	if _, err := a.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	a.seq, a.lastHash = seq, lastHash
+	return nil
+}
+
+// Close writes the queued entries and closes the log, no entry may be
+// appended anymore.
+func (a *AuditLog) Close() error {
+	close(a.entries)
+	<-a.done
+	return a.file.Close()
+}
+
+func readAuditLog(r io.Reader, fn func(entry *AuditEntry) error) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return errors.WithMessage(err, "invalid audit entry")
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// VerifyAuditLog checks the entries of r are chained and signed with the
+// PEM encoded certificate, up to the last one which must be signed. It
+// returns the number of entries checked and the hash of the last one.
+func VerifyAuditLog(r io.Reader, certPEM []byte) (int, string, error) {
+	count := 0
+	prevHash := ""
+	var unsigned uint64 // first entry not covered by a signature yet
+	err := readAuditLog(r, func(entry *AuditEntry) error {
+		count++
+		if entry.Seq != uint64(count) {
+			return errors.Errorf("audit entry %d has seq %d", count, entry.Seq)
+		}
+		if entry.PrevHash != prevHash {
+			return errors.Errorf("audit entry %d is not chained to the previous one", entry.Seq)
+		}
+		hash := entry.digest()
+		if entry.Hash != hex.EncodeToString(hash) {
+			return errors.Errorf("audit entry %d does not match its hash", entry.Seq)
+		}
+		if len(entry.Signature) == 0 {
+			if unsigned == 0 {
+				unsigned = entry.Seq
+			}
+		} else if _, err := verifySignature(certPEM, entry.Signature, hash); err != nil {
+			return errors.WithMessagef(err, "audit entry %d", entry.Seq)
+		} else {
+			unsigned = 0
+		}
+		prevHash = entry.Hash
+		return nil
+	})
+	if err == nil && unsigned != 0 {
+		err = errors.Errorf("audit entries from %d are not signed", unsigned)
+	}
+	return count, prevHash, err
+}
+
+// audit appends an entry to the audit log, the change audited is already
+// made so a failure is only logged.
+func (h *Handler) audit(typ string, data interface{}) {
+	if err := h.auditLog.Append(typ, data); err != nil {
+		logger.Error("Could not append audit entry", "type", typ, "error", err)
+	}
+}
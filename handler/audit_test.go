@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.issue(t, "Org1MSP", "User1@org1.example.com")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.SignCert.Raw})
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	a, err := OpenAuditLog(path, signer)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.Append(AuditAllocation, map[string]interface{}{"tx": i}))
+	}
+	require.NoError(t, a.Close())
+	// a reopened log follows the entries of the previous run
+	a, err = OpenAuditLog(path, signer)
+	require.NoError(t, err)
+	var batch []*AuditEntry
+	for i := 3; i < 11; i++ {
+		batch = append(batch, &AuditEntry{Type: AuditAllocation, Data: []byte(fmt.Sprintf(`{"tx":%d}`, i))})
+	}
+	require.NoError(t, a.writeBatch(batch))
+	require.NoError(t, a.Close())
+
+	log, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(log), "\n"), "\n")
+	require.Len(t, lines, 11)
+	count, head, err := VerifyAuditLog(bytes.NewReader(log), certPEM)
+	require.NoError(t, err)
+	assert.Equal(t, 11, count)
+	assert.Contains(t, lines[10], head)
+
+	tests := []struct {
+		name  string
+		lines []string
+		err   string
+	}{
+		{"entry removed", append(append([]string{}, lines[:4]...), lines[5:]...), "has seq"},
+		{"entry altered", append(append(append([]string{}, lines[:4]...), strings.Replace(lines[4], `"tx":4`, `"tx":5`, 1)), lines[5:]...), "does not match its hash"},
+		{"unsigned tail", lines[:9], "entries from 4 are not signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := VerifyAuditLog(strings.NewReader(strings.Join(tt.lines, "")), certPEM)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	_, _, err = VerifyAuditLog(bytes.NewReader(log), ca.certPEM)
+	assert.Error(t, err, "signed by another certificate")
+}
+
+func TestAuditLogTornTail(t *testing.T) {
+	ca := newTestCA(t)
+	signer := ca.issue(t, "Org1MSP", "User1@org1.example.com")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.SignCert.Raw})
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	batch := func(from, to int) []*AuditEntry {
+		var entries []*AuditEntry
+		for i := from; i < to; i++ {
+			entries = append(entries, &AuditEntry{Type: AuditAllocation, Data: []byte(fmt.Sprintf(`{"tx":%d}`, i))})
+		}
+		return entries
+	}
+	a, err := OpenAuditLog(path, signer)
+	require.NoError(t, err)
+	require.NoError(t, a.writeBatch(batch(0, 3)))
+	require.NoError(t, a.Close())
+	signed, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	a, err = OpenAuditLog(path, signer)
+	require.NoError(t, err)
+	require.NoError(t, a.writeBatch(batch(3, 6)))
+	require.NoError(t, a.Close())
+
+	// a crash tore the second batch within its last line
+	log, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, log[:len(log)-10], 0644))
+
+	a, err = OpenAuditLog(path, signer)
+	require.NoError(t, err)
+	truncated, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, signed, truncated)
+	require.NoError(t, a.writeBatch(batch(3, 4)))
+	require.NoError(t, a.Close())
+
+	log, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	count, _, err := VerifyAuditLog(bytes.NewReader(log), certPEM)
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	// an entry torn before the tail is not recovered
+	lines := strings.SplitAfter(string(log), "\n")
+	corrupt := lines[0] + lines[1][:20] + "\n" + strings.Join(lines[2:], "")
+	require.NoError(t, ioutil.WriteFile(path, []byte(corrupt), 0644))
+	_, err = OpenAuditLog(path, signer)
+	assert.Error(t, err)
+}
+
+func TestAuditLogDropsWhenFull(t *testing.T) {
+	a := &AuditLog{entries: make(chan *AuditEntry, 1)}
+	require.NoError(t, a.Append(AuditAllocation, map[string]interface{}{"tx": 1}))
+	require.NoError(t, a.Append(AuditAllocation, map[string]interface{}{"tx": 2}))
+	require.NoError(t, a.Append(AuditAllocation, map[string]interface{}{"tx": 3}))
+	assert.Len(t, a.entries, 1)
+
+	dropped := a.droppedEntry()
+	require.NotNil(t, dropped)
+	assert.Equal(t, AuditDropped, dropped.Type)
+	assert.JSONEq(t, `{"entries":2}`, string(dropped.Data))
+	assert.Nil(t, a.droppedEntry())
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	cb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+	"google.golang.org/grpc"
+)
+
+// broadcastStream pipelines envelopes on a Broadcast stream of an orderer,
+// up to a window of them waiting for their acknowledgement. The orderer
+// acknowledges the envelopes of a stream in order, so every ack is
+// correlated to the oldest envelope pending.
+type broadcastStream struct {
+	client ab.AtomicBroadcast_BroadcastClient
+	cancel context.CancelFunc
+	window chan struct{} // a slot per pending envelope
+	onAck  func(start time.Time, err error)
+
+	sendMtx sync.Mutex // orders the sends and the pending envelopes alike
+	mtx     sync.Mutex
+	pending []*pendingEnvelope
+	err     error
+	done    chan struct{} // closed once the stream is broken
+}
+
+type pendingEnvelope struct {
+	start  time.Time
+	result chan error
+}
+
+func openBroadcastStream(conn *grpc.ClientConn, window int, onAck func(start time.Time, err error)) (*broadcastStream, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := ab.NewAtomicBroadcastClient(conn).Broadcast(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s := &broadcastStream{
+		client: client,
+		cancel: cancel,
+		window: make(chan struct{}, window),
+		onAck:  onAck,
+		done:   make(chan struct{}),
+	}
+	go s.recvLoop()
+	return s, nil
+}
+
+// send sends env once there is room in the window, the result of its
+// broadcast is delivered on the returned channel.
+func (s *broadcastStream) send(env *cb.Envelope) <-chan error {
+	result := make(chan error, 1)
+	select {
+	case s.window <- struct{}{}:
+	case <-s.done:
+		result <- s.brokenErr()
+		return result
+	}
+
+	s.sendMtx.Lock()
+	defer s.sendMtx.Unlock()
+	s.mtx.Lock()
+	if s.err != nil {
+		s.mtx.Unlock()
+		<-s.window
+		result <- s.err
+		return result
+	}
+	s.pending = append(s.pending, &pendingEnvelope{start: time.Now(), result: result})
+	s.mtx.Unlock()
+
+	if err := s.client.Send(env); err != nil {
+		// fails the envelope with all the pending ones
+		s.fail(errors.WithMessage(err, "could not send"))
+	}
+	return result
+}
+
+func (s *broadcastStream) recvLoop() {
+	for {
+		msg, err := s.client.Recv()
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		s.mtx.Lock()
+		if len(s.pending) == 0 {
+			s.mtx.Unlock()
+			s.fail(errors.New("unexpected broadcast ack"))
+			return
+		}
+		env := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mtx.Unlock()
+		<-s.window
+
+		if msg.Status != cb.Status_SUCCESS {
+			err = &statusError{status: msg.Status}
+		}
+		s.onAck(env.start, err)
+		env.result <- err
+	}
+}
+
+// fail breaks the stream, failing all the pending envelopes with err.
+func (s *broadcastStream) fail(err error) {
+	s.mtx.Lock()
+	if s.err != nil {
+		s.mtx.Unlock()
+		return
+	}
+	s.err = err
+	pending := s.pending
+	s.pending = nil
+	close(s.done)
+	s.mtx.Unlock()
+
+	s.cancel()
+	for _, env := range pending {
+		<-s.window
+		s.onAck(env.start, err)
+		env.result <- err
+	}
+}
+
+// statusError is the error of an envelope the orderer acknowledged with a
+// status other than SUCCESS, the stream stays sound.
+type statusError struct {
+	status cb.Status
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("catch unexpected status: %v", e.status)
+}
+
+// isStatusError checks err is a status acknowledged by the orderer rather
+// than a stream or transport error.
+func isStatusError(err error) bool {
+	_, ok := err.(*statusError)
+	return ok
+}
+
+// isRejected checks the orderer rejected the envelope itself, like a bad
+// request or a forbidden one, so that broadcasting it again is pointless.
+func isRejected(err error) bool {
+	e, ok := err.(*statusError)
+	return ok && e.status >= 400 && e.status < 500
+}
+
+func (s *broadcastStream) brokenErr() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.err
+}
+
+func (s *broadcastStream) broken() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *broadcastStream) close() {
+	s.fail(errors.New("broadcast stream closed"))
+}
+
+// openStreams opens the pool of broadcast streams of the orderer on conn.
+func (b *BroadcastClient) openStreams(conn *grpc.ClientConn) ([]*broadcastStream, error) {
+	streams := make([]*broadcastStream, AppConf.Broadcast.Streams)
+	for i := range streams {
+		s, err := openBroadcastStream(conn, AppConf.Broadcast.Window, b.recordAck)
+		if err != nil {
+			for _, s := range streams[:i] {
+				s.close()
+			}
+			return nil, err
+		}
+		streams[i] = s
+	}
+	return streams, nil
+}
+
+// stream returns the next stream of the pool, a broken stream is reopened.
+func (b *BroadcastClient) stream() (*broadcastStream, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.streams) == 0 {
+		return nil, errors.Errorf("orderer %s is not connected", b.name)
+	}
+	i := int(atomic.AddUint32(&b.next, 1) % uint32(len(b.streams)))
+	if b.streams[i].broken() {
+		s, err := openBroadcastStream(b.conn, AppConf.Broadcast.Window, b.recordAck)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not reopen broadcast stream")
+		}
+		b.streams[i] = s
+	}
+	return b.streams[i], nil
+}
+
+// firstError returns the first error of errs, nil if there is none.
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func failed(err error) <-chan error {
+	result := make(chan error, 1)
+	result <- err
+	return result
+}
+
+// broadcastAll pipelines txs in order on a single stream of the orderer, so
+// that the txs of a bundle reach the orderer one after the other. It returns
+// the result of every tx once all are acknowledged.
+func (b *BroadcastClient) broadcastAll(txs types.Txs) []error {
+	start := time.Now()
+	results := make([]<-chan error, len(txs))
+	s, err := b.stream()
+	for i, tx := range txs {
+		env := &cb.Envelope{}
+		if err != nil {
+			results[i] = failed(err)
+		} else if err := proto.Unmarshal(tx, env); err != nil {
+			results[i] = failed(err)
+		} else {
+			results[i] = s.send(env)
+		}
+	}
+	errs := make([]error, len(txs))
+	for i, result := range results {
+		errs[i] = <-result
+	}
+	b.recordBatch(time.Since(start))
+	return errs
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	cb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
+	"github.com/stretchr/testify/assert"
+)
+
+// ackClient acknowledges every envelope sent with the next of its statuses.
+type ackClient struct {
+	ab.AtomicBroadcast_BroadcastClient
+	acks chan cb.Status
+}
+
+func (c *ackClient) Send(*cb.Envelope) error {
+	return nil
+}
+
+func (c *ackClient) Recv() (*ab.BroadcastResponse, error) {
+	status, ok := <-c.acks
+	if !ok {
+		return nil, io.EOF
+	}
+	return &ab.BroadcastResponse{Status: status}, nil
+}
+
+func newTestStream(orderer *BroadcastClient, acks chan cb.Status) *broadcastStream {
+	_, cancel := context.WithCancel(context.Background())
+	s := &broadcastStream{
+		client: &ackClient{acks: acks},
+		cancel: cancel,
+		window: make(chan struct{}, 4),
+		onAck:  orderer.recordAck,
+		done:   make(chan struct{}),
+	}
+	go s.recvLoop()
+	return s
+}
+
+func TestBroadcastStreamRejected(t *testing.T) {
+	orderer := &BroadcastClient{}
+	acks := make(chan cb.Status, 3)
+	s := newTestStream(orderer, acks)
+	defer s.close()
+
+	acks <- cb.Status_SUCCESS
+	acks <- cb.Status_BAD_REQUEST
+	acks <- cb.Status_SERVICE_UNAVAILABLE
+	var errs []error
+	for _, result := range []<-chan error{s.send(&cb.Envelope{}), s.send(&cb.Envelope{}), s.send(&cb.Envelope{})} {
+		select {
+		case err := <-result:
+			errs = append(errs, err)
+		case <-time.After(time.Second):
+			t.Fatal("not acknowledged")
+		}
+	}
+
+	// a status fails its envelope only, the stream goes on
+	assert.NoError(t, errs[0])
+	assert.True(t, isRejected(errs[1]))
+	assert.True(t, isStatusError(errs[2]))
+	assert.False(t, isRejected(errs[2]))
+	assert.False(t, s.broken())
+
+	// a rejected tx is no failure of the orderer
+	assert.Equal(t, 2, orderer.stats.acks)
+	assert.Equal(t, 1, orderer.stats.failures)
+
+	close(acks)
+	<-s.done
+	assert.False(t, isStatusError(s.brokenErr()))
+}
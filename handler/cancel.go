@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/bccsp/utils"
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// CancelTimeWindow is how far the timestamp of a cancel request may be off
+// the local clock.
+var CancelTimeWindow = 5 * time.Minute
+
+// CancelMessage returns the bytes the creator of a transaction signs to cancel it.
+func CancelMessage(txId string, timestamp int64) []byte {
+	return []byte(fmt.Sprintf("cancel %s %d", txId, timestamp))
+}
+
+// leases tracks the transactions fetched by an orderer which are still in
+// the mempool until their broadcast is done. Reaping and leasing, as well as
+// checking and cancelling, must happen under the lock.
+type leases struct {
+	sync.Mutex
+	txs map[[mempool.TxKeySize]byte]string // txKey -> orderer
+}
+
+func newLeases() *leases {
+	return &leases{txs: make(map[[mempool.TxKeySize]byte]string)}
+}
+
+func (l *leases) add(txs types.Txs, orderer string) {
+	for _, tx := range txs {
+		l.txs[mempool.TxKey(tx)] = orderer
+	}
+}
+
+// leased checks the tx of txKey is leased, the caller holds the lock.
+func (l *leases) leased(txKey [mempool.TxKeySize]byte) bool {
+	_, ok := l.txs[txKey]
+	return ok
+}
+
+func (l *leases) release(txs types.Txs) {
+	l.Lock()
+	defer l.Unlock()
+	for _, tx := range txs {
+		delete(l.txs, mempool.TxKey(tx))
+	}
+}
+
+// CancelTransaction removes a pending transaction on request of its creator.
+// A transaction already fetched by an orderer can't be cancelled any more.
+// Cancelling a transaction of a bundle cancels the whole bundle, so the
+// creator must have created all of its transactions.
+func (h *Handler) CancelTransaction(ctx context.Context, req *protos.CancelRequest) (*protos.CancelResponse, error) {
+	tx, ok := h.Mempool.TxByID(req.TxId)
+	if !ok {
+		return h.notFound(req.TxId), nil
+	}
+	if err := h.verifyCancel(tx, req, time.Now()); err != nil {
+		return nil, err
+	}
+
+	resp, err := h.replicate(ctx, &protos.RaftEntry{Type: protos.RaftEntryType_CANCEL, Cancel: req})
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*protos.CancelResponse), nil
+}
+
+// verifyCancel checks req is signed by the creator of tx, and of all the txs
+// of its bundle, as of now.
+func (h *Handler) verifyCancel(tx types.Tx, req *protos.CancelRequest, now time.Time) error {
+	if err := verifyCreator(tx, req, now); err != nil {
+		return err
+	}
+	for _, bundled := range h.Mempool.BundleTxs(req.TxId) {
+		creator, err := txCreator(bundled)
+		if err != nil || !bytes.Equal(creator, req.Creator) {
+			return errors.New("cancel request is not from the creator of every transaction of the bundle")
+		}
+	}
+	return nil
+}
+
+// cancel removes the pending transaction req cancels unless it is leased.
+// The request is verified again as of now, since it may come from another
+// replica.
+func (h *Handler) cancel(req *protos.CancelRequest, now time.Time) (*protos.CancelResponse, error) {
+	h.leases.Lock()
+	defer h.leases.Unlock()
+
+	txId := req.TxId
+	tx, ok := h.Mempool.TxByID(txId)
+	if !ok {
+		return h.notFound(txId), nil
+	}
+	if err := h.verifyCancel(tx, req, now); err != nil {
+		return nil, err
+	}
+	if orderer, ok := h.leases.txs[mempool.TxKey(tx)]; ok {
+		return &protos.CancelResponse{Result: protos.CancelResult_IN_FLIGHT, Orderer: orderer}, nil
+	}
+
+	// keep the tx in the cache, so the cancelled tx can't be submitted again
+	h.Mempool.RemoveTxByKey(mempool.TxKey(tx), false)
+	h.invocations.take(txId)
+	h.statuses.SetState(txId, TxCancelled)
+	logger.Info("Cancelled transaction on request of its creator", "txId", txId)
+
+	return &protos.CancelResponse{Result: protos.CancelResult_CANCELLED}, nil
+}
+
+func (h *Handler) notFound(txId string) *protos.CancelResponse {
+	resp := &protos.CancelResponse{Result: protos.CancelResult_NOT_FOUND}
+	if status := h.statuses.Get(txId); status != nil {
+		resp.State = string(status.State)
+	}
+	return resp
+}
+
+// verifyCreator checks the cancel request is signed by the creator of tx
+// within CancelTimeWindow of now.
+func verifyCreator(tx types.Tx, req *protos.CancelRequest, now time.Time) error {
+	signedAt := time.Unix(req.Timestamp, 0)
+	if since := now.Sub(signedAt); since > CancelTimeWindow || since < -CancelTimeWindow {
+		return errors.Errorf("cancel request timestamp %s out of window", signedAt.Format(time.RFC3339))
+	}
+	creator, err := txCreator(tx)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(creator, req.Creator) {
+		return errors.New("cancel request is not from the transaction creator")
+	}
+
+	id, err := protoutil.UnmarshalSerializedIdentity(req.Creator)
+	if err != nil {
+		return err
+	}
+	_, err = verifySignature(id.IdBytes, req.Signature, CancelMessage(req.TxId, req.Timestamp))
+	return errors.WithMessage(err, "invalid cancel request")
+}
+
+// txCreator returns the serialized identity of the creator of tx.
+func txCreator(tx types.Tx) ([]byte, error) {
+	env, err := protoutil.UnmarshalEnvelope(tx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := protoutil.UnmarshalPayload(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, errors.New("transaction header not set")
+	}
+	shdr, err := protoutil.UnmarshalSignatureHeader(payload.Header.SignatureHeader)
+	if err != nil {
+		return nil, err
+	}
+	return shdr.Creator, nil
+}
+
+// verifySignature checks signature is the one of the PEM encoded certificate
+// over message, it returns the certificate.
+func verifySignature(certPEM, signature, message []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not parse certificate")
+	}
+	pubKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not ECDSA")
+	}
+
+	r, s, err := utils.UnmarshalECDSASignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	if lowS, err := utils.IsLowS(pubKey, s); err != nil || !lowS {
+		return nil, errors.New("invalid signature")
+	}
+	if !ecdsa.Verify(pubKey, digest(message), r, s) {
+		return nil, errors.New("invalid signature")
+	}
+	return cert, nil
+}
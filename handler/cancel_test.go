@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/mempool/mock"
+	"github.com/tylerztl/fabric-mempool/protos"
+)
+
+// testCA issues the certificates of the test identities.
+type testCA struct {
+	key     *ecdsa.PrivateKey
+	cert    *x509.Certificate
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{key: key, cert: cert, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a signer of the MSP mspID with a certificate named cn.
+func (ca *testCA) issue(t *testing.T, mspID, cn string) *Crypto {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspID,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	require.NoError(t, err)
+	return &Crypto{Creator: creator, PrivKey: key, SignCert: cert}
+}
+
+// newCreatedTx returns the envelope of txId created by creator.
+func newCreatedTx(t *testing.T, txId string, creator *Crypto) types.Tx {
+	chdr, err := proto.Marshal(&cb.ChannelHeader{TxId: txId, FeeLimit: []byte("1")})
+	require.NoError(t, err)
+	shdr, err := proto.Marshal(&cb.SignatureHeader{Creator: creator.Creator})
+	require.NoError(t, err)
+	payload, err := proto.Marshal(&cb.Payload{Header: &cb.Header{ChannelHeader: chdr, SignatureHeader: shdr}})
+	require.NoError(t, err)
+	env, err := proto.Marshal(&cb.Envelope{Payload: payload})
+	require.NoError(t, err)
+	return env
+}
+
+func TestVerifyCreator(t *testing.T) {
+	ca := newTestCA(t)
+	creator, other := ca.issue(t, "Org1MSP", "user1"), ca.issue(t, "Org1MSP", "user2")
+	tx := newCreatedTx(t, "tx1", creator)
+
+	request := func(signer *Crypto, from *Crypto, signedAt time.Time) *protos.CancelRequest {
+		req := &protos.CancelRequest{TxId: "tx1", Timestamp: signedAt.Unix(), Creator: from.Creator}
+		signature, err := signer.Sign(CancelMessage(req.TxId, req.Timestamp))
+		require.NoError(t, err)
+		req.Signature = signature
+		return req
+	}
+
+	tests := []struct {
+		name string
+		req  *protos.CancelRequest
+		err  string
+	}{
+		{"creator", request(creator, creator, time.Now()), ""},
+		{"forged signature", request(other, creator, time.Now()), "invalid cancel request: invalid signature"},
+		{"wrong creator", request(other, other, time.Now()), "cancel request is not from the transaction creator"},
+		{"stale timestamp", request(creator, creator, time.Now().Add(-2*CancelTimeWindow)), "out of window"},
+		{"future timestamp", request(creator, creator, time.Now().Add(2*CancelTimeWindow)), "out of window"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyCreator(tx, tt.req, time.Now())
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+
+	req := request(creator, creator, time.Now())
+	req.TxId = "tx2"
+	assert.Error(t, verifyCreator(tx, req, time.Now()), "signature of another tx")
+}
+
+func TestCancelBundleOfOtherCreators(t *testing.T) {
+	ca := newTestCA(t)
+	creator, other := ca.issue(t, "Org1MSP", "user1"), ca.issue(t, "Org1MSP", "user2")
+	h := &Handler{Mempool: mock.NewMempool(cfg.DefaultMempoolConfig())}
+	bundle := types.Txs{newCreatedTx(t, "mine", creator), newCreatedTx(t, "theirs", other)}
+	require.NoError(t, h.Mempool.CheckBundle(bundle, 0, mempool.TxInfo{}))
+
+	req := &protos.CancelRequest{TxId: "mine", Timestamp: time.Now().Unix(), Creator: creator.Creator}
+	signature, err := creator.Sign(CancelMessage(req.TxId, req.Timestamp))
+	require.NoError(t, err)
+	req.Signature = signature
+	_, err = h.CancelTransaction(context.Background(), req)
+	assert.EqualError(t, err, "cancel request is not from the creator of every transaction of the bundle")
+	assert.Equal(t, 2, h.Mempool.Size())
+}
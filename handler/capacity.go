@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
+)
+
+// capacityStats are the observations of an orderer since the last capacity
+// decision.
+type capacityStats struct {
+	fetches     int
+	fullFetches int // fetches reaping as many txs as the capacity
+	acks        int
+	ackLatency  time.Duration // sum of the acks
+	batches     int
+	batchTime   time.Duration // sum of the broadcasts of whole fetches
+	failures    int
+	blockHeight uint64 // highest block height fetched for
+}
+
+// Capacity returns the number of txs the orderer fetches at once.
+func (b *BroadcastClient) Capacity() int {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	return b.capacity
+}
+
+func (b *BroadcastClient) setCapacity(capacity int) {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	b.capacity = capacity
+}
+
+// pinCapacity sets the capacity by hand, the controller leaves it alone
+// until it is unpinned.
+func (b *BroadcastClient) pinCapacity(capacity int) {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	b.capacity = capacity
+	b.pinned = true
+}
+
+func (b *BroadcastClient) unpinCapacity() {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	b.pinned = false
+}
+
+// CapacityPinned returns whether the capacity is set by hand.
+func (b *BroadcastClient) CapacityPinned() bool {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	return b.pinned
+}
+
+// CapacityDecision returns the last decision of the adaptive capacity
+// controller, nil if there is none.
+func (b *BroadcastClient) CapacityDecision() *conf.CapacityDecision {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	return b.decision
+}
+
+func (b *BroadcastClient) recordFetch(expectedTxs, actualTxs int, blockHeight uint64) {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	b.stats.fetches++
+	if actualTxs >= expectedTxs {
+		b.stats.fullFetches++
+	}
+	if blockHeight > b.stats.blockHeight {
+		b.stats.blockHeight = blockHeight
+	}
+}
+
+func (b *BroadcastClient) recordAck(start time.Time, err error) {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	// a rejected tx is no failure of the orderer
+	if err != nil && !isRejected(err) {
+		b.stats.failures++
+		return
+	}
+	b.stats.acks++
+	b.stats.ackLatency += time.Since(start)
+}
+
+func (b *BroadcastClient) recordBatch(elapsed time.Duration) {
+	b.statsMtx.Lock()
+	defer b.statsMtx.Unlock()
+	b.stats.batches++
+	b.stats.batchTime += elapsed
+}
+
+// CapacityController adjusts the capacity of every ready orderer once per
+// interval, within the configured bounds:
+//
+//   - broadcast failures halve it,
+//   - when broadcasting a fetch takes longer than the time between two fetches,
+//     it is lowered to what is broadcast in that time,
+//   - it is kept while the block height doesn't progress,
+//   - when every fetch reaps as many txs as the capacity, it grows by a
+//     quarter.
+//
+// A capacity set by hand through /capacity is pinned and left alone until it
+// is unpinned, the controller then decides from the observations made after
+// the unpin. A sanction of the accountability lowers the capacity even when
+// pinned, the controller raises it again only if it is not pinned.
+type CapacityController struct {
+	info    *conf.CapacityInfo
+	fetcher *TxsFetcher
+	quit    chan struct{}
+}
+
+func NewCapacityController(info *conf.CapacityInfo, fetcher *TxsFetcher) *CapacityController {
+	return &CapacityController{
+		info:    info,
+		fetcher: fetcher,
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start adjusts the capacities in the background until Stop is called.
+func (c *CapacityController) Start() {
+	go func() {
+		ticker := time.NewTicker(c.info.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.adjust()
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (c *CapacityController) Stop() {
+	close(c.quit)
+}
+
+func (c *CapacityController) adjust() {
+	for name, orderer := range c.fetcher.GetOrderers() {
+		if orderer.State() != protos.OrdererState_READY {
+			continue
+		}
+
+		orderer.statsMtx.Lock()
+		stats := orderer.stats
+		orderer.stats = capacityStats{blockHeight: stats.blockHeight}
+		if orderer.pinned {
+			orderer.statsMtx.Unlock()
+			continue
+		}
+		var lastHeight uint64
+		if orderer.decision != nil {
+			lastHeight = orderer.decision.BlockHeight
+		}
+		decision := c.decide(orderer.capacity, stats, lastHeight)
+		orderer.capacity = decision.To
+		orderer.decision = decision
+		orderer.statsMtx.Unlock()
+
+		if decision.From != decision.To {
+			logger.Info("Adjusted orderer capacity", "ordererName", name, "from", decision.From, "to", decision.To,
+				"reason", decision.Reason, "fetches", stats.fetches, "ackLatency", decision.AckLatency, "failures", stats.failures)
+		}
+	}
+}
+
+func (c *CapacityController) decide(capacity int, stats capacityStats, lastHeight uint64) *conf.CapacityDecision {
+	decision := &conf.CapacityDecision{
+		Time:        time.Now().Unix(),
+		From:        capacity,
+		Fetches:     stats.fetches,
+		FullFetches: stats.fullFetches,
+		Acks:        stats.acks,
+		Failures:    stats.failures,
+		BlockHeight: stats.blockHeight,
+	}
+	if stats.acks > 0 {
+		decision.AckLatency = (stats.ackLatency / time.Duration(stats.acks)).String()
+	}
+	// the acks of a fetch are pipelined, so it is broadcast in less than the
+	// sum of their latencies
+	var batchTime time.Duration
+	if stats.batches > 0 {
+		batchTime = stats.batchTime / time.Duration(stats.batches)
+		decision.BatchTime = batchTime.String()
+	}
+
+	switch {
+	case stats.failures > 0:
+		capacity /= 2
+		decision.Reason = "broadcast failures"
+	case stats.fetches > 0 && batchTime > c.info.Interval/time.Duration(stats.fetches):
+		fetchInterval := c.info.Interval / time.Duration(stats.fetches)
+		capacity = int(int64(capacity) * int64(fetchInterval) / int64(batchTime))
+		decision.Reason = "broadcasts slower than fetches"
+	case stats.fetches > 0 && lastHeight > 0 && stats.blockHeight <= lastHeight:
+		decision.Reason = "no block height progress"
+	case stats.fetches > 0 && stats.fullFetches == stats.fetches:
+		step := capacity / 4
+		if step < 1 {
+			step = 1
+		}
+		capacity += step
+		decision.Reason = "saturated fetches"
+	default:
+		decision.Reason = "steady"
+	}
+
+	if capacity < c.info.MinCapacity {
+		capacity = c.info.MinCapacity
+	}
+	if capacity > c.info.MaxCapacity {
+		capacity = c.info.MaxCapacity
+	}
+	decision.To = capacity
+	return decision
+}
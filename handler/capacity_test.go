@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
+)
+
+func newTestCapacityController(fetcher *TxsFetcher) *CapacityController {
+	return NewCapacityController(&conf.CapacityInfo{MinCapacity: 2, MaxCapacity: 100, Interval: 10 * time.Second}, fetcher)
+}
+
+func TestCapacityDecide(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		capacity   int
+		stats      capacityStats
+		lastHeight uint64
+		to         int
+		reason     string
+	}{
+		{
+			name:     "failures halve",
+			capacity: 40,
+			stats:    capacityStats{fetches: 5, fullFetches: 5, failures: 1, blockHeight: 11},
+			to:       20,
+			reason:   "broadcast failures",
+		},
+		{
+			name:     "failures floor at min",
+			capacity: 3,
+			stats:    capacityStats{failures: 2},
+			to:       2,
+			reason:   "broadcast failures",
+		},
+		{
+			// a fetch every second broadcast in two
+			name:     "slow broadcasts",
+			capacity: 40,
+			stats:    capacityStats{fetches: 10, fullFetches: 10, batches: 2, batchTime: 4 * time.Second},
+			to:       20,
+			reason:   "broadcasts slower than fetches",
+		},
+		{
+			name:       "no block height progress",
+			capacity:   40,
+			stats:      capacityStats{fetches: 5, fullFetches: 5, blockHeight: 10},
+			lastHeight: 10,
+			to:         40,
+			reason:     "no block height progress",
+		},
+		{
+			name:       "saturated grow by a quarter",
+			capacity:   40,
+			stats:      capacityStats{fetches: 5, fullFetches: 5, blockHeight: 11},
+			lastHeight: 10,
+			to:         50,
+			reason:     "saturated fetches",
+		},
+		{
+			name:     "saturated first decision",
+			capacity: 2,
+			stats:    capacityStats{fetches: 1, fullFetches: 1},
+			to:       3,
+			reason:   "saturated fetches",
+		},
+		{
+			name:     "saturated capped at max",
+			capacity: 90,
+			stats:    capacityStats{fetches: 5, fullFetches: 5, blockHeight: 11},
+			to:       100,
+			reason:   "saturated fetches",
+		},
+		{
+			name:     "partial fetches",
+			capacity: 40,
+			stats:    capacityStats{fetches: 5, fullFetches: 3, blockHeight: 11},
+			to:       40,
+			reason:   "steady",
+		},
+		{
+			name:     "no fetches raised to min",
+			capacity: 1,
+			to:       2,
+			reason:   "steady",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decision := newTestCapacityController(nil).decide(tc.capacity, tc.stats, tc.lastHeight)
+			assert.Equal(t, tc.capacity, decision.From)
+			assert.Equal(t, tc.to, decision.To)
+			assert.Equal(t, tc.reason, decision.Reason)
+		})
+	}
+}
+
+func TestCapacityAdjustSkipsPinned(t *testing.T) {
+	saturated := capacityStats{fetches: 5, fullFetches: 5, blockHeight: 11}
+	orderer := &BroadcastClient{state: protos.OrdererState_READY, capacity: 40, stats: saturated}
+	c := newTestCapacityController(&TxsFetcher{clients: map[string]*BroadcastClient{"orderer0": orderer}})
+
+	orderer.pinCapacity(10)
+	c.adjust()
+	assert.Equal(t, 10, orderer.Capacity())
+	assert.Nil(t, orderer.CapacityDecision())
+
+	// the observations made while pinned are not decided from
+	orderer.unpinCapacity()
+	c.adjust()
+	assert.Equal(t, 10, orderer.Capacity())
+	assert.Equal(t, "steady", orderer.CapacityDecision().Reason)
+
+	orderer.recordFetch(10, 10, 12)
+	c.adjust()
+	assert.Equal(t, 12, orderer.Capacity())
+	assert.False(t, orderer.CapacityPinned())
+}
@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// connect connects the orderer, retrying with a jittered exponential backoff
+// until it succeeds or the orderer is removed.
+func (b *BroadcastClient) connect() {
+	for attempt := 0; ; attempt++ {
+		err := b.resetConnect()
+		if err == nil {
+			b.stateMtx.Lock()
+			if b.state == protos.OrdererState_CONNECTING {
+				b.state = protos.OrdererState_READY
+			}
+			b.connecting = false
+			close(b.connected)
+			b.stateMtx.Unlock()
+			return
+		}
+
+		b.stateMtx.Lock()
+		b.lastErr = err
+		b.stateMtx.Unlock()
+		wait := reconnectBackoff(attempt)
+		logger.Info("Retrying to connect orderer service", "ordererName", b.name, "ordererAddr", b.serverAddr, "retryIn", wait)
+		select {
+		case <-b.stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reconnectBackoff doubles the wait from ReconnectBackoff up to
+// MaxReconnectBackoff, with a jitter of 20% so that the orderers lost at
+// once aren't all redialed in lockstep.
+func reconnectBackoff(attempt int) time.Duration {
+	wait := ReconnectBackoff
+	for i := 0; i < attempt && wait < MaxReconnectBackoff; i++ {
+		wait *= 2
+	}
+	if wait > MaxReconnectBackoff {
+		wait = MaxReconnectBackoff
+	}
+	return wait + time.Duration((rand.Float64()*0.4-0.2)*float64(wait))
+}
+
+// reconnect connects the orderer again in the background, unless it is
+// already being connected.
+func (b *BroadcastClient) reconnect(cause error) {
+	b.stateMtx.Lock()
+	if b.connecting || b.state == protos.OrdererState_REMOVED {
+		b.stateMtx.Unlock()
+		return
+	}
+	b.connecting = true
+	b.connected = make(chan struct{})
+	b.reconnects++
+	b.lastErr = cause
+	b.stateMtx.Unlock()
+
+	logger.Error("Reconnecting orderer service", "ordererName", b.name, "ordererAddr", b.serverAddr, "error", cause)
+	go b.connect()
+}
+
+// awaitReconnect reconnects the orderer after cause, unless it is already
+// being connected, and waits up to timeout for it to be connected again.
+func (b *BroadcastClient) awaitReconnect(cause error, timeout time.Duration) error {
+	b.reconnect(cause)
+	b.stateMtx.Lock()
+	connected := b.connected
+	b.stateMtx.Unlock()
+
+	select {
+	case <-connected:
+		return nil
+	case <-b.stop:
+		return errors.Errorf("orderer %s is removed", b.name)
+	case <-time.After(timeout):
+		return errors.Errorf("orderer %s is not connected again within %s", b.name, timeout)
+	}
+}
+
+// monitor follows the connectivity state of conn until it is replaced or
+// closed, and reconnects the orderer once conn fails.
+func (b *BroadcastClient) monitor(conn *grpc.ClientConn) {
+	for {
+		state := conn.GetState()
+		b.mutex.Lock()
+		current := b.conn == conn
+		b.mutex.Unlock()
+		if !current {
+			return
+		}
+
+		b.stateMtx.Lock()
+		b.health = state
+		b.stateMtx.Unlock()
+		switch state {
+		case connectivity.Shutdown:
+			return
+		case connectivity.TransientFailure:
+			b.reconnect(errors.New("connection failed"))
+			return
+		}
+		if !conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+	}
+}
+
+// Health returns the connectivity state of the connection of the orderer,
+// the number of times it was reconnected and the last connection error.
+func (b *BroadcastClient) Health() (connectivity.State, int, error) {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	return b.health, b.reconnects, b.lastErr
+}
+
+// resetConnect dials the orderer and opens its broadcast streams, replacing
+// the previous connection.
+func (b *BroadcastClient) resetConnect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), ConnTimeout)
+	defer cancel()
+
+	ordererConn, err := grpc.DialContext(ctx, b.serverAddr, b.dialOpts...)
+	if err != nil {
+		logger.Error("Error connecting (grpc) to orderer service", "ordererAddr", b.serverAddr, "error", err)
+		return err
+	}
+	streams, err := b.openStreams(ordererConn)
+	if err != nil {
+		logger.Error("Error creating broadcast client for orderer service", "ordererAddr", b.serverAddr, "error", err)
+		ordererConn.Close()
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	select {
+	case <-b.stop:
+		for _, s := range streams {
+			s.close()
+		}
+		ordererConn.Close()
+		return errors.Errorf("orderer %s is removed", b.name)
+	default:
+	}
+	for _, s := range b.streams {
+		s.close()
+	}
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	b.conn = ordererConn
+	b.streams = streams
+	go b.monitor(ordererConn)
+	logger.Info("Connected orderer service", "ordererAddr", b.serverAddr)
+	return nil
+}
+
+// close stops connecting the orderer and closes its connection.
+func (b *BroadcastClient) close() {
+	b.stateMtx.Lock()
+	if b.state == protos.OrdererState_REMOVED {
+		b.stateMtx.Unlock()
+		return
+	}
+	b.state = protos.OrdererState_REMOVED
+	b.stateMtx.Unlock()
+
+	close(b.stop)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, s := range b.streams {
+		s.close()
+	}
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
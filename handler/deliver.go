@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"time"
+
+	cb "github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric/protos/orderer"
+	pbpeer "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// ReconnectInterval is the wait before reopening a broken deliver stream.
+var ReconnectInterval = 5 * time.Second
+
+// CommitFunc receives the validation result of every committed transaction.
+type CommitFunc func(txId string, blockNum uint64, code pbpeer.TxValidationCode)
+
+// CommitListener follows the filtered blocks committed by the peer on a channel.
+type CommitListener struct {
+	channelID string
+	deliver   pbpeer.DeliverClient
+	signer    *Crypto
+	onCommit  CommitFunc
+}
+
+func CreateDeliverClient(node *conf.PeerInfo) (pbpeer.DeliverClient, error) {
+	conn, err := DailConnection(node)
+	if err != nil {
+		return nil, err
+	}
+	return pbpeer.NewDeliverClient(conn), nil
+}
+
+func NewCommitListener(channelID string, deliver pbpeer.DeliverClient, signer *Crypto, onCommit CommitFunc) *CommitListener {
+	return &CommitListener{
+		channelID: channelID,
+		deliver:   deliver,
+		signer:    signer,
+		onCommit:  onCommit,
+	}
+}
+
+// Start follows the channel until ctx is done, reconnecting on errors.
+func (l *CommitListener) Start(ctx context.Context) {
+	for {
+		err := l.listen(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		logger.Error("deliver stream broken, reconnecting", "channel", l.channelID, "error", err)
+		time.Sleep(ReconnectInterval)
+	}
+}
+
+func (l *CommitListener) listen(ctx context.Context) error {
+	stream, err := l.deliver.DeliverFiltered(ctx)
+	if err != nil {
+		return errors.WithMessage(err, "could not open deliver stream")
+	}
+
+	seekInfo := &ab.SeekInfo{
+		Start:    &ab.SeekPosition{Type: &ab.SeekPosition_Newest{Newest: &ab.SeekNewest{}}},
+		Stop:     &ab.SeekPosition{Type: &ab.SeekPosition_Specified{Specified: &ab.SeekSpecified{Number: math.MaxUint64}}},
+		Behavior: ab.SeekInfo_BLOCK_UNTIL_READY,
+	}
+	env, err := protoutil.CreateSignedEnvelope(cb.HeaderType_DELIVER_SEEK_INFO, l.channelID, l.signer, seekInfo, 0, 0)
+	if err != nil {
+		return errors.WithMessage(err, "could not create seek envelope")
+	}
+	if err := stream.Send(env); err != nil {
+		return errors.WithMessage(err, "could not send seek envelope")
+	}
+	logger.Info("Listening committed blocks", "channel", l.channelID)
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		switch t := resp.Type.(type) {
+		case *pbpeer.DeliverResponse_FilteredBlock:
+			for _, tx := range t.FilteredBlock.FilteredTransactions {
+				l.onCommit(tx.Txid, t.FilteredBlock.Number, tx.TxValidationCode)
+			}
+		case *pbpeer.DeliverResponse_Status:
+			return errors.Errorf("deliver stream closed with status: %s", t.Status)
+		}
+	}
+}
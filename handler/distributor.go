@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+)
+
+// Distributor shares the tax of the txs fetched by an orderer between the
+// orderers.
+type Distributor interface {
+	// Distribute returns the share of tax of every orderer, the shares add
+	// up to tax.
+	Distribute(tax *big.Int, fetcher *BroadcastClient, orderers map[string]*BroadcastClient) map[*BroadcastClient]*big.Int
+}
+
+// DistributorFactory creates a distribution strategy from its settings.
+type DistributorFactory func(config *conf.DistributeConfig) (Distributor, error)
+
+var (
+	distributorsMtx sync.RWMutex
+	distributors    = map[string]DistributorFactory{
+		conf.DistributeByEffort: func(*conf.DistributeConfig) (Distributor, error) {
+			return effortDistributor{}, nil
+		},
+		conf.DistributeEqually: func(*conf.DistributeConfig) (Distributor, error) {
+			return weightedDistributor(func(*BroadcastClient) int64 { return 1 }), nil
+		},
+		conf.DistributeByCapacity: func(*conf.DistributeConfig) (Distributor, error) {
+			return weightedDistributor(func(b *BroadcastClient) int64 { return int64(b.Capacity()) }), nil
+		},
+		conf.DistributeByUptime: func(*conf.DistributeConfig) (Distributor, error) {
+			return weightedDistributor(func(b *BroadcastClient) int64 { return time.Now().Unix() - b.joinTime }), nil
+		},
+		conf.DistributeByStake: func(config *conf.DistributeConfig) (Distributor, error) {
+			stakes := make(map[string]int64, len(config.Stakes))
+			for orderer, stake := range config.Stakes {
+				stakes[orderer] = stake
+			}
+			return weightedDistributor(func(b *BroadcastClient) int64 { return stakes[b.name] }), nil
+		},
+	}
+)
+
+// RegisterDistributor makes a distribution strategy selectable by name,
+// replacing the strategy of that name if any.
+func RegisterDistributor(name string, factory DistributorFactory) {
+	distributorsMtx.Lock()
+	defer distributorsMtx.Unlock()
+	distributors[name] = factory
+}
+
+// NewDistributor creates the distribution strategy selected by config.
+func NewDistributor(config *conf.DistributeConfig) (Distributor, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	distributorsMtx.RLock()
+	factory, ok := distributors[config.StrategyName()]
+	distributorsMtx.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown distribution strategy %s", config.StrategyName())
+	}
+	return factory(config)
+}
+
+// effortDistributor feeds all tax to the orderer which fetched the txs.
+type effortDistributor struct{}
+
+func (effortDistributor) Distribute(tax *big.Int, fetcher *BroadcastClient, _ map[string]*BroadcastClient) map[*BroadcastClient]*big.Int {
+	return map[*BroadcastClient]*big.Int{fetcher: new(big.Int).Set(tax)}
+}
+
+// weightedDistributor shares the tax in proportion to the weight of every
+// orderer. The remainder of the division goes to the orderer which fetched
+// the txs if it has weight, to the heaviest orderer otherwise, and all tax
+// goes to the orderer which fetched the txs when no orderer has weight.
+type weightedDistributor func(orderer *BroadcastClient) int64
+
+func (weight weightedDistributor) Distribute(tax *big.Int, fetcher *BroadcastClient, orderers map[string]*BroadcastClient) map[*BroadcastClient]*big.Int {
+	weights := make(map[*BroadcastClient]*big.Int, len(orderers))
+	total := new(big.Int)
+	// the heaviest orderer, by name among equals so the shares don't depend
+	// on the map order
+	var heaviest *BroadcastClient
+	var heaviestName string
+	for name, orderer := range orderers {
+		w := weight(orderer)
+		if w <= 0 {
+			continue
+		}
+		weights[orderer] = big.NewInt(w)
+		total.Add(total, weights[orderer])
+		if heaviest == nil || weights[orderer].Cmp(weights[heaviest]) > 0 ||
+			(weights[orderer].Cmp(weights[heaviest]) == 0 && name < heaviestName) {
+			heaviest, heaviestName = orderer, name
+		}
+	}
+	if total.Sign() == 0 {
+		return map[*BroadcastClient]*big.Int{fetcher: new(big.Int).Set(tax)}
+	}
+
+	shares := make(map[*BroadcastClient]*big.Int, len(weights))
+	less := new(big.Int).Set(tax)
+	for orderer, w := range weights {
+		share := new(big.Int).Div(new(big.Int).Mul(tax, w), total)
+		shares[orderer] = share
+		less.Sub(less, share)
+	}
+	if share, ok := shares[fetcher]; ok {
+		share.Add(share, less)
+	} else {
+		shares[heaviest].Add(shares[heaviest], less)
+	}
+	return shares
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tylerztl/fabric-mempool/conf"
+)
+
+func newTestOrderers(names ...string) map[string]*BroadcastClient {
+	orderers := make(map[string]*BroadcastClient, len(names))
+	for _, name := range names {
+		orderers[name] = &BroadcastClient{name: name, capacity: 1, joinTime: time.Now().Unix()}
+	}
+	return orderers
+}
+
+// shares returns the shares of distributing tax by name, they must add up to
+// tax.
+func shares(t *testing.T, config *conf.DistributeConfig, tax int64, fetcher string, orderers map[string]*BroadcastClient) map[string]int64 {
+	distributor, err := NewDistributor(config)
+	require.NoError(t, err)
+	sum := new(big.Int)
+	byName := make(map[string]int64)
+	for orderer, share := range distributor.Distribute(big.NewInt(tax), orderers[fetcher], orderers) {
+		byName[orderer.name] = share.Int64()
+		sum.Add(sum, share)
+	}
+	assert.Equal(t, tax, sum.Int64(), "shares don't add up to the tax")
+	return byName
+}
+
+func TestDistributeByEffort(t *testing.T) {
+	orderers := newTestOrderers("orderer0", "orderer1")
+	assert.Equal(t, map[string]int64{"orderer1": 10},
+		shares(t, &conf.DistributeConfig{Strategy: conf.DistributeByEffort}, 10, "orderer1", orderers))
+}
+
+func TestDistributeEqually(t *testing.T) {
+	orderers := newTestOrderers("orderer0", "orderer1", "orderer2")
+	// the remainder goes to the fetcher
+	assert.Equal(t, map[string]int64{"orderer0": 3, "orderer1": 4, "orderer2": 3},
+		shares(t, &conf.DistributeConfig{Strategy: conf.DistributeEqually}, 10, "orderer1", orderers))
+	// the legacy distribution type
+	assert.Equal(t, map[string]int64{"orderer0": 3, "orderer1": 3, "orderer2": 4},
+		shares(t, &conf.DistributeConfig{DistributionType: 1}, 10, "orderer2", orderers))
+}
+
+func TestDistributeByCapacity(t *testing.T) {
+	orderers := newTestOrderers("orderer0", "orderer1", "orderer2")
+	orderers["orderer0"].capacity = 1
+	orderers["orderer1"].capacity = 3
+	orderers["orderer2"].capacity = 0
+	assert.Equal(t, map[string]int64{"orderer0": 3, "orderer1": 7},
+		shares(t, &conf.DistributeConfig{Strategy: conf.DistributeByCapacity}, 10, "orderer0", orderers))
+}
+
+func TestDistributeByUptime(t *testing.T) {
+	orderers := newTestOrderers("orderer0", "orderer1")
+	now := time.Now().Unix()
+	orderers["orderer0"].joinTime = now - 100
+	orderers["orderer1"].joinTime = now - 300
+	assert.Equal(t, map[string]int64{"orderer0": 1, "orderer1": 3},
+		shares(t, &conf.DistributeConfig{Strategy: conf.DistributeByUptime}, 4, "orderer1", orderers))
+}
+
+func TestDistributeByStake(t *testing.T) {
+	orderers := newTestOrderers("orderer0", "orderer1", "orderer2")
+	config := &conf.DistributeConfig{Strategy: conf.DistributeByStake, Stakes: map[string]int64{"orderer0": 1, "orderer1": 2}}
+	assert.Equal(t, map[string]int64{"orderer0": 4, "orderer1": 6},
+		shares(t, config, 10, "orderer0", orderers))
+
+	// a fetcher without stake gets nothing, the remainder goes to the
+	// heaviest orderer
+	assert.Equal(t, map[string]int64{"orderer0": 3, "orderer1": 7},
+		shares(t, config, 10, "orderer2", orderers))
+
+	// among equals to the first by name
+	config.Stakes = map[string]int64{"orderer0": 1, "orderer1": 1}
+	assert.Equal(t, map[string]int64{"orderer0": 2, "orderer1": 1},
+		shares(t, config, 3, "orderer2", orderers))
+
+	// with no stake among the orderers, all goes to the fetcher
+	config.Stakes = map[string]int64{"orderer9": 1}
+	assert.Equal(t, map[string]int64{"orderer2": 10},
+		shares(t, config, 10, "orderer2", orderers))
+}
+
+func TestNewDistributor(t *testing.T) {
+	_, err := NewDistributor(&conf.DistributeConfig{Strategy: "unknown"})
+	assert.EqualError(t, err, "unknown distribution strategy unknown")
+	_, err = NewDistributor(&conf.DistributeConfig{Strategy: conf.DistributeByStake})
+	assert.Error(t, err)
+
+	RegisterDistributor("test", func(*conf.DistributeConfig) (Distributor, error) {
+		return effortDistributor{}, nil
+	})
+	distributor, err := NewDistributor(&conf.DistributeConfig{Strategy: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, effortDistributor{}, distributor)
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata keys of the signature of a fetch request.
+const (
+	FetchCreatorKey   = "fetch-creator-bin"
+	FetchSignatureKey = "fetch-signature-bin"
+	FetchTimestampKey = "fetch-timestamp"
+)
+
+// FetchMessage returns the bytes an orderer signs to fetch txs, timestamp is
+// in unix nanoseconds.
+func FetchMessage(requester string, blockHeight uint64, timestamp int64) []byte {
+	return []byte(fmt.Sprintf("fetch %s %d %d", requester, blockHeight, timestamp))
+}
+
+// SignFetchRequest attaches the signature of signer over ftx to the outgoing
+// metadata of ctx.
+func SignFetchRequest(ctx context.Context, signer *Crypto, ftx *pb.FetchTxsRequest) (context.Context, error) {
+	timestamp := time.Now().UnixNano()
+	signature, err := signer.Sign(FetchMessage(ftx.Requester, ftx.BlockHeight, timestamp))
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx,
+		FetchCreatorKey, string(signer.Creator),
+		FetchSignatureKey, string(signature),
+		FetchTimestampKey, strconv.FormatInt(timestamp, 10),
+	), nil
+}
+
+// forwardFetchSignature passes the signature of an incoming fetch request on
+// to the outgoing requests of ctx.
+func forwardFetchSignature(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	for _, key := range []string{FetchCreatorKey, FetchSignatureKey, FetchTimestampKey} {
+		for _, v := range md.Get(key) {
+			ctx = metadata.AppendToOutgoingContext(ctx, key, v)
+		}
+	}
+	return ctx
+}
+
+// fetchAuth authenticates the fetch requests of the orderers.
+type fetchAuth struct {
+	info  *conf.FetchAuthInfo
+	roots *x509.CertPool
+}
+
+func newFetchAuth(info *conf.FetchAuthInfo) (*fetchAuth, error) {
+	roots, err := loadCACerts(info.CACerts, "ordererOrganizations/example.com/msp/cacerts/*")
+	if err != nil {
+		return nil, err
+	}
+	return &fetchAuth{info: info, roots: roots}, nil
+}
+
+// loadCACerts returns the pool of the PEM certificates of files, or of the
+// crypto-config files matching pattern if there are none.
+func loadCACerts(files []string, pattern string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		fpath := conf.GetCryptoConfigPath(pattern)
+		matches, err := filepath.Glob(fpath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot find filepath %s", fpath)
+		} else if len(matches) == 0 {
+			return nil, errors.Errorf("no CA cert filepath name matches: %s", fpath)
+		}
+		files = matches
+	}
+
+	roots := x509.NewCertPool()
+	for _, file := range files {
+		in, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error loading %s", file)
+		}
+		if !roots.AppendCertsFromPEM(in) {
+			return nil, errors.Errorf("could not parse CA cert %s", file)
+		}
+	}
+	return roots, nil
+}
+
+// authenticate checks the fetch request is signed by a member of the orderer
+// MSP whose certificate has the identity of orderer, and uses it up.
+func (a *fetchAuth) authenticate(ctx context.Context, ftx *pb.FetchTxsRequest, orderer *BroadcastClient) error {
+	timestamp, err := a.verify(ctx, ftx, orderer)
+	if err != nil {
+		return err
+	}
+	return orderer.signed(timestamp, a.info.TimeWindow)
+}
+
+// verify checks the fetch request like authenticate, without using it up.
+// It returns the timestamp of the signature.
+func (a *fetchAuth) verify(ctx context.Context, ftx *pb.FetchTxsRequest, orderer *BroadcastClient) (int64, error) {
+	keys := signatureKeys{FetchCreatorKey, FetchSignatureKey, FetchTimestampKey}
+	cert, timestamp, err := keys.verify(ctx, "fetch request", a.info.MSPID, a.roots, a.info.TimeWindow, func(timestamp int64) []byte {
+		return FetchMessage(ftx.Requester, ftx.BlockHeight, timestamp)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if cert.Subject.CommonName != orderer.identity {
+		return 0, errors.Errorf("fetch request signed by %s, not by orderer %s", cert.Subject.CommonName, orderer.name)
+	}
+	if orderer.replayed(timestamp) {
+		return 0, errors.Errorf("replayed fetch request of orderer %s", orderer.name)
+	}
+	return timestamp, nil
+}
+
+// signatureKeys are the metadata keys of the creator, the signature and the
+// timestamp of a signed request.
+type signatureKeys struct {
+	creator, signature, timestamp string
+}
+
+// verify checks the request of ctx is signed within window of the local
+// clock by a member of the MSP mspID, whose certificate chains to roots.
+// message returns the bytes signed at a timestamp. It returns the
+// certificate and the timestamp of the signature.
+func (k signatureKeys) verify(ctx context.Context, what, mspID string, roots *x509.CertPool, window time.Duration,
+	message func(timestamp int64) []byte) (*x509.Certificate, int64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	creator, signature := get(k.creator), get(k.signature)
+	if creator == "" || signature == "" {
+		return nil, 0, errors.Errorf("%s is not signed", what)
+	}
+	timestamp, err := strconv.ParseInt(get(k.timestamp), 10, 64)
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "invalid %s timestamp", what)
+	}
+	signedAt := time.Unix(0, timestamp)
+	if since := time.Since(signedAt); since > window || since < -window {
+		return nil, 0, errors.Errorf("%s timestamp %s out of window", what, signedAt.Format(time.RFC3339))
+	}
+
+	id, err := protoutil.UnmarshalSerializedIdentity([]byte(creator))
+	if err != nil {
+		return nil, 0, err
+	}
+	if id.Mspid != mspID {
+		return nil, 0, errors.Errorf("%s signer is not a member of %s", what, mspID)
+	}
+	cert, err := verifySignature(id.IdBytes, []byte(signature), message(timestamp))
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "invalid %s", what)
+	}
+	opts := x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	if _, err := cert.Verify(opts); err != nil {
+		return nil, 0, errors.WithMessagef(err, "%s signer is not a member of %s", what, mspID)
+	}
+	return cert, timestamp, nil
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"google.golang.org/grpc/metadata"
+)
+
+// signedFetch returns the incoming context of ftx signed by signer.
+func signedFetch(t *testing.T, signer *Crypto, ftx *pb.FetchTxsRequest) context.Context {
+	ctx, err := SignFetchRequest(context.Background(), signer, ftx)
+	require.NoError(t, err)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestFetchAuthAuthenticate(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	auth := &fetchAuth{info: &conf.FetchAuthInfo{MSPID: "OrdererMSP", TimeWindow: conf.DefaultFetchAuthInfo().TimeWindow}, roots: roots}
+	ftx := &pb.FetchTxsRequest{Requester: "orderer0", BlockHeight: 7}
+
+	cases := []struct {
+		name   string
+		signer *Crypto
+		err    string
+	}{
+		{"orderer", ca.issue(t, "OrdererMSP", "orderer0.example.com"), ""},
+		{"wrong msp", ca.issue(t, "Org1MSP", "orderer0.example.com"), "not a member of OrdererMSP"},
+		{"other orderer", ca.issue(t, "OrdererMSP", "orderer1.example.com"), "not by orderer orderer0"},
+		{"untrusted ca", newTestCA(t).issue(t, "OrdererMSP", "orderer0.example.com"), "not a member of OrdererMSP"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			orderer := &BroadcastClient{name: "orderer0", identity: "orderer0.example.com"}
+			err := auth.authenticate(signedFetch(t, c.signer, ftx), ftx, orderer)
+			if c.err == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.err)
+			}
+		})
+	}
+
+	t.Run("replayed", func(t *testing.T) {
+		orderer := &BroadcastClient{name: "orderer0", identity: "orderer0.example.com"}
+		ctx := signedFetch(t, ca.issue(t, "OrdererMSP", "orderer0.example.com"), ftx)
+		_, err := auth.verify(ctx, ftx, orderer)
+		require.NoError(t, err, "verifying leaves the request to be used")
+		require.NoError(t, auth.authenticate(ctx, ftx, orderer))
+		err = auth.authenticate(ctx, ftx, orderer)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "replayed")
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		orderer := &BroadcastClient{name: "orderer0", identity: "orderer0.example.com"}
+		signer := ca.issue(t, "OrdererMSP", "orderer0.example.com")
+		first := signedFetch(t, signer, ftx)
+		second := signedFetch(t, signer, ftx)
+		require.NoError(t, auth.authenticate(second, ftx, orderer))
+		require.NoError(t, auth.authenticate(first, ftx, orderer), "concurrent fetches may arrive in any order")
+		assert.Error(t, auth.authenticate(first, ftx, orderer))
+	})
+
+	t.Run("other block height", func(t *testing.T) {
+		orderer := &BroadcastClient{name: "orderer0", identity: "orderer0.example.com"}
+		ctx := signedFetch(t, ca.issue(t, "OrdererMSP", "orderer0.example.com"), ftx)
+		err := auth.authenticate(ctx, &pb.FetchTxsRequest{Requester: "orderer0", BlockHeight: 8}, orderer)
+		assert.Error(t, err)
+	})
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"math/big"
 	"os"
@@ -11,131 +12,226 @@ import (
 	"sync"
 	"time"
 
-	"github.com/gogo/protobuf/proto"
-	cb "github.com/hyperledger/fabric/protos/common"
-	ab "github.com/hyperledger/fabric/protos/orderer"
 	"github.com/pkg/errors"
 	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 var (
 	MaxGrpcMsgSize         = 1000 * 1024 * 1024
 	ConnTimeout            = 30 * time.Second
 	DefaultOrdererCapacity = 10
+	KeepaliveTime          = time.Minute
+	KeepaliveTimeout       = 20 * time.Second
+	ReconnectBackoff       = time.Second
+	MaxReconnectBackoff    = time.Minute
 	AppConf                = conf.GetAppConf().Conf
 	logger                 = log.NewTMLogger(log.NewSyncWriter(os.Stdout)).With("module", "fetcher")
+
+	errOrdererDraining = errors.New("orderer is draining")
 )
 
 type TxsFetcher struct {
+	config  *conf.DistributeConfig
+	mutex   sync.RWMutex
 	clients map[string]*BroadcastClient
 }
 
+// NewTxsFetcher connects the orderers of the application settings. The
+// orderers which are unreachable are retried in the background.
 func NewTxsFetcher(config *conf.DistributeConfig) *TxsFetcher {
 	runtime.GOMAXPROCS(AppConf.CPUs)
 
 	if len(AppConf.Orderers) == 0 {
-		panic(" Cannot find connect orderers config")
+		logger.Info("No orderers configured, waiting for orderers to be added")
 	}
-	fpath := conf.GetCryptoConfigPath(fmt.Sprintf("ordererOrganizations/example.com/orderers/%s"+"*", AppConf.Orderers[0].Host))
-	matches, err := filepath.Glob(fpath)
-	if err != nil {
-		panic(fmt.Sprintf("Cannot find filepath %s ; err: %v\n", fpath, err))
-	} else if len(matches) != 1 {
-		panic(fmt.Sprintf("No msp directory filepath name matches: %s\n", fpath))
+	t := &TxsFetcher{
+		config:  config,
+		clients: make(map[string]*BroadcastClient),
 	}
-
-	return &TxsFetcher{
-		getOrderers(config),
+	for _, orderer := range AppConf.Orderers {
+		tlsCACert, err := GetTLSCACerts(orderer.TLSCACert)
+		if err != nil {
+			panic(fmt.Sprintf("Error loading TLS CA cert of orderer %s, err: %v", orderer.Name, err))
+		}
+		if _, err := t.AddOrderer(orderer, tlsCACert, DefaultOrdererCapacity); err != nil {
+			panic(fmt.Sprintf("Error adding orderer %s, err: %v", orderer.Name, err))
+		}
 	}
+	return t
 }
 
 func (t *TxsFetcher) GetOrderer(name string) *BroadcastClient {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 	client, _ := t.clients[name]
 	return client
 }
 
 // GetOrderers return all clients in fetcher
 func (t *TxsFetcher) GetOrderers() map[string]*BroadcastClient {
-	return t.clients
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	clients := make(map[string]*BroadcastClient, len(t.clients))
+	for name, client := range t.clients {
+		clients[name] = client
+	}
+	return clients
 }
 
-func getOrderers(config *conf.DistributeConfig) map[string]*BroadcastClient {
-	ordererClients := make(map[string]*BroadcastClient)
-	for _, orderer := range AppConf.Orderers {
-		var serverAddr string
-		if AppConf.Local {
-			serverAddr = fmt.Sprintf("localhost:%d", orderer.Port)
-		} else {
-			serverAddr = fmt.Sprintf("%s:%d", orderer.Host, orderer.Port)
-		}
+// AddOrderer adds an orderer and connects it in the background, retrying
+// with a backoff until it is reachable. TLS is used with the
+// PEM encoded tlsCACert if set, or else with the TLS root certificate of
+// the crypto-config directory when tlsEnabled is set.
+func (t *TxsFetcher) AddOrderer(orderer *conf.OrdererInfo, tlsCACert []byte, capacity int) (*BroadcastClient, error) {
+	if orderer.Name == "" || orderer.Host == "" || orderer.Port == 0 {
+		return nil, errors.New("orderer name, host and port are required")
+	}
+	dialOpts, err := dialOptions(orderer, tlsCACert)
+	if err != nil {
+		return nil, err
+	}
+	var serverAddr string
+	if AppConf.Local {
+		serverAddr = fmt.Sprintf("localhost:%d", orderer.Port)
+	} else {
+		serverAddr = fmt.Sprintf("%s:%d", orderer.Host, orderer.Port)
+	}
 
-		fpath := conf.GetCryptoConfigPath(fmt.Sprintf("ordererOrganizations/example.com/orderers/%s"+"*", orderer.Host))
-		matches, err := filepath.Glob(fpath)
-		if err != nil {
-			panic(fmt.Sprintf("Cannot find filepath %s ; err: %v\n", fpath, err))
-		} else if len(matches) != 1 {
-			panic(fmt.Sprintf("No msp directory filepath name matches: %s\n", fpath))
-		}
+	identity := orderer.Identity
+	if identity == "" {
+		identity = orderer.Host
+	}
 
-		var dialOpts []grpc.DialOption
-		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(MaxGrpcMsgSize),
-			grpc.MaxCallRecvMsgSize(MaxGrpcMsgSize)))
-		if AppConf.TlsEnabled {
-			creds, err := credentials.NewClientTLSFromFile(fmt.Sprintf("%s/tls/ca.crt", matches[0]), orderer.Host)
-			if err != nil {
-				panic(fmt.Sprintf("Error creating grpc tls client creds, serverAddr %s, err: %v", serverAddr, err))
-			}
-			dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
-		} else {
-			dialOpts = append(dialOpts, grpc.WithInsecure())
-		}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.clients[orderer.Name]; ok {
+		return nil, errors.Errorf("orderer %s already exists", orderer.Name)
+	}
+	client := &BroadcastClient{
+		name:       orderer.Name,
+		serverAddr: serverAddr,
+		identity:   identity,
+		dialOpts:   dialOpts,
+		joinTime:   time.Now().Unix(),
+		config:     t.config,
+		totalTax:   big.NewInt(0),
+		orderCount: big.NewInt(int64(len(t.clients) + 1)),
+		capacity:   capacity,
+		state:      protos.OrdererState_CONNECTING,
+		connecting: true,
+		connected:  make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+	t.clients[orderer.Name] = client
+	go client.connect()
+	return client, nil
+}
 
-		ctx, _ := context.WithTimeout(context.Background(), ConnTimeout)
-		//defer cancel()
+// RemoveOrderer drains an orderer, waits for the broadcasts of the txs it
+// fetched to be done, then disconnects it.
+func (t *TxsFetcher) RemoveOrderer(ctx context.Context, name string) (*BroadcastClient, error) {
+	client := t.GetOrderer(name)
+	if client == nil {
+		return nil, errors.New("not found orderer connected client")
+	}
+	if err := client.drain(); err != nil {
+		return nil, err
+	}
 
-		ordererConn, err := grpc.DialContext(ctx, serverAddr, dialOpts...)
-		if err != nil {
-			panic(fmt.Sprintf("Error connecting (grpc) to %s, err: %v", serverAddr, err))
-		}
+	done := make(chan struct{})
+	go func() {
+		client.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, errors.WithMessage(ctx.Err(), "orderer is still broadcasting, left draining")
+	}
 
-		client, err := ab.NewAtomicBroadcastClient(ordererConn).Broadcast(context.TODO())
+	t.mutex.Lock()
+	if t.clients[name] == client {
+		delete(t.clients, name)
+	}
+	t.mutex.Unlock()
+	client.close()
+	return client, nil
+}
+
+func dialOptions(orderer *conf.OrdererInfo, tlsCACert []byte) ([]grpc.DialOption, error) {
+	var dialOpts []grpc.DialOption
+	dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(MaxGrpcMsgSize),
+		grpc.MaxCallRecvMsgSize(MaxGrpcMsgSize)))
+	dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:                KeepaliveTime,
+		Timeout:             KeepaliveTimeout,
+		PermitWithoutStream: true,
+	}))
+	switch {
+	case len(tlsCACert) > 0:
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(tlsCACert) {
+			return nil, errors.Errorf("could not parse TLS CA cert of orderer %s", orderer.Name)
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(certPool, orderer.Host)))
+	case AppConf.TlsEnabled:
+		fpath := conf.GetCryptoConfigPath(fmt.Sprintf("ordererOrganizations/example.com/orderers/%s"+"*", orderer.Host))
+		matches, err := filepath.Glob(fpath)
 		if err != nil {
-			panic(fmt.Sprintf("Error creating broadcast client for orderer[%s] , err: %v", serverAddr, err))
+			return nil, errors.Wrapf(err, "cannot find filepath %s", fpath)
+		} else if len(matches) != 1 {
+			return nil, errors.Errorf("no msp directory filepath name matches: %s", fpath)
 		}
-
-		logger.Info("Connected orderer service", "ordererAddr", serverAddr)
-		ordererClients[orderer.Name] = &BroadcastClient{
-			name:       orderer.Name,
-			serverAddr: serverAddr,
-			dialOpts:   dialOpts,
-			client:     client,
-			joinTime:   time.Now().Unix(),
-			config:     config,
-			totalTax:   big.NewInt(0),
-			orderCount: big.NewInt(int64(len(AppConf.Orderers))),
-			capacity:   DefaultOrdererCapacity,
+		creds, err := credentials.NewClientTLSFromFile(fmt.Sprintf("%s/tls/ca.crt", matches[0]), orderer.Host)
+		if err != nil {
+			return nil, errors.WithMessage(err, "error creating grpc tls client creds")
 		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(creds))
+	default:
+		dialOpts = append(dialOpts, grpc.WithInsecure())
 	}
-
-	return ordererClients
+	return dialOpts, nil
 }
 
 type BroadcastClient struct {
 	name       string
 	serverAddr string
+	identity   string // common name of the certificate of its fetch requests
 	dialOpts   []grpc.DialOption
-	client     ab.AtomicBroadcast_BroadcastClient
+	conn       *grpc.ClientConn
+	streams    []*broadcastStream
+	next       uint32 // round robin over the streams
 	mutex      sync.Mutex
 
+	stateMtx sync.Mutex
+	state    protos.OrdererState
+	inflight sync.WaitGroup // fetches not broadcast yet
+	stop     chan struct{}
+	signedAt map[int64]bool // timestamps of the signed fetch requests in the time window
+
+	health     connectivity.State // of the current connection
+	connecting bool
+	connected  chan struct{} // closed once connected
+	reconnects int
+	lastErr    error
+
 	totalTax   *big.Int
 	orderCount *big.Int
 	fetchedTxs int64
 	joinTime   int64
 	config     *conf.DistributeConfig
-	capacity   int
+
+	statsMtx sync.Mutex
+	capacity int
+	pinned   bool // capacity set by hand
+	stats    capacityStats
+	decision *conf.CapacityDecision
 }
 
 // AddTax used to add order tax to orderer
@@ -170,52 +266,93 @@ func (b *BroadcastClient) calcInfo() (string, string, string, string, string) {
 	return b.name, strconv.FormatInt(b.fetchedTxs, 10), b.totalTax.String(), b.config.String(), speed
 }
 
-func (b *BroadcastClient) resetConnect() error {
-	ctx, _ := context.WithTimeout(context.Background(), ConnTimeout)
-	//defer cancel()
+// State returns the connection state of the orderer.
+func (b *BroadcastClient) State() protos.OrdererState {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	return b.state
+}
 
-	ordererConn, err := grpc.DialContext(ctx, b.serverAddr, b.dialOpts...)
-	if err != nil {
-		logger.Error("Error reset connecting (grpc) to %s, err: %v", b.serverAddr, err)
-		return err
+// Status returns the state and counters of the orderer.
+func (b *BroadcastClient) Status() *protos.OrdererStatus {
+	health, reconnects, lastErr := b.Health()
+	status := &protos.OrdererStatus{
+		Name:       b.name,
+		Addr:       b.serverAddr,
+		State:      b.State(),
+		Capacity:   int32(b.Capacity()),
+		FetchedTxs: b.fetchedTxs,
+		FeeReward:  b.GetTax(),
+		Health:     health.String(),
+		Reconnects: int32(reconnects),
 	}
-	client, err := ab.NewAtomicBroadcastClient(ordererConn).Broadcast(context.TODO())
-	if err != nil {
-		logger.Error("Error reset creating broadcast client for orderer[%s] , err: %v", b.serverAddr, err)
-		return err
+	if lastErr != nil {
+		status.LastError = lastErr.Error()
 	}
-	logger.Info("Connected orderer service", "ordererAddr", b.serverAddr)
-	b.client = client
-	return nil
+	return status
 }
 
-func (b *BroadcastClient) broadcast(transaction []byte) error {
-	env := &cb.Envelope{}
-	err := proto.Unmarshal(transaction, env)
-	if err != nil {
-		return err
+// begin registers a fetch by the orderer, which must be ready. end must be
+// called once the fetched txs are broadcast.
+func (b *BroadcastClient) begin() error {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	switch b.state {
+	case protos.OrdererState_READY:
+		if b.health == connectivity.TransientFailure || b.health == connectivity.Shutdown {
+			return errors.Errorf("orderer %s is unhealthy", b.name)
+		}
+		b.inflight.Add(1)
+		return nil
+	case protos.OrdererState_DRAINING:
+		return errOrdererDraining
 	}
+	return errors.Errorf("orderer %s is %s", b.name, b.state)
+}
 
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+func (b *BroadcastClient) end() {
+	b.inflight.Done()
+}
 
-	done := make(chan error)
-	go func() {
-		done <- b.getAck()
-	}()
-	if err := b.client.Send(env); err != nil {
-		return errors.WithMessage(err, "could not send")
-	}
-	return <-done
+// replayed checks a fetch request signed at timestamp was already used by
+// the orderer.
+func (b *BroadcastClient) replayed(timestamp int64) bool {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	return b.signedAt[timestamp]
 }
 
-func (b *BroadcastClient) getAck() error {
-	msg, err := b.client.Recv()
-	if err != nil {
-		return err
+// signed records the timestamp of a signed fetch request of the orderer, a
+// request with a timestamp already seen is a replay. The timestamps are kept
+// for the time window the requests are accepted in, so concurrent fetches of
+// the orderer may arrive in any order.
+func (b *BroadcastClient) signed(timestamp int64, window time.Duration) error {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	if b.signedAt[timestamp] {
+		return errors.Errorf("replayed fetch request of orderer %s", b.name)
+	}
+	if b.signedAt == nil {
+		b.signedAt = make(map[int64]bool)
+	}
+	// the requests signed out of the window are refused anyway
+	expired := time.Now().Add(-window).UnixNano()
+	for t := range b.signedAt {
+		if t < expired {
+			delete(b.signedAt, t)
+		}
 	}
-	if msg.Status != cb.Status_SUCCESS {
-		return fmt.Errorf("catch unexpected status: %v", msg.Status)
+	b.signedAt[timestamp] = true
+	return nil
+}
+
+// drain stops the orderer fetching txs.
+func (b *BroadcastClient) drain() error {
+	b.stateMtx.Lock()
+	defer b.stateMtx.Unlock()
+	if b.state == protos.OrdererState_REMOVED {
+		return errors.Errorf("orderer %s is removed", b.name)
 	}
+	b.state = protos.OrdererState_DRAINING
 	return nil
 }
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/config"
+	tmos "github.com/tendermint/tendermint/libs/os"
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/p2p/pex"
+	"github.com/tendermint/tendermint/version"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/mempool"
+)
+
+// Gossip runs the p2p switch which gossips the txs of the mempool with the
+// other fabric-mempool instances.
+type Gossip struct {
+	info      *conf.P2PInfo
+	sw        *p2p.Switch
+	transport *p2p.MultiplexTransport
+	reactor   *mempool.Reactor
+}
+
+// NewGossip creates the p2p switch for pool, it must be started by Start.
+func NewGossip(info *conf.P2PInfo, pool *mempool.CListMempool, cfg *config.MempoolConfig) (*Gossip, error) {
+	if err := tmos.EnsureDir(filepath.Dir(info.NodeKey), 0700); err != nil {
+		return nil, err
+	}
+	nodeKey, err := p2p.LoadOrGenNodeKey(info.NodeKey)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not load node key")
+	}
+
+	p2pConfig := config.DefaultP2PConfig()
+	p2pConfig.ListenAddress = info.ListenAddress
+	p2pConfig.ExternalAddress = info.ExternalAddress
+	p2pConfig.PersistentPeers = strings.Join(info.PersistentPeers, ",")
+	p2pConfig.AllowDuplicateIP = info.AllowDuplicateIP
+	p2pConfig.PexReactor = false
+	p2pConfig.AddrBookStrict = false
+
+	listenAddr := info.ExternalAddress
+	if listenAddr == "" {
+		listenAddr = info.ListenAddress
+	}
+	nodeInfo := p2p.DefaultNodeInfo{
+		ProtocolVersion: p2p.NewProtocolVersion(version.P2PProtocol, version.BlockProtocol, 0),
+		DefaultNodeID:   nodeKey.ID(),
+		ListenAddr:      listenAddr,
+		Network:         info.Network,
+		Version:         version.TMCoreSemVer,
+		Channels:        []byte{mempool.MempoolChannel, mempool.MempoolStatusChannel, mempool.MempoolInventoryChannel},
+		Moniker:         info.Moniker,
+	}
+	if err := nodeInfo.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "invalid p2p node info")
+	}
+
+	transport := p2p.NewMultiplexTransport(nodeInfo, *nodeKey, p2p.MConnConfig(p2pConfig))
+	if !p2pConfig.AllowDuplicateIP {
+		p2p.MultiplexTransportConnFilters(p2p.ConnDuplicateIPFilter())(transport)
+	}
+
+	var options []mempool.ReactorOption
+	if info.Scoring != nil {
+		options = append(options, mempool.WithPeerScoring(mempool.PeerScoreConfig{
+			DuplicateWeight: info.Scoring.DuplicateWeight,
+			InvalidWeight:   info.Scoring.InvalidWeight,
+			OversizeWeight:  info.Scoring.OversizeWeight,
+			ThrottleScore:   info.Scoring.ThrottleScore,
+			DisconnectScore: info.Scoring.DisconnectScore,
+			HalfLife:        info.Scoring.HalfLife,
+		}))
+	}
+	reactor := mempool.NewReactor(cfg, pool, options...)
+	reactor.SetLogger(logger.With("module", "mempool"))
+
+	sw := p2p.NewSwitch(p2pConfig, transport)
+	sw.SetLogger(logger.With("module", "p2p"))
+	sw.AddReactor("MEMPOOL", reactor)
+	sw.SetNodeInfo(nodeInfo)
+	sw.SetNodeKey(nodeKey)
+
+	// the address book keeps the switch from dialing itself
+	addrBook := pex.NewAddrBook(filepath.Join(filepath.Dir(info.NodeKey), "addrbook.json"), false)
+	addrBook.SetLogger(logger.With("module", "addrbook"))
+	for _, addr := range []string{info.ExternalAddress, info.ListenAddress} {
+		if addr == "" {
+			continue
+		}
+		netAddr, err := p2p.NewNetAddressString(p2p.IDAddressString(nodeKey.ID(), addr))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid p2p address %s", addr)
+		}
+		addrBook.AddOurAddress(netAddr)
+	}
+	sw.SetAddrBook(addrBook)
+
+	if err := sw.AddPersistentPeers(info.PersistentPeers); err != nil {
+		return nil, errors.WithMessage(err, "invalid persistent peers")
+	}
+	logger.Info("P2P node ID", "ID", nodeKey.ID(), "file", info.NodeKey)
+
+	return &Gossip{info: info, sw: sw, transport: transport, reactor: reactor}, nil
+}
+
+// Start listens for peers, starts the switch and dials the persistent peers.
+func (g *Gossip) Start() error {
+	addr, err := p2p.NewNetAddressString(p2p.IDAddressString(g.sw.NodeInfo().ID(), g.info.ListenAddress))
+	if err != nil {
+		return err
+	}
+	if err := g.transport.Listen(*addr); err != nil {
+		return err
+	}
+	if err := g.sw.Start(); err != nil {
+		return err
+	}
+	if err := g.sw.DialPeersAsync(g.info.PersistentPeers); err != nil {
+		return errors.WithMessage(err, "could not dial persistent peers")
+	}
+	logger.Info("P2P gossip running", "listenAddress", g.info.ListenAddress, "persistentPeers", len(g.info.PersistentPeers))
+	return nil
+}
+
+// PeerScores returns the misbehaviour scores of the peers, highest first.
+func (g *Gossip) PeerScores() []mempool.PeerScore {
+	return g.reactor.PeerScores()
+}
+
+// Stop disconnects all peers and stops the switch.
+func (g *Gossip) Stop() error {
+	return g.sw.Stop()
+}
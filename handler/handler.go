@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/big"
-	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -15,42 +17,164 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
 	"github.com/tylerztl/fabric-mempool/conf"
 	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protos"
 	"github.com/tylerztl/fabric-mempool/protoutil"
 )
 
 type Handler struct {
 	fetcher          *TxsFetcher
 	distributeConfig *conf.DistributeConfig
+	distributor      Distributor
+	distributorMtx   sync.RWMutex
 	sortConfig       *conf.SortConfig
 	mempool.Mempool
 	endorser pbpeer.EndorserClient
 	signer   *Crypto
+
+	statuses    *TxStatusIndex
+	invocations *invocations
+	resubmits   chan *resubmission // to resubmitLoop, if resubmitting is enabled
+	estimator   *mempool.FeeEstimator
+	leases      *leases
+	gossip      *Gossip
+	replica     *Replica
+	admissions  *admissions
+	fetchAuth   *fetchAuth
+	adminAuth   *adminAuth
+	capacity    *CapacityController
+	rewards     *RewardLedger
+	settler     *Settler
+	auditLog    *AuditLog
+	// accountability follows the fetched txs into blocks, if enabled
+	accountability *Accountability
 }
 
 func (h *Handler) SubmitTransaction(ctx context.Context, etx *pb.EndorsedTransaction) (*pb.SubmitTxResponse, error) {
-	err := h.Mempool.CheckTx(etx.Tx, nil, mempool.TxInfo{})
+	_, err := h.replicate(ctx, &protos.RaftEntry{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{etx.Tx}})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SubmitTxResponse{Status: pb.StatusCode_SUCCESS}, nil
+}
+
+// SubmitTimeLocked adds a transaction which must not be fetched by an orderer
+// before the given time and block height.
+func (h *Handler) SubmitTimeLocked(ctx context.Context, req *protos.TimeLockedTransaction) (*pb.SubmitTxResponse, error) {
+	_, err := h.replicate(ctx, &protos.RaftEntry{
+		Type:            protos.RaftEntryType_ADMIT,
+		Txs:             [][]byte{req.Tx},
+		NotBefore:       req.NotBefore,
+		NotBeforeHeight: req.NotBeforeHeight,
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &pb.SubmitTxResponse{Status: pb.StatusCode_SUCCESS}, nil
 }
 
-// distribute check tax add to one orderer or average all orderer
-func (h *Handler) distribute(tax *big.Int, orderer *BroadcastClient) {
-	if h.distributeConfig.DistributionType == 1 {
-		orderers := h.fetcher.GetOrderers()
-		ordererCount := big.NewInt(int64(len(orderers)))
-		// if less some tax after average tax, add to orderer which deal the order
-		average := new(big.Int).Div(tax, ordererCount)
-		less := new(big.Int).Sub(tax, new(big.Int).Mul(ordererCount, average))
-		orderer.AddTax(less)
-		for _, item := range orderers {
-			item.AddTax(average)
+// SubmitBundle adds several transactions which must be ordered together and
+// consecutively. The bundle is fetched by one orderer as a whole or not at all.
+func (h *Handler) SubmitBundle(ctx context.Context, req *protos.BundleRequest) (*protos.SubmitBundleResponse, error) {
+	txs := make(types.Txs, len(req.Txs))
+	for i, tx := range req.Txs {
+		txs[i] = tx
+	}
+	ttl := AppConf.Mempool.BundleTTL
+	if req.Ttl > 0 {
+		ttl = time.Duration(req.Ttl) * time.Second
+	}
+	_, err := h.replicate(ctx, &protos.RaftEntry{
+		Type: protos.RaftEntryType_ADMIT_BUNDLE,
+		Txs:  req.Txs,
+		Ttl:  int64(ttl),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &protos.SubmitBundleResponse{
+		Status:   pb.StatusCode_SUCCESS,
+		BundleId: fmt.Sprintf("%X", mempool.BundleKey(txs)),
+	}, nil
+}
+
+// EstimateFee suggests the fee limit to pay for a transaction to be fetched
+// by an orderer within the requested number of fetch rounds.
+func (h *Handler) EstimateFee(ctx context.Context, req *protos.EstimateFeeRequest) (*protos.EstimateFeeResponse, error) {
+	estimate := h.estimator.EstimateFee(int(req.TargetRounds))
+	resp := &protos.EstimateFeeResponse{
+		TargetRounds: uint32(estimate.TargetRounds),
+		Fee:          estimate.Fee,
+		MinFee:       estimate.MinFee,
+		PoolFee:      estimate.PoolFee,
+		HistoryFee:   estimate.HistoryFee,
+		AvgBatchSize: estimate.AvgBatchSize,
+		Samples:      uint32(estimate.Samples),
+	}
+	for _, p := range []int{10, 25, 50, 75, 90} {
+		if fee, ok := estimate.Percentiles[p]; ok {
+			resp.Percentiles = append(resp.Percentiles, &protos.FeePercentile{Percentile: uint32(p), Fee: fee})
 		}
-	} else {
-		orderer.AddTax(tax)
+	}
+	return resp, nil
+}
+
+// GetMinFee returns the minimum fee a transaction must currently pay to be admitted.
+func (h *Handler) GetMinFee() conf.MinFeeFeedback {
+	return conf.MinFeeFeedback{
+		MinFee:   h.Mempool.MinFee().String(),
+		Size:     h.Mempool.Size(),
+		TxsBytes: h.Mempool.TxsBytes(),
+	}
+}
+
+// GetTxStatus returns the latest known status of a transaction.
+func (h *Handler) GetTxStatus(txId string) (*TxStatus, error) {
+	status := h.statuses.Get(txId)
+	if status == nil {
+		return nil, errors.New("not found transaction status")
+	}
+	return status, nil
+}
+
+// SubscribeTxEvents returns a stream of transaction status changes.
+func (h *Handler) SubscribeTxEvents() (<-chan *TxEvent, func()) {
+	return h.statuses.Subscribe()
+}
+
+// distribute shares the tax of a tx broadcast by orderer with the
+// distribution strategy in use, and records the shares in the reward ledger.
+// The tax of a tx already credited is not shared out again.
+func (h *Handler) distribute(tx []byte, txId string, tax *big.Int, orderer *BroadcastClient) {
+	h.distributorMtx.RLock()
+	distributor := h.distributor
+	rule := h.distributeConfig.StrategyName()
+	h.distributorMtx.RUnlock()
+	shares := distributor.Distribute(tax, orderer, h.fetcher.GetOrderers())
+	credits := make(map[string]*big.Int, len(shares))
+	allocation := make(map[string]string, len(shares))
+	for item, share := range shares {
+		credits[item.name] = share
+		allocation[item.name] = share.String()
+	}
+	if err := h.rewards.Credit(txId, rule, credits); err != nil {
+		logger.Error("Could not record fee reward", "txId", txId, "error", err)
+		return
+	}
+	for item, share := range shares {
+		item.AddTax(share)
+	}
+	h.audit(AuditAllocation, map[string]interface{}{
+		"tx_id":   txId,
+		"fetcher": orderer.name,
+		"fee":     tax.String(),
+		"rule":    rule,
+		"shares":  allocation,
+	})
+	if h.accountability != nil {
+		h.accountability.fetched(tx, txId, orderer.name, credits)
 	}
 }
 
@@ -64,42 +188,132 @@ func (h *Handler) GetOrdererLog(name string) (string, error) {
 
 func (h *Handler) GetOrdererInfoList() conf.OrdererFeedback {
 	list := make([]*conf.Feedback, 0)
-	for k, v := range h.fetcher.clients {
-		list = append(list, &conf.Feedback{
-			Orderer:   k,
-			Capacity:  v.capacity,
-			FeeReward: v.totalTax.String(),
-		})
+	for k, v := range h.fetcher.GetOrderers() {
+		health, reconnects, lastErr := v.Health()
+		feedback := &conf.Feedback{
+			Orderer:        k,
+			State:          v.State().String(),
+			Capacity:       v.Capacity(),
+			CapacityPinned: v.CapacityPinned(),
+			FeeReward:      v.totalTax.String(),
+			Health:         health.String(),
+			Reconnects:     reconnects,
+			// set by the adaptive capacity controller
+			CapacityDecision: v.CapacityDecision(),
+		}
+		if h.accountability != nil {
+			feedback.Inclusion = h.accountability.Inclusion(k)
+		}
+		if lastErr != nil {
+			feedback.LastError = lastErr.Error()
+		}
+		list = append(list, feedback)
 	}
 	return conf.OrdererFeedback{
 		Lists: list,
 	}
 }
 
-// ChangeDistribute change distribution type
-func (h *Handler) ChangeDistribute(config *conf.DistributeConfig) {
+// GetPeerScores returns the misbehaviour scores of the gossip peers.
+func (h *Handler) GetPeerScores() ([]mempool.PeerScore, error) {
+	if h.gossip == nil {
+		return nil, errors.New("p2p gossip is disabled")
+	}
+	return h.gossip.PeerScores(), nil
+}
+
+// ChangeDistribute change distribution strategy, selected by name or by
+// distribution type, the request of ctx must be signed by an admin
+func (h *Handler) ChangeDistribute(ctx context.Context, config *conf.DistributeConfig) error {
+	admin, err := h.authorize(ctx, AdminChangeDistribute, config)
+	if err != nil {
+		return err
+	}
+	distributor, err := NewDistributor(config)
+	if err != nil {
+		return err
+	}
+	h.distributorMtx.Lock()
+	h.distributor = distributor
 	h.distributeConfig.DistributionType = config.DistributionType
-	logger.Info("change transaction allocation rule", "allocation-rule", config.String())
+	h.distributeConfig.Strategy = config.Strategy
+	h.distributeConfig.Stakes = config.Stakes
+	h.distributorMtx.Unlock()
+	h.audit(AuditDistribution, map[string]interface{}{
+		"allocation_rule": config.DistributionType,
+		"strategy":        config.Strategy,
+		"stakes":          config.Stakes,
+		"admin":           admin,
+	})
+	logger.Info("change transaction allocation rule", "allocation-rule", config.String(), "admin", admin)
+	return nil
 }
 
-func (h *Handler) ChangeSortSwitch(config *conf.SortConfig) {
+// ChangeSortSwitch the request of ctx must be signed by an admin
+func (h *Handler) ChangeSortSwitch(ctx context.Context, config *conf.SortConfig) error {
+	admin, err := h.authorize(ctx, AdminChangeSortSwitch, config)
+	if err != nil {
+		return err
+	}
 	h.sortConfig.SortSwitch = config.SortSwitch
-	logger.Info("change transaction sorting switch", " sorting-rule", config.String())
+	h.audit(AuditSort, map[string]interface{}{"sort_switch": config.SortSwitch, "admin": admin})
+	logger.Info("change transaction sorting switch", " sorting-rule", config.String(), "admin", admin)
+	return nil
 }
 
-func (h *Handler) ChangeOrdererCapacity(config *conf.OrdererCapacityConfig) error {
+// ChangeOrdererCapacity the request of ctx must be signed by an admin
+func (h *Handler) ChangeOrdererCapacity(ctx context.Context, config *conf.OrdererCapacityConfig) error {
+	admin, err := h.authorize(ctx, AdminChangeCapacity, config)
+	if err != nil {
+		return err
+	}
 	orderer := h.fetcher.GetOrderer(config.Orderer)
 	if orderer == nil {
 		logger.Error("Not found orderer", "name", config.Orderer)
 		return errors.New("not found orderer")
 	}
 
-	orderer.capacity = config.Capacity
-	logger.Info("change orderer capacity", "ordererName", config.Orderer, "new capacity", config.Capacity)
+	if config.Unpin {
+		orderer.unpinCapacity()
+		h.audit(AuditCapacity, map[string]interface{}{
+			"orderer": config.Orderer,
+			"unpin":   true,
+			"admin":   admin,
+		})
+		logger.Info("unpin orderer capacity", "ordererName", config.Orderer, "admin", admin)
+		return nil
+	}
+	if config.Capacity <= 0 {
+		return errors.Errorf("capacity must be positive, got %d", config.Capacity)
+	}
+
+	from := orderer.Capacity()
+	orderer.pinCapacity(config.Capacity)
+	h.audit(AuditCapacity, map[string]interface{}{
+		"orderer": config.Orderer,
+		"from":    from,
+		"to":      config.Capacity,
+		"admin":   admin,
+	})
+	logger.Info("change orderer capacity", "ordererName", config.Orderer, "new capacity", config.Capacity, "admin", admin)
 	return nil
 }
 
 func (h *Handler) Invoke(channelID, ccID, feeLimit string, args ...string) error {
+	_, err := h.invoke(&invocation{
+		channelID: channelID,
+		ccID:      ccID,
+		feeLimit:  feeLimit,
+		args:      args,
+	})
+	return err
+}
+
+// invoke endorses the invocation and adds the assembled transaction to the
+// mempool, returning its txId.
+func (h *Handler) invoke(inv *invocation) (string, error) {
+	channelID, ccID, feeLimit, args := inv.channelID, inv.ccID, inv.feeLimit, inv.args
+
 	var argsInByte [][]byte
 	for _, arg := range args {
 		argsInByte = append(argsInByte, []byte(arg))
@@ -114,60 +328,103 @@ func (h *Handler) Invoke(channelID, ccID, feeLimit string, args ...string) error
 		},
 	}
 	invocation := &pbpeer.ChaincodeInvocationSpec{ChaincodeSpec: spec}
-	prop, _, err := putils.CreateChaincodeProposalWithTxIDAndTransient(pb.HeaderType_ENDORSER_TRANSACTION, channelID, feeLimit, invocation, creator, "", nil)
+	prop, txId, err := putils.CreateChaincodeProposalWithTxIDAndTransient(pb.HeaderType_ENDORSER_TRANSACTION, channelID, feeLimit, invocation, creator, "", nil)
 	if err != nil {
-		return errors.WithMessage(err, "error creating proposal")
+		return "", errors.WithMessage(err, "error creating proposal")
 	}
 
 	signedProp, err := GetSignedProposal(prop, h.signer)
 	if err != nil {
-		return errors.WithMessage(err, "error creating signed proposal")
+		return "", errors.WithMessage(err, "error creating signed proposal")
 	}
 
 	proposalResp, err := h.endorser.ProcessProposal(context.Background(), signedProp)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if proposalResp.Response.Status >= shim.ERRORTHRESHOLD {
-		return errors.WithMessage(err, fmt.Sprintf("error proposal responses received, %s", proposalResp.Response.Message))
+		return "", errors.Errorf("error proposal responses received, %s", proposalResp.Response.Message)
 	}
 
 	// assemble a signed transaction (it's an Envelope message)
 	env, err := CreateSignedTx(prop, h.signer, proposalResp)
 	if err != nil {
-		return errors.WithMessage(err, "could not assemble transaction")
+		return "", errors.WithMessage(err, "could not assemble transaction")
 	}
 	envBytes, err := proto.Marshal(env)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if err := h.Mempool.CheckTx(envBytes, nil, mempool.TxInfo{}); err != nil {
+	_, err = h.replicate(context.Background(), &protos.RaftEntry{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{envBytes}})
+	if err != nil {
 		logger.Error("failed to add transaction", "error", err)
+		return "", err
 	}
+	h.invocations.add(txId, inv)
 
-	return nil
+	return txId, nil
 }
 
 func (h *Handler) FetchTransactions(ctx context.Context, ftx *pb.FetchTxsRequest) (*pb.FetchTxsResponse, error) {
-	if h.Mempool.Size() <= 0 {
-		return &pb.FetchTxsResponse{TxNum: 0, IsEmpty: true}, nil
-	}
+	return h.fetch(ctx, ftx, 0, h.sortConfig.SortSwitch)
+}
 
+// fetch reaps txs for the orderer requester and broadcasts them to it. A
+// positive max fetches fewer txs than the capacity of the orderer, sorted
+// reaps the txs with the highest fees first.
+func (h *Handler) fetch(ctx context.Context, ftx *pb.FetchTxsRequest, max int, sorted bool) (*pb.FetchTxsResponse, error) {
+	if h.replica != nil && !h.replica.IsLeader() {
+		return nil, errors.Errorf("not the leader of the mempool replicas, the leader is replica %d", h.replica.Leader())
+	}
 	orderer := h.fetcher.GetOrderer(ftx.Requester)
 	if orderer == nil {
 		return nil, errors.New("not found orderer connected client")
 	}
-	expectedTxs := orderer.capacity
+	if h.fetchAuth != nil {
+		if err := h.fetchAuth.authenticate(ctx, ftx, orderer); err != nil {
+			logger.Error("Rejected unauthenticated fetch request", "OrdererName", ftx.Requester, "error", err)
+			return nil, err
+		}
+	}
+	h.Mempool.PromoteDueTxs()
+	if h.Mempool.Size() <= 0 {
+		return &pb.FetchTxsResponse{TxNum: 0, IsEmpty: true}, nil
+	}
+	if err := orderer.begin(); err == errOrdererDraining {
+		return &pb.FetchTxsResponse{TxNum: 0, IsEmpty: true}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	expectedTxs := orderer.Capacity()
+	if max > 0 && max < expectedTxs {
+		expectedTxs = max
+	}
 
+	// the txs leased to an orderer are being broadcast by it, so a fetch
+	// running concurrently leaves them out
 	var txs types.Txs
-	if h.sortConfig.SortSwitch {
-		txs = h.Mempool.ReapMaxTxsBySort(expectedTxs)
+	h.leases.Lock()
+	if sorted {
+		txs = h.Mempool.ReapMaxTxsBySortSkipping(expectedTxs, h.leases.leased)
 	} else {
-		txs = h.Mempool.ReapMaxTxs(expectedTxs - 1)
+		txs = h.Mempool.ReapMaxTxsSkipping(expectedTxs, h.leases.leased)
+	}
+	h.leases.add(txs, ftx.Requester)
+	h.leases.Unlock()
+	if h.replica != nil {
+		// the other replicas keep the txs from being cancelled as well
+		_, err := h.replica.Propose(ctx, &protos.RaftEntry{Type: protos.RaftEntryType_LEASE, Txs: txBytes(txs), Requester: ftx.Requester})
+		if err != nil {
+			h.leases.release(txs)
+			orderer.end()
+			return nil, err
+		}
 	}
 	actualTxs := len(txs)
 	isEmpty := actualTxs < expectedTxs
+	orderer.recordFetch(expectedTxs, actualTxs, ftx.BlockHeight)
+	h.estimator.TxsReaped(txs)
 
 	logger.Info("Fetched unconfirmed transactions for orderer", "OrdererName", ftx.Requester,
 		"actualTxs", actualTxs, "capacity", expectedTxs, "mempool", h.Mempool.Size(), "blockHeight", ftx.BlockHeight)
@@ -175,38 +432,186 @@ func (h *Handler) FetchTransactions(ctx context.Context, ftx *pb.FetchTxsRequest
 	for i, tx := range txs {
 		fee, txId, err := protoutil.GetTxFeeFromEnvelope(tx)
 		if err != nil {
-			fmt.Printf("Unmarshal tx failed: %s", err)
+			logger.Error("Unmarshal fetched tx failed", "error", err)
 			continue
 		}
-		h.distribute(fee, orderer)
 		logger.Info("Fetched tx detail", "index", i, "txId", txId, "fee", fee)
 	}
 	orderer.AddTx(int64(actualTxs))
 	orderer.log()
 
-	go func() {
-		committedTxs := make(types.Txs, 0)
-		for _, tx := range txs {
-			err := orderer.broadcast(tx)
-			if err != nil {
-				logger.Error("failed to broadcast endorsed tx to orderer service", "error", err)
-				if err = orderer.resetConnect(); err == nil && orderer.broadcast(tx) != nil {
-					logger.Error("retry broadcast endorsed tx to orderer service", "ordererName", ftx.Requester)
+	go h.broadcastFetched(orderer, txs, ftx.BlockHeight)
+
+	//if err := h.Mempool.Update(1, txs, nil, nil, nil); err != nil {
+	//	logger.Error("txs committed update failed", "error", err)
+	//}
+
+	return &pb.FetchTxsResponse{TxNum: int32(actualTxs), IsEmpty: isEmpty}, nil
+}
+
+// broadcastFetched broadcasts the txs fetched by orderer. The txs failing
+// are retried together once the orderer is connected again, so the rest of
+// a bundle is still broadcast in order. The txs broadcast are removed from
+// the mempool and their fees shared out, the ones failing again stay pending
+// and can be fetched again.
+func (h *Handler) broadcastFetched(orderer *BroadcastClient, txs types.Txs, blockHeight uint64) {
+	defer orderer.end()
+
+	// the txs rejected by the orderer are dropped, the failed ones are
+	// broadcast again once and only a broken stream waits for a reconnect
+	var failed, rejected types.Txs
+	var cause error
+	errs := orderer.broadcastAll(txs)
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if isRejected(err) {
+			logger.Error("orderer service rejected the endorsed tx", "index", i, "error", err)
+			rejected = append(rejected, txs[i])
+			continue
+		}
+		logger.Error("failed to broadcast endorsed tx to orderer service", "index", i, "error", err)
+		failed = append(failed, txs[i])
+		if cause == nil && !isStatusError(err) {
+			cause = err
+		}
+	}
+	if len(failed) > 0 {
+		retry := failed
+		failed = nil
+		var err error
+		if cause != nil {
+			err = orderer.awaitReconnect(cause, ConnTimeout)
+		}
+		if err != nil {
+			failed = retry
+		} else {
+			for i, err := range orderer.broadcastAll(retry) {
+				if isRejected(err) {
+					rejected = append(rejected, retry[i])
+				} else if err != nil {
+					failed = append(failed, retry[i])
 				}
 			}
+		}
+		if len(failed) > 0 {
+			logger.Error("retry broadcast endorsed tx to orderer service", "ordererName", orderer.name, "failed", len(failed), "error", err)
+		}
+	}
 
-			committedTxs = append(committedTxs, tx)
+	states := make(map[[mempool.TxKeySize]byte]TxState, len(failed)+len(rejected))
+	for _, tx := range failed {
+		states[mempool.TxKey(tx)] = TxFailed
+	}
+	for _, tx := range rejected {
+		states[mempool.TxKey(tx)] = TxInvalid
+	}
+	// the broadcast and the rejected txs leave the pool
+	removed := make(types.Txs, 0, len(txs))
+	for _, tx := range txs {
+		state, ok := states[mempool.TxKey(tx)]
+		if !ok {
+			state = TxBroadcast
 		}
-		if err := h.Mempool.Update(int64(ftx.BlockHeight), committedTxs, nil, nil, nil); err != nil {
-			logger.Error("txs committed update failed", "error", err)
+		if state != TxFailed {
+			removed = append(removed, tx)
 		}
-	}()
+		fee, txId, err := protoutil.GetTxFeeFromEnvelope(tx)
+		if err != nil {
+			continue
+		}
+		h.statuses.Update(txId, 0, func(status *TxStatus) {
+			status.State = state
+			status.Orderer = orderer.name
+		})
+		if state == TxBroadcast {
+			h.distribute(tx, txId, fee, orderer)
+		}
+	}
 
-	//if err := h.Mempool.Update(1, txs, nil, nil, nil); err != nil {
-	//	logger.Error("txs committed update failed", "error", err)
-	//}
+	if len(failed) > 0 {
+		// the failed txs stay pending, leased to no orderer
+		_, err := h.replicate(context.Background(), &protos.RaftEntry{Type: protos.RaftEntryType_RELEASE, Txs: txBytes(failed)})
+		if err != nil {
+			logger.Error("Could not release the txs failed to be broadcast", "error", err)
+			h.leases.release(failed)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	_, err := h.replicate(context.Background(), &protos.RaftEntry{
+		Type:        protos.RaftEntryType_REMOVE,
+		Txs:         txBytes(removed),
+		BlockHeight: blockHeight,
+	})
+	if err != nil {
+		logger.Error("txs committed update failed", "error", err)
+		h.leases.release(removed)
+	}
+}
 
-	return &pb.FetchTxsResponse{TxNum: int32(actualTxs), IsEmpty: isEmpty}, nil
+// newMempool creates the transaction pool from the application settings.
+func newMempool(info *conf.MempoolInfo) (*mempool.CListMempool, *config.MempoolConfig, error) {
+	rootDir := info.RootDir
+	if rootDir == "" {
+		// create a unique, concurrency-safe data directory under os.TempDir()
+		dir, err := ioutil.TempDir("", "fabric-mempool_")
+		if err != nil {
+			return nil, nil, err
+		}
+		rootDir = dir
+	}
+
+	cfg := config.DefaultMempoolConfig()
+	cfg.RootDir = rootDir
+	cfg.WalPath = info.WalDir
+	cfg.Size = info.Size
+	cfg.MaxTxsBytes = info.MaxTxsBytes
+	cfg.MaxTxBytes = info.MaxTxBytes
+	cfg.CacheSize = info.CacheSize
+	cfg.Recheck = info.Recheck
+
+	options := []mempool.CListMempoolOption{
+		mempool.WithMaxBundleTxs(info.MaxBundleTxs),
+		mempool.WithMaxTimeLock(info.MaxTimeLock),
+		mempool.WithRequireFee(),
+	}
+	if info.TimestampLock {
+		options = append(options, mempool.WithTimestampLock())
+	}
+	if info.MinFee != nil {
+		minFee, err := mempool.NewMinFeeFunc(info.MinFee.Curve, info.MinFee.Base, info.MinFee.Max, info.MinFee.Threshold)
+		if err != nil {
+			return nil, nil, err
+		}
+		options = append(options, mempool.WithMinFee(minFee))
+	}
+	if info.Overflow != nil {
+		dir := info.Overflow.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(rootDir, dir)
+		}
+		db, err := dbm.NewDB("overflow", dbm.GoLevelDBBackend, dir)
+		if err != nil {
+			return nil, nil, errors.WithMessage(err, "could not open mempool overflow")
+		}
+		options = append(options, mempool.WithOverflow(db, info.Overflow.MaxTxs, info.Overflow.MaxTxsBytes))
+		logger.Info("Enabled mempool overflow", "dir", dir, "maxTxs", info.Overflow.MaxTxs,
+			"maxTxsBytes", info.Overflow.MaxTxsBytes)
+	}
+
+	pool := mempool.NewCListMempool(cfg, 0, options...)
+	pool.SetLogger(logger)
+	if cfg.WalEnabled() {
+		if err := pool.InitWAL(); err != nil {
+			return nil, nil, errors.WithMessage(err, "could not init mempool WAL")
+		}
+	}
+	logger.Info("Created mempool", "rootDir", rootDir, "wal", cfg.WalEnabled(), "size", cfg.Size,
+		"maxTxsBytes", cfg.MaxTxsBytes, "maxTxBytes", cfg.MaxTxBytes, "cacheSize", cfg.CacheSize, "recheck", cfg.Recheck)
+	return pool, cfg, nil
 }
 
 func NewHandler(distributeConfig *conf.DistributeConfig, sortConfig *conf.SortConfig) *Handler {
@@ -219,32 +624,127 @@ func NewHandler(distributeConfig *conf.DistributeConfig, sortConfig *conf.SortCo
 		panic(err)
 	}
 
-	// create a unique, concurrency-safe test directory under os.TempDir()
-	rootDir := os.Getenv("MEMPOOL_DATA")
-	if rootDir == "" {
-		rootDir, err = ioutil.TempDir("", "fabric-mempool_")
-		if err != nil {
-			panic(err)
-		}
-		defer func() {
-			_ = os.RemoveAll(rootDir)
-		}()
+	pool, poolConfig, err := newMempool(AppConf.Mempool)
+	if err != nil {
+		panic(err)
 	}
 
-	cfg := config.DefaultMempoolConfig()
-	cfg.CacheSize = 1000
-	cfg.RootDir = rootDir
-	cfg.Size = 10000000
+	if AppConf.Distribution != nil {
+		*distributeConfig = *AppConf.Distribution
+	}
+	distributor, err := NewDistributor(distributeConfig)
+	if err != nil {
+		panic(err)
+	}
 
-	pool := mempool.NewCListMempool(cfg, 0)
-	pool.SetLogger(logger)
+	// the rewards and the audit log outlive the temp dir of a mempool
+	// without rootDir
+	rewardsDir, err := AppConf.Mempool.StatePath(AppConf.Rewards.Dir)
+	if err != nil {
+		panic(err)
+	}
+	rewardsDB, err := dbm.NewDB("rewards", dbm.GoLevelDBBackend, rewardsDir)
+	if err != nil {
+		panic(errors.WithMessage(err, "could not open reward ledger"))
+	}
+	rewards, err := NewRewardLedger(rewardsDB, AppConf.Rewards)
+	if err != nil {
+		panic(errors.WithMessage(err, "could not load reward ledger"))
+	}
 
-	return &Handler{
+	auditFile, err := AppConf.Mempool.StatePath(AppConf.Audit.File)
+	if err != nil {
+		panic(err)
+	}
+	auditLog, err := OpenAuditLog(auditFile, signer)
+	if err != nil {
+		panic(err)
+	}
+
+	h := &Handler{
 		fetcher:          NewTxsFetcher(distributeConfig),
 		Mempool:          pool,
 		distributeConfig: distributeConfig,
+		distributor:      distributor,
 		sortConfig:       sortConfig,
 		endorser:         endorser,
 		signer:           signer,
+		statuses:         NewTxStatusIndex(),
+		invocations:      newInvocations(),
+		estimator:        mempool.NewFeeEstimator(pool),
+		leases:           newLeases(),
+		rewards:          rewards,
+		auditLog:         auditLog,
+	}
+	for name, orderer := range h.fetcher.GetOrderers() {
+		orderer.AddTax(rewards.Total(name))
+	}
+	if AppConf.Settlement != nil {
+		h.settler = NewSettler(AppConf.Settlement, h, rewards)
+	}
+	if AppConf.Accountability != nil {
+		h.accountability = NewAccountability(AppConf.Accountability, h)
+		h.accountability.Start()
+	}
+
+	if AppConf.Resubmit != nil && AppConf.Resubmit.Auto {
+		h.resubmits = make(chan *resubmission, ResubmitQueueSize)
+		go h.resubmitLoop()
+	}
+
+	if len(AppConf.Channels) > 0 {
+		deliver, err := CreateDeliverClient(AppConf.Peer)
+		if err != nil {
+			panic(err)
+		}
+		for _, channelID := range AppConf.Channels {
+			go NewCommitListener(channelID, deliver, signer, h.onCommitted).Start(context.Background())
+		}
+	}
+
+	if AppConf.FetchAuth != nil {
+		if h.fetchAuth, err = newFetchAuth(AppConf.FetchAuth); err != nil {
+			panic(err)
+		}
+	}
+	if h.adminAuth, err = newAdminAuth(AppConf.AdminAuth); err != nil {
+		panic(err)
+	}
+
+	if AppConf.Capacity != nil {
+		h.capacity = NewCapacityController(AppConf.Capacity, h.fetcher)
+		h.capacity.Start()
+	}
+
+	if AppConf.Raft != nil {
+		h.admissions = newAdmissions()
+		if h.replica, err = NewReplica(AppConf.Raft, h); err != nil {
+			panic(err)
+		}
+		h.replica.Start()
+	}
+
+	if AppConf.P2P != nil {
+		if h.gossip, err = NewGossip(AppConf.P2P, pool, poolConfig); err != nil {
+			panic(err)
+		}
+		if err = h.gossip.Start(); err != nil {
+			panic(err)
+		}
+	}
+
+	// settlements are submitted through the replicas, if any
+	if h.settler != nil {
+		h.settler.Start()
+	}
+
+	return h
+}
+
+func txBytes(txs types.Txs) [][]byte {
+	bz := make([][]byte, len(txs))
+	for i, tx := range txs {
+		bz[i] = tx
 	}
+	return bz
 }
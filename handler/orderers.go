@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
+)
+
+// AddOrderer implements the OrdererAdmin service, adding an orderer which is
+// connected in the background.
+func (h *Handler) AddOrderer(ctx context.Context, req *protos.AddOrdererRequest) (*protos.OrdererStatus, error) {
+	admin, err := h.authorize(ctx, AdminAddOrderer, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Port > math.MaxUint16 {
+		return nil, errors.Errorf("invalid orderer port %d", req.Port)
+	}
+	capacity := int(req.Capacity)
+	if capacity <= 0 {
+		capacity = DefaultOrdererCapacity
+	}
+	info := &conf.OrdererInfo{Name: req.Name, Host: req.Host, Port: uint16(req.Port), Identity: req.Identity}
+	orderer, err := h.fetcher.AddOrderer(info, req.TlsCaCert, capacity)
+	if err != nil {
+		return nil, err
+	}
+	// an orderer added again keeps its rewards
+	orderer.AddTax(h.rewards.Total(req.Name))
+	h.audit(AuditOrderer, map[string]interface{}{
+		"action":   "add",
+		"orderer":  req.Name,
+		"addr":     orderer.serverAddr,
+		"identity": orderer.identity,
+		"capacity": capacity,
+		"admin":    admin,
+	})
+	logger.Info("Added orderer", "ordererName", req.Name, "ordererAddr", orderer.serverAddr, "capacity", capacity, "admin", admin)
+	return orderer.Status(), nil
+}
+
+// DrainOrderer implements the OrdererAdmin service, the orderer fetches no
+// more txs but stays connected.
+func (h *Handler) DrainOrderer(ctx context.Context, req *protos.OrdererRequest) (*protos.OrdererStatus, error) {
+	admin, err := h.authorize(ctx, AdminDrainOrderer, req)
+	if err != nil {
+		return nil, err
+	}
+	orderer := h.fetcher.GetOrderer(req.Name)
+	if orderer == nil {
+		return nil, errors.New("not found orderer connected client")
+	}
+	if err := orderer.drain(); err != nil {
+		return nil, err
+	}
+	h.audit(AuditOrderer, map[string]interface{}{"action": "drain", "orderer": req.Name, "admin": admin})
+	logger.Info("Draining orderer", "ordererName", req.Name, "admin", admin)
+	return orderer.Status(), nil
+}
+
+// RemoveOrderer implements the OrdererAdmin service, it returns once the txs
+// fetched by the orderer are broadcast and the orderer is disconnected.
+func (h *Handler) RemoveOrderer(ctx context.Context, req *protos.OrdererRequest) (*protos.OrdererStatus, error) {
+	admin, err := h.authorize(ctx, AdminRemoveOrderer, req)
+	if err != nil {
+		return nil, err
+	}
+	orderer, err := h.fetcher.RemoveOrderer(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	h.audit(AuditOrderer, map[string]interface{}{"action": "remove", "orderer": req.Name, "admin": admin})
+	logger.Info("Removed orderer", "ordererName", req.Name, "admin", admin)
+	return orderer.Status(), nil
+}
+
+// ListOrderers implements the OrdererAdmin service.
+func (h *Handler) ListOrderers(ctx context.Context, req *protos.ListOrderersRequest) (*protos.ListOrderersResponse, error) {
+	resp := &protos.ListOrderersResponse{}
+	for _, orderer := range h.fetcher.GetOrderers() {
+		resp.Orderers = append(resp.Orderers, orderer.Status())
+	}
+	sort.Slice(resp.Orderers, func(i, j int) bool { return resp.Orderers[i].Name < resp.Orderers[j].Name })
+	return resp, nil
+}
@@ -0,0 +1,466 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"go.etcd.io/etcd/etcdserver/api/snap"
+	"go.etcd.io/etcd/raft"
+	"go.etcd.io/etcd/raft/raftpb"
+	"go.etcd.io/etcd/wal"
+	"go.etcd.io/etcd/wal/walpb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// snapshotCatchUpEntries is the number of entries kept in the log after a
+// snapshot, so slow followers catch up without the snapshot.
+const snapshotCatchUpEntries = 1000
+
+// StateMachine is the mempool state replicated by a Replica.
+type StateMachine interface {
+	// Apply applies a committed entry, the result is returned to the
+	// proposer of the entry.
+	Apply(entry *protos.RaftEntry) (interface{}, error)
+	// Snapshot returns the state to rebuild the mempool from.
+	Snapshot() ([]byte, error)
+	// Restore replaces the state by a snapshot.
+	Restore(data []byte) error
+}
+
+type applyResult struct {
+	value interface{}
+	err   error
+}
+
+// Replica is a member of a cluster of mempool replicas which replicate
+// the changes of the mempool through a Raft log. Changes are proposed by
+// any replica and applied by every replica in log order.
+type Replica struct {
+	info    *conf.RaftInfo
+	fsm     StateMachine
+	node    raft.Node
+	storage *raft.MemoryStorage
+	wal     *wal.WAL
+	snaps   *snap.Snapshotter
+	tls     *raftTLS
+	peers   map[uint64]*raftPeer
+
+	lead   uint64 // atomic
+	nextID uint64 // atomic
+
+	waitersMtx sync.Mutex
+	waiters    map[uint64]chan applyResult
+
+	confState     raftpb.ConfState
+	snapshotIndex uint64
+	appliedIndex  uint64
+
+	stopC chan struct{}
+	doneC chan struct{}
+}
+
+// NewReplica restores the Raft log of the replica from its data directory,
+// the replica must be started by Start.
+func NewReplica(info *conf.RaftInfo, fsm StateMachine) (*Replica, error) {
+	r := &Replica{
+		info:    info,
+		fsm:     fsm,
+		storage: raft.NewMemoryStorage(),
+		peers:   make(map[uint64]*raftPeer),
+		nextID:  uint64(rand.Int63()),
+		waiters: make(map[uint64]chan applyResult),
+		stopC:   make(chan struct{}),
+		doneC:   make(chan struct{}),
+	}
+
+	walDir := filepath.Join(info.DataDir, "wal")
+	snapDir := filepath.Join(info.DataDir, "snap")
+	if err := os.MkdirAll(snapDir, 0750); err != nil {
+		return nil, errors.WithMessage(err, "could not create raft snapshot dir")
+	}
+	r.snaps = snap.New(zap.NewNop(), snapDir)
+
+	restart := wal.Exist(walDir)
+	if !restart {
+		w, err := wal.Create(zap.NewNop(), walDir, nil)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not create raft wal")
+		}
+		w.Close()
+	}
+
+	snapshot, err := r.snaps.Load()
+	if err != nil && err != snap.ErrNoSnapshot {
+		return nil, errors.WithMessage(err, "could not load raft snapshot")
+	}
+	walSnap := walpb.Snapshot{}
+	if snapshot != nil {
+		walSnap.Index, walSnap.Term = snapshot.Metadata.Index, snapshot.Metadata.Term
+		if err := r.storage.ApplySnapshot(*snapshot); err != nil {
+			return nil, err
+		}
+		if err := fsm.Restore(snapshot.Data); err != nil {
+			return nil, errors.WithMessage(err, "could not restore raft snapshot")
+		}
+		r.confState = snapshot.Metadata.ConfState
+		r.snapshotIndex = snapshot.Metadata.Index
+		r.appliedIndex = snapshot.Metadata.Index
+	}
+	if r.wal, err = wal.Open(zap.NewNop(), walDir, walSnap); err != nil {
+		return nil, errors.WithMessage(err, "could not open raft wal")
+	}
+	_, hardState, entries, err := r.wal.ReadAll()
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not read raft wal")
+	}
+	if err := r.storage.SetHardState(hardState); err != nil {
+		return nil, err
+	}
+	if err := r.storage.Append(entries); err != nil {
+		return nil, err
+	}
+
+	config := &raft.Config{
+		ID:              info.ID,
+		ElectionTick:    info.ElectionTicks,
+		HeartbeatTick:   info.HeartbeatTicks,
+		Storage:         r.storage,
+		Applied:         r.appliedIndex,
+		MaxSizePerMsg:   1024 * 1024,
+		MaxInflightMsgs: 256,
+		PreVote:         true,
+		CheckQuorum:     true,
+		Logger:          raftLogger{},
+	}
+	if restart {
+		r.node = raft.RestartNode(config)
+	} else {
+		peers := make([]raft.Peer, len(info.Peers))
+		for i, peer := range info.Peers {
+			peers[i] = raft.Peer{ID: peer.ID}
+		}
+		r.node = raft.StartNode(config, peers)
+	}
+
+	if r.tls, err = newRaftTLS(info); err != nil {
+		return nil, err
+	}
+	for _, peer := range info.Peers {
+		if peer.ID == info.ID {
+			continue
+		}
+		conn, err := grpc.Dial(peer.Addr, grpc.WithTransportCredentials(r.tls.clientCredentials(peer.ID)))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not dial raft peer %d", peer.ID)
+		}
+		r.peers[peer.ID] = &raftPeer{
+			id:     peer.ID,
+			conn:   conn,
+			client: protos.NewRaftClient(conn),
+			sendC:  make(chan raftpb.Message, 4096),
+		}
+	}
+	logger.Info("Restored raft replica", "id", info.ID, "restart", restart,
+		"snapshotIndex", r.snapshotIndex, "entries", len(entries))
+	return r, nil
+}
+
+// Start runs the Raft node and the senders to the other replicas.
+func (r *Replica) Start() {
+	for _, peer := range r.peers {
+		go peer.run(r)
+	}
+	go r.run()
+}
+
+// Stop stops the Raft node, pending proposals fail.
+func (r *Replica) Stop() {
+	close(r.stopC)
+	<-r.doneC
+}
+
+// NewServer returns the server of the Raft service, which accepts the peers
+// of the replica only. It serves the messages of the peers to h.
+func (r *Replica) NewServer(h protos.RaftServer) *grpc.Server {
+	server := grpc.NewServer(grpc.Creds(r.tls.serverCredentials()))
+	protos.RegisterRaftServer(server, h)
+	return server
+}
+
+// Leader returns the raft id of the leader, zero if there is none.
+func (r *Replica) Leader() uint64 {
+	return atomic.LoadUint64(&r.lead)
+}
+
+// IsLeader returns true if this replica is the leader.
+func (r *Replica) IsLeader() bool {
+	return r.Leader() == r.info.ID
+}
+
+// Propose appends entry to the Raft log and waits until this replica
+// applied it, returning the result of the apply.
+func (r *Replica) Propose(ctx context.Context, entry *protos.RaftEntry) (interface{}, error) {
+	entry.Replica = r.info.ID
+	entry.Id = atomic.AddUint64(&r.nextID, 1)
+	data, err := proto.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	resultC := make(chan applyResult, 1)
+	r.waitersMtx.Lock()
+	r.waiters[entry.Id] = resultC
+	r.waitersMtx.Unlock()
+	defer func() {
+		r.waitersMtx.Lock()
+		delete(r.waiters, entry.Id)
+		r.waitersMtx.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.info.ProposeTimeout)
+	defer cancel()
+	if err := r.node.Propose(ctx, data); err != nil {
+		return nil, errors.WithMessage(err, "could not propose raft entry")
+	}
+	select {
+	case result := <-resultC:
+		return result.value, result.err
+	case <-ctx.Done():
+		return nil, errors.WithMessage(ctx.Err(), "raft entry not applied")
+	case <-r.stopC:
+		return nil, errors.New("raft replica stopped")
+	}
+}
+
+// Step passes a message received from another replica to the Raft node.
+func (r *Replica) Step(ctx context.Context, msg *protos.RaftMessage) (*protos.RaftResponse, error) {
+	var m raftpb.Message
+	if err := m.Unmarshal(msg.Payload); err != nil {
+		return nil, errors.WithMessage(err, "invalid raft message")
+	}
+	if err := r.node.Step(ctx, m); err != nil {
+		return nil, err
+	}
+	return &protos.RaftResponse{}, nil
+}
+
+func (r *Replica) run() {
+	defer close(r.doneC)
+	defer r.wal.Close()
+	defer r.node.Stop()
+
+	ticker := time.NewTicker(r.info.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.node.Tick()
+
+		case rd := <-r.node.Ready():
+			if rd.SoftState != nil {
+				if old := atomic.SwapUint64(&r.lead, rd.SoftState.Lead); old != rd.SoftState.Lead {
+					logger.Info("Raft leader changed", "leader", rd.SoftState.Lead, "replica", r.info.ID)
+				}
+			}
+			if err := r.wal.Save(rd.HardState, rd.Entries); err != nil {
+				panic(errors.WithMessage(err, "could not save raft entries"))
+			}
+			if !raft.IsEmptySnap(rd.Snapshot) {
+				r.installSnapshot(rd.Snapshot)
+			}
+			if err := r.storage.Append(rd.Entries); err != nil {
+				panic(err)
+			}
+			r.send(rd.Messages)
+			r.applyEntries(rd.CommittedEntries)
+			r.maybeSnapshot()
+			r.node.Advance()
+
+		case <-r.stopC:
+			return
+		}
+	}
+}
+
+// installSnapshot replaces the state by a snapshot received from the leader.
+func (r *Replica) installSnapshot(snapshot raftpb.Snapshot) {
+	if err := r.saveSnapshot(snapshot); err != nil {
+		panic(errors.WithMessage(err, "could not save raft snapshot"))
+	}
+	if err := r.storage.ApplySnapshot(snapshot); err != nil {
+		panic(err)
+	}
+	if err := r.fsm.Restore(snapshot.Data); err != nil {
+		panic(errors.WithMessage(err, "could not restore raft snapshot"))
+	}
+	r.confState = snapshot.Metadata.ConfState
+	r.snapshotIndex = snapshot.Metadata.Index
+	r.appliedIndex = snapshot.Metadata.Index
+	logger.Info("Installed raft snapshot", "index", snapshot.Metadata.Index)
+}
+
+func (r *Replica) saveSnapshot(snapshot raftpb.Snapshot) error {
+	// the snapshot index must be in the WAL before the snapshot is saved,
+	// the WAL is only opened at saved snapshot indexes
+	walSnap := walpb.Snapshot{Index: snapshot.Metadata.Index, Term: snapshot.Metadata.Term}
+	if err := r.wal.SaveSnapshot(walSnap); err != nil {
+		return err
+	}
+	if err := r.snaps.SaveSnap(snapshot); err != nil {
+		return err
+	}
+	return r.wal.ReleaseLockTo(snapshot.Metadata.Index)
+}
+
+func (r *Replica) applyEntries(entries []raftpb.Entry) {
+	for _, e := range entries {
+		if e.Index <= r.appliedIndex {
+			continue
+		}
+		switch e.Type {
+		case raftpb.EntryNormal:
+			if len(e.Data) > 0 {
+				r.applyEntry(e.Data)
+			}
+		case raftpb.EntryConfChange:
+			var cc raftpb.ConfChange
+			if err := cc.Unmarshal(e.Data); err != nil {
+				panic(err)
+			}
+			r.confState = *r.node.ApplyConfChange(cc)
+		}
+		r.appliedIndex = e.Index
+	}
+}
+
+func (r *Replica) applyEntry(data []byte) {
+	entry := &protos.RaftEntry{}
+	if err := proto.Unmarshal(data, entry); err != nil {
+		logger.Error("Ignoring invalid raft entry", "error", err)
+		return
+	}
+	value, err := r.fsm.Apply(entry)
+	if entry.Replica != r.info.ID {
+		return
+	}
+	r.waitersMtx.Lock()
+	resultC, ok := r.waiters[entry.Id]
+	r.waitersMtx.Unlock()
+	if ok {
+		resultC <- applyResult{value: value, err: err}
+	}
+}
+
+// maybeSnapshot snapshots the state and compacts the log every
+// SnapshotEntries applied entries.
+func (r *Replica) maybeSnapshot() {
+	if r.appliedIndex-r.snapshotIndex < r.info.SnapshotEntries {
+		return
+	}
+	data, err := r.fsm.Snapshot()
+	if err != nil {
+		logger.Error("Could not snapshot mempool", "error", err)
+		return
+	}
+	snapshot, err := r.storage.CreateSnapshot(r.appliedIndex, &r.confState, data)
+	if err != nil {
+		panic(err)
+	}
+	if err := r.saveSnapshot(snapshot); err != nil {
+		panic(errors.WithMessage(err, "could not save raft snapshot"))
+	}
+	if r.appliedIndex > snapshotCatchUpEntries {
+		if err := r.storage.Compact(r.appliedIndex - snapshotCatchUpEntries); err != nil && err != raft.ErrCompacted {
+			panic(err)
+		}
+	}
+	r.snapshotIndex = r.appliedIndex
+	logger.Info("Saved raft snapshot", "index", r.appliedIndex, "bytes", len(data))
+}
+
+func (r *Replica) send(msgs []raftpb.Message) {
+	for _, m := range msgs {
+		peer, ok := r.peers[m.To]
+		if !ok {
+			continue
+		}
+		select {
+		case peer.sendC <- m:
+		default:
+			// raft retransmits what the replica missed
+			r.node.ReportUnreachable(m.To)
+			if m.Type == raftpb.MsgSnap {
+				r.node.ReportSnapshot(m.To, raft.SnapshotFailure)
+			}
+		}
+	}
+}
+
+// raftPeer sends the messages to another replica in order.
+type raftPeer struct {
+	id     uint64
+	conn   *grpc.ClientConn
+	client protos.RaftClient
+	sendC  chan raftpb.Message
+}
+
+func (p *raftPeer) run(r *Replica) {
+	defer p.conn.Close()
+	for {
+		select {
+		case m := <-p.sendC:
+			err := p.send(r, m)
+			if err != nil {
+				r.node.ReportUnreachable(p.id)
+			}
+			if m.Type == raftpb.MsgSnap {
+				status := raft.SnapshotFinish
+				if err != nil {
+					status = raft.SnapshotFailure
+				}
+				r.node.ReportSnapshot(p.id, status)
+			}
+		case <-r.stopC:
+			return
+		}
+	}
+}
+
+func (p *raftPeer) send(r *Replica, m raftpb.Message) error {
+	payload, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.info.ProposeTimeout)
+	defer cancel()
+	_, err = p.client.Step(ctx, &protos.RaftMessage{Payload: payload})
+	return err
+}
+
+// raftLogger writes the logs of the Raft node to the mempool logger.
+type raftLogger struct{}
+
+func (raftLogger) Debug(v ...interface{})                   {}
+func (raftLogger) Debugf(format string, v ...interface{})   {}
+func (raftLogger) Info(v ...interface{})                    { logger.Info(fmt.Sprint(v...)) }
+func (raftLogger) Infof(format string, v ...interface{})    { logger.Info(fmt.Sprintf(format, v...)) }
+func (raftLogger) Warning(v ...interface{})                 { logger.Error(fmt.Sprint(v...)) }
+func (raftLogger) Warningf(format string, v ...interface{}) { logger.Error(fmt.Sprintf(format, v...)) }
+func (raftLogger) Error(v ...interface{})                   { logger.Error(fmt.Sprint(v...)) }
+func (raftLogger) Errorf(format string, v ...interface{})   { logger.Error(fmt.Sprintf(format, v...)) }
+func (raftLogger) Fatal(v ...interface{})                   { panic(fmt.Sprint(v...)) }
+func (raftLogger) Fatalf(format string, v ...interface{})   { panic(fmt.Sprintf(format, v...)) }
+func (raftLogger) Panic(v ...interface{})                   { panic(fmt.Sprint(v...)) }
+func (raftLogger) Panicf(format string, v ...interface{})   { panic(fmt.Sprintf(format, v...)) }
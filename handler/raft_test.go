@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// newReplicatedHandler returns a handler holding only the state replicated
+// by the Raft log.
+func newReplicatedHandler(t *testing.T) *Handler {
+	info := conf.DefaultMempoolInfo()
+	info.RootDir = t.TempDir()
+	pool, _, err := newMempool(info)
+	require.NoError(t, err)
+	return &Handler{
+		Mempool:     pool,
+		statuses:    NewTxStatusIndex(),
+		estimator:   mempool.NewFeeEstimator(pool),
+		leases:      newLeases(),
+		admissions:  newAdmissions(),
+		invocations: newInvocations(),
+	}
+}
+
+type testReplica struct {
+	h      *Handler
+	info   *conf.RaftInfo
+	server *grpc.Server
+}
+
+// newTestRaftCert writes a self-signed TLS certificate named cn and its key
+// to dir, it returns their paths.
+func newTestRaftCert(t *testing.T, dir, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath := filepath.Join(dir, cn+".crt"), filepath.Join(dir, cn+".key")
+	require.NoError(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certPath, keyPath
+}
+
+func (r *testReplica) stop() {
+	r.h.replica.Stop()
+	r.server.Stop()
+}
+
+// newTestCluster starts a cluster of n replicas in process, the data of
+// replica i is kept in dirs[i].
+func newTestCluster(t *testing.T, dirs []string) []*testReplica {
+	listeners := make([]net.Listener, len(dirs))
+	peers := make([]*conf.RaftPeerInfo, len(dirs))
+	keys := make([]string, len(dirs))
+	for i, dir := range dirs {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		listeners[i] = lis
+		cert, key := newTestRaftCert(t, dir, fmt.Sprintf("replica%d", i+1))
+		peers[i] = &conf.RaftPeerInfo{ID: uint64(i + 1), Addr: lis.Addr().String(), Cert: cert}
+		keys[i] = key
+	}
+
+	replicas := make([]*testReplica, len(dirs))
+	for i, dir := range dirs {
+		info := conf.DefaultRaftInfo()
+		info.ID = uint64(i + 1)
+		info.Peers = peers
+		info.ListenAddr = peers[i].Addr
+		info.Key = keys[i]
+		info.DataDir = dir
+		info.TickInterval = 10 * time.Millisecond
+		info.SnapshotEntries = 4
+
+		h := newReplicatedHandler(t)
+		replica, err := NewReplica(info, h)
+		require.NoError(t, err)
+		h.replica = replica
+		server := h.NewRaftServer()
+		go server.Serve(listeners[i])
+		replica.Start()
+		replicas[i] = &testReplica{h: h, info: info, server: server}
+	}
+	t.Cleanup(func() {
+		for _, r := range replicas {
+			if r != nil {
+				r.stop()
+			}
+		}
+	})
+	return replicas
+}
+
+// leader waits for the replicas to agree on a leader and returns it.
+func leader(t *testing.T, replicas []*testReplica) *testReplica {
+	var lead *testReplica
+	require.Eventually(t, func() bool {
+		id := replicas[0].h.replica.Leader()
+		for _, r := range replicas {
+			if id == 0 || r.h.replica.Leader() != id {
+				return false
+			}
+			if r.info.ID == id {
+				lead = r
+			}
+		}
+		return lead != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	return lead
+}
+
+func follower(replicas []*testReplica, lead *testReplica) *testReplica {
+	for _, r := range replicas {
+		if r != lead {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestReplicaProposeOnFollower(t *testing.T) {
+	replicas := newTestCluster(t, []string{t.TempDir(), t.TempDir(), t.TempDir()})
+	lead := leader(t, replicas)
+	f := follower(replicas, lead)
+
+	creator := newTestCA(t).issue(t, "Org1MSP", "user1")
+	tx := newCreatedTx(t, "tx1", creator)
+	_, err := f.h.SubmitTransaction(context.Background(), &pb.EndorsedTransaction{Tx: tx})
+	require.NoError(t, err)
+	// the proposer returns once it applied the entry, the others follow
+	for _, r := range replicas {
+		r := r
+		require.Eventually(t, func() bool {
+			_, ok := r.h.Mempool.TxByID("tx1")
+			return ok
+		}, 5*time.Second, 10*time.Millisecond, "replica %d", r.info.ID)
+	}
+	assert.Equal(t, TxPending, f.h.statuses.Get("tx1").State)
+
+	// a tx rejected by CheckTx is returned to the proposer
+	_, err = f.h.SubmitTransaction(context.Background(), &pb.EndorsedTransaction{Tx: tx})
+	assert.Equal(t, mempool.ErrTxInCache, err)
+}
+
+func TestReplicaFetchOnlyOnLeader(t *testing.T) {
+	replicas := newTestCluster(t, []string{t.TempDir(), t.TempDir(), t.TempDir()})
+	lead := leader(t, replicas)
+	f := follower(replicas, lead)
+
+	ftx := &pb.FetchTxsRequest{Requester: "orderer0"}
+	_, err := f.h.fetch(context.Background(), ftx, 0, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not the leader of the mempool replicas")
+	_, err = f.h.TopFees(context.Background(), &protos.TopFeesRequest{Requester: "orderer0"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not the leader of the mempool replicas")
+}
+
+func TestRaftServerAcceptsPeersOnly(t *testing.T) {
+	replicas := newTestCluster(t, []string{t.TempDir()})
+	leader(t, replicas)
+	addr := replicas[0].info.Peers[0].Addr
+
+	step := func(opt grpc.DialOption) error {
+		conn, err := grpc.Dial(addr, opt)
+		require.NoError(t, err)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = protos.NewRaftClient(conn).Step(ctx, &protos.RaftMessage{})
+		return err
+	}
+
+	withCert := func(certPath, keyPath string) grpc.DialOption {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		require.NoError(t, err)
+		return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			Certificates:       []tls.Certificate{cert},
+			InsecureSkipVerify: true,
+		}))
+	}
+
+	assert.NoError(t, step(withCert(replicas[0].info.Peers[0].Cert, replicas[0].info.Key)))
+	assert.Error(t, step(grpc.WithInsecure()), "without TLS")
+	assert.Error(t, step(withCert(newTestRaftCert(t, t.TempDir(), "stranger"))), "with the cert of another replica")
+}
+
+func TestApplyVerifiesCancel(t *testing.T) {
+	ca := newTestCA(t)
+	creator, other := ca.issue(t, "Org1MSP", "user1"), ca.issue(t, "Org1MSP", "user2")
+	h := newReplicatedHandler(t)
+	_, err := h.Apply(&protos.RaftEntry{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{newCreatedTx(t, "tx1", creator)}})
+	require.NoError(t, err)
+
+	request := func(signer *Crypto, signedAt time.Time) *protos.CancelRequest {
+		req := &protos.CancelRequest{TxId: "tx1", Timestamp: signedAt.Unix(), Creator: creator.Creator}
+		signature, err := signer.Sign(CancelMessage(req.TxId, req.Timestamp))
+		require.NoError(t, err)
+		req.Signature = signature
+		return req
+	}
+	now := time.Now()
+
+	// a replica doesn't trust the verification of the proposer
+	_, err = h.Apply(&protos.RaftEntry{Type: protos.RaftEntryType_CANCEL, Cancel: request(other, now), Timestamp: now.UnixNano()})
+	assert.Error(t, err)
+	_, err = h.Apply(&protos.RaftEntry{Type: protos.RaftEntryType_CANCEL, Timestamp: now.UnixNano()})
+	assert.EqualError(t, err, "cancel entry without its request")
+	// the window is checked as of the proposal
+	_, err = h.Apply(&protos.RaftEntry{Type: protos.RaftEntryType_CANCEL, Cancel: request(creator, now),
+		Timestamp: now.Add(2 * CancelTimeWindow).UnixNano()})
+	assert.Error(t, err)
+	_, ok := h.Mempool.TxByID("tx1")
+	assert.True(t, ok)
+
+	resp, err := h.Apply(&protos.RaftEntry{Type: protos.RaftEntryType_CANCEL, Cancel: request(creator, now), Timestamp: now.UnixNano()})
+	require.NoError(t, err)
+	assert.Equal(t, protos.CancelResult_CANCELLED, resp.(*protos.CancelResponse).Result)
+}
+
+func TestReplicaRestartFromWAL(t *testing.T) {
+	dir := t.TempDir()
+	replicas := newTestCluster(t, []string{dir})
+	leader(t, replicas)
+
+	creator := newTestCA(t).issue(t, "Org1MSP", "user1")
+	// more entries than SnapshotEntries, so the restart restores a snapshot
+	// and replays the entries logged after it
+	var txs types.Txs
+	for _, txId := range []string{"tx1", "tx2", "tx3", "tx4", "tx5", "tx6"} {
+		tx := newCreatedTx(t, txId, creator)
+		txs = append(txs, tx)
+		_, err := replicas[0].h.SubmitTransaction(context.Background(), &pb.EndorsedTransaction{Tx: tx})
+		require.NoError(t, err)
+	}
+	_, err := replicas[0].h.replicate(context.Background(), &protos.RaftEntry{
+		Type: protos.RaftEntryType_LEASE, Txs: [][]byte{txs[5]}, Requester: "orderer0",
+	})
+	require.NoError(t, err)
+	replicas[0].stop()
+	replicas[0] = nil
+
+	restarted := newTestCluster(t, []string{dir})
+	leader(t, restarted)
+	h := restarted[0].h
+	require.Eventually(t, func() bool { return h.Mempool.Size() == len(txs) }, 5*time.Second, 10*time.Millisecond)
+	h.leases.Lock()
+	assert.Equal(t, "orderer0", h.leases.txs[mempool.TxKey(txs[5])])
+	h.leases.Unlock()
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	creator := newTestCA(t).issue(t, "Org1MSP", "user1")
+	tx1, tx2, tx3, tx4 := newCreatedTx(t, "tx1", creator), newCreatedTx(t, "tx2", creator),
+		newCreatedTx(t, "tx3", creator), newCreatedTx(t, "tx4", creator)
+
+	h := newReplicatedHandler(t)
+	for _, entry := range []*protos.RaftEntry{
+		{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{tx1}},
+		{Type: protos.RaftEntryType_ADMIT_BUNDLE, Txs: [][]byte{tx2, tx3}},
+		{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{tx4}},
+		{Type: protos.RaftEntryType_LEASE, Txs: [][]byte{tx1, tx2}, Requester: "orderer0"},
+		{Type: protos.RaftEntryType_LEASE, Txs: [][]byte{tx3}, Requester: "orderer1"},
+		{Type: protos.RaftEntryType_REMOVE, Txs: [][]byte{tx4}, BlockHeight: 1},
+	} {
+		_, err := h.Apply(entry)
+		require.NoError(t, err)
+	}
+	data, err := h.Snapshot()
+	require.NoError(t, err)
+
+	restored := newReplicatedHandler(t)
+	require.NoError(t, restored.Restore(data))
+	assert.Equal(t, 3, restored.Mempool.Size())
+	for _, txId := range []string{"tx1", "tx2", "tx3"} {
+		_, ok := restored.Mempool.TxByID(txId)
+		assert.True(t, ok, txId)
+	}
+	_, ok := restored.Mempool.TxByID("tx4")
+	assert.False(t, ok, "tx4 was committed before the snapshot")
+	assert.Equal(t, map[[mempool.TxKeySize]byte]string{
+		mempool.TxKey(tx1): "orderer0",
+		mempool.TxKey(tx2): "orderer0",
+		mempool.TxKey(tx3): "orderer1",
+	}, restored.leases.txs)
+	admitted, restoredAdmitted := h.admissions.list(), restored.admissions.list()
+	require.Len(t, restoredAdmitted, len(admitted))
+	for i := range admitted {
+		assert.True(t, proto.Equal(admitted[i], restoredAdmitted[i]), "admission %d", i)
+	}
+
+	// restoring replaces the state
+	require.NoError(t, restored.Restore(data))
+	assert.Equal(t, 3, restored.Mempool.Size())
+	assert.Len(t, restored.admissions.list(), 2)
+}
+
+func TestAdmissionsRemoveBundle(t *testing.T) {
+	creator := newTestCA(t).issue(t, "Org1MSP", "user1")
+	tx1, tx2, tx3 := newCreatedTx(t, "tx1", creator), newCreatedTx(t, "tx2", creator), newCreatedTx(t, "tx3", creator)
+	single := &protos.RaftEntry{Type: protos.RaftEntryType_ADMIT, Txs: [][]byte{tx1}}
+	bundle := &protos.RaftEntry{Type: protos.RaftEntryType_ADMIT_BUNDLE, Txs: [][]byte{tx2, tx3}}
+
+	a := newAdmissions()
+	a.add(types.Txs{tx1}, single)
+	a.add(types.Txs{tx2, tx3}, bundle)
+	assert.Equal(t, []*protos.RaftEntry{single, bundle}, a.list())
+
+	// any tx of a bundle removes the whole bundle
+	a.remove(types.Txs{tx3})
+	assert.Equal(t, []*protos.RaftEntry{single}, a.list())
+	assert.NotContains(t, a.txIDs, "tx2")
+	assert.NotContains(t, a.txIDs, "tx3")
+
+	a.add(types.Txs{tx2, tx3}, bundle)
+	a.forget("tx2")
+	assert.Equal(t, []*protos.RaftEntry{single}, a.list())
+	a.forget("tx1")
+	assert.Empty(t, a.list())
+	assert.Empty(t, a.entries)
+	assert.Empty(t, a.txIDs)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"google.golang.org/grpc/credentials"
+)
+
+// raftTLS authenticates the replicas to each other by mutual TLS. The
+// certificates of the peers are pinned rather than chained to a CA, so a
+// replica accepts the peers of the cluster only, whatever name they are
+// reached at.
+type raftTLS struct {
+	cert  tls.Certificate
+	peers map[uint64][]byte // DER certificate by raft id
+}
+
+func newRaftTLS(info *conf.RaftInfo) (*raftTLS, error) {
+	t := &raftTLS{peers: make(map[uint64][]byte, len(info.Peers))}
+	var certPEM []byte
+	for _, peer := range info.Peers {
+		bz, err := ioutil.ReadFile(peer.Cert)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not read the cert of raft peer %d", peer.ID)
+		}
+		block, _ := pem.Decode(bz)
+		if block == nil {
+			return nil, errors.Errorf("invalid cert of raft peer %d", peer.ID)
+		}
+		t.peers[peer.ID] = block.Bytes
+		if peer.ID == info.ID {
+			certPEM = bz
+		}
+	}
+	keyPEM, err := ioutil.ReadFile(info.Key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not read the raft key")
+	}
+	if t.cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, errors.WithMessage(err, "invalid raft key pair")
+	}
+	return t, nil
+}
+
+// verify accepts the certificate of one of the peers ids.
+func (t *raftTLS) verify(ids ...uint64) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no raft peer certificate")
+		}
+		for _, id := range ids {
+			if bytes.Equal(rawCerts[0], t.peers[id]) {
+				return nil
+			}
+		}
+		return errors.New("not the certificate of a raft peer")
+	}
+}
+
+// serverCredentials accepts the connections of the peers.
+func (t *raftTLS) serverCredentials() credentials.TransportCredentials {
+	ids := make([]uint64, 0, len(t.peers))
+	for id := range t.peers {
+		ids = append(ids, id)
+	}
+	return credentials.NewTLS(&tls.Config{
+		Certificates:          []tls.Certificate{t.cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		VerifyPeerCertificate: t.verify(ids...),
+		MinVersion:            tls.VersionTLS12,
+	})
+}
+
+// clientCredentials connects to the peer id only.
+func (t *raftTLS) clientCredentials(id uint64) credentials.TransportCredentials {
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{t.cert},
+		// the pinned certificate is verified instead of the name and the CA
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: t.verify(id),
+		MinVersion:            tls.VersionTLS12,
+	})
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+	"google.golang.org/grpc"
+)
+
+var _ StateMachine = (*Handler)(nil)
+
+// replicate applies a change to the mempool. With replicas it is proposed to
+// the Raft log and applied by every replica, as of the time it is proposed.
+func (h *Handler) replicate(ctx context.Context, entry *protos.RaftEntry) (interface{}, error) {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().UnixNano()
+	}
+	if h.replica == nil {
+		return h.Apply(entry)
+	}
+	return h.replica.Propose(ctx, entry)
+}
+
+// entryTime returns the time entry was proposed at, the local time for the
+// entries proposed without.
+func entryTime(entry *protos.RaftEntry) time.Time {
+	if entry.Timestamp == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, entry.Timestamp)
+}
+
+// Apply implements StateMachine. The mempool checks entries against the time
+// they were proposed at, so that every replica applies them alike.
+func (h *Handler) Apply(entry *protos.RaftEntry) (interface{}, error) {
+	txs := make(types.Txs, len(entry.Txs))
+	for i, tx := range entry.Txs {
+		txs[i] = tx
+	}
+	now := entryTime(entry)
+
+	switch entry.Type {
+	case protos.RaftEntryType_ADMIT:
+		if len(txs) != 1 {
+			return nil, errors.Errorf("admit entry with %d txs", len(txs))
+		}
+		txInfo := mempool.TxInfo{NotBeforeHeight: int64(entry.NotBeforeHeight), Now: now}
+		if entry.NotBefore > 0 {
+			txInfo.NotBefore = time.Unix(entry.NotBefore, 0)
+		}
+		if err := h.Mempool.CheckTx(txs[0], nil, txInfo); err != nil {
+			h.rejected(entry, err)
+			return nil, err
+		}
+		h.admitted(txs, entry)
+		return nil, nil
+
+	case protos.RaftEntryType_ADMIT_BUNDLE:
+		if err := h.Mempool.CheckBundle(txs, time.Duration(entry.Ttl), mempool.TxInfo{Now: now}); err != nil {
+			h.rejected(entry, err)
+			return nil, err
+		}
+		h.admitted(txs, entry)
+		return nil, nil
+
+	case protos.RaftEntryType_LEASE:
+		h.leases.Lock()
+		h.leases.add(txs, entry.Requester)
+		h.leases.Unlock()
+		return nil, nil
+
+	case protos.RaftEntryType_REMOVE:
+		err := h.Mempool.UpdateAt(now, int64(entry.BlockHeight), txs, nil, nil, nil)
+		h.leases.release(txs)
+		if h.admissions != nil {
+			h.admissions.remove(txs)
+		}
+		return nil, err
+
+	case protos.RaftEntryType_RELEASE:
+		h.leases.release(txs)
+		return nil, nil
+
+	case protos.RaftEntryType_CANCEL:
+		if entry.Cancel == nil {
+			return nil, errors.New("cancel entry without its request")
+		}
+		resp, err := h.cancel(entry.Cancel, now)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Result == protos.CancelResult_CANCELLED && h.admissions != nil {
+			h.admissions.forget(entry.Cancel.TxId)
+		}
+		return resp, nil
+	}
+	return nil, errors.Errorf("unknown raft entry type %d", entry.Type)
+}
+
+// rejected logs an admission entry the mempool rejected. The checks depend on
+// the cache and the occupancy of each replica, so the other
+// replicas may have admitted the txs of entry: the mempools diverge until the
+// txs are committed or expire. Txs already seen are rejected by every replica.
+func (h *Handler) rejected(entry *protos.RaftEntry, err error) {
+	if h.replica == nil || err == mempool.ErrTxInCache {
+		return
+	}
+	logger.Error("Rejected raft admission entry, the mempool replicas may diverge", "replica", h.replica.info.ID,
+		"proposer", entry.Replica, "type", entry.Type, "txs", len(entry.Txs), "error", err)
+}
+
+// admitted records the txs of entry admitted to the mempool.
+func (h *Handler) admitted(txs types.Txs, entry *protos.RaftEntry) {
+	for _, tx := range txs {
+		h.estimator.TxAdmitted(tx)
+		if txId, err := protoutil.GetOrComputeTxIDFromEnvelope(tx); err == nil {
+			h.statuses.SetState(txId, TxPending)
+		}
+	}
+	if h.admissions != nil {
+		h.admissions.add(txs, entry)
+	}
+}
+
+// Snapshot implements StateMachine. The snapshot holds the admission entries
+// of the pending txs, followed by the leases of the orderers.
+func (h *Handler) Snapshot() ([]byte, error) {
+	snapshot := &protos.RaftSnapshot{}
+	for _, entry := range h.admissions.list() {
+		pending := true
+		for _, tx := range entry.Txs {
+			txId, err := protoutil.GetOrComputeTxIDFromEnvelope(tx)
+			if _, ok := h.Mempool.TxByID(txId); err != nil || !ok {
+				pending = false
+				break
+			}
+		}
+		if !pending {
+			// expired, rechecked out or committed meanwhile
+			h.admissions.remove(types.Txs{entry.Txs[0]})
+			continue
+		}
+		snapshot.Entries = append(snapshot.Entries, entry)
+	}
+
+	h.leases.Lock()
+	leased := make(map[string]*protos.RaftEntry)
+	for _, entry := range snapshot.Entries {
+		for _, tx := range entry.Txs {
+			orderer, ok := h.leases.txs[mempool.TxKey(tx)]
+			if !ok {
+				continue
+			}
+			lease, ok := leased[orderer]
+			if !ok {
+				lease = &protos.RaftEntry{Type: protos.RaftEntryType_LEASE, Requester: orderer}
+				leased[orderer] = lease
+			}
+			lease.Txs = append(lease.Txs, tx)
+		}
+	}
+	h.leases.Unlock()
+	for _, lease := range leased {
+		snapshot.Entries = append(snapshot.Entries, lease)
+	}
+
+	return proto.Marshal(snapshot)
+}
+
+// Restore implements StateMachine.
+func (h *Handler) Restore(data []byte) error {
+	snapshot := &protos.RaftSnapshot{}
+	if err := proto.Unmarshal(data, snapshot); err != nil {
+		return err
+	}
+
+	h.Mempool.Flush()
+	h.leases.Lock()
+	h.leases.txs = make(map[[mempool.TxKeySize]byte]string)
+	h.leases.Unlock()
+	h.admissions.reset()
+
+	for _, entry := range snapshot.Entries {
+		if _, err := h.Apply(entry); err != nil {
+			logger.Error("Could not restore raft entry", "type", entry.Type, "error", err)
+		}
+	}
+	logger.Info("Restored mempool from raft snapshot", "entries", len(snapshot.Entries), "mempool", h.Mempool.Size())
+	return nil
+}
+
+// NewRaftServer returns the server receiving the messages of the other
+// mempool replicas, nil unless raft replication is enabled. It must listen
+// on the listenAddr of the raft section rather than with the mempool service.
+func (h *Handler) NewRaftServer() *grpc.Server {
+	if h.replica == nil {
+		return nil
+	}
+	return h.replica.NewServer(h)
+}
+
+// Step implements the Raft service, receiving the messages of the other
+// mempool replicas.
+func (h *Handler) Step(ctx context.Context, msg *protos.RaftMessage) (*protos.RaftResponse, error) {
+	if h.replica == nil {
+		return nil, errors.New("raft replication is disabled")
+	}
+	return h.replica.Step(ctx, msg)
+}
+
+// admissions keeps the admission entries of the txs pending in the mempool
+// for the snapshots of the Raft log. It is only used by the Raft apply loop.
+type admissions struct {
+	seq     uint64
+	entries map[[mempool.TxKeySize]byte]*admission // txKey -> admission
+	txIDs   map[string][mempool.TxKeySize]byte     // txId -> txKey
+}
+
+type admission struct {
+	seq   uint64
+	entry *protos.RaftEntry
+}
+
+func newAdmissions() *admissions {
+	return &admissions{
+		entries: make(map[[mempool.TxKeySize]byte]*admission),
+		txIDs:   make(map[string][mempool.TxKeySize]byte),
+	}
+}
+
+func (a *admissions) add(txs types.Txs, entry *protos.RaftEntry) {
+	a.seq++
+	adm := &admission{seq: a.seq, entry: entry}
+	for _, tx := range txs {
+		key := mempool.TxKey(tx)
+		a.entries[key] = adm
+		if txId, err := protoutil.GetOrComputeTxIDFromEnvelope(tx); err == nil {
+			a.txIDs[txId] = key
+		}
+	}
+}
+
+// remove forgets the admissions of txs, all txs of a bundle are forgotten
+// with any of them.
+func (a *admissions) remove(txs types.Txs) {
+	for _, tx := range txs {
+		adm, ok := a.entries[mempool.TxKey(tx)]
+		if !ok {
+			continue
+		}
+		for _, admitted := range adm.entry.Txs {
+			delete(a.entries, mempool.TxKey(admitted))
+			if txId, err := protoutil.GetOrComputeTxIDFromEnvelope(admitted); err == nil {
+				delete(a.txIDs, txId)
+			}
+		}
+	}
+}
+
+// forget removes the admission of the tx with txId.
+func (a *admissions) forget(txId string) {
+	key, ok := a.txIDs[txId]
+	if !ok {
+		return
+	}
+	if adm, ok := a.entries[key]; ok {
+		a.remove(types.Txs{adm.entry.Txs[0]})
+	}
+}
+
+func (a *admissions) reset() {
+	a.entries = make(map[[mempool.TxKeySize]byte]*admission)
+	a.txIDs = make(map[string][mempool.TxKeySize]byte)
+}
+
+// list returns the admission entries in the order they were applied.
+func (a *admissions) list() []*protos.RaftEntry {
+	seen := make(map[*admission]bool, len(a.entries))
+	list := make([]*admission, 0, len(a.entries))
+	for _, adm := range a.entries {
+		if !seen[adm] {
+			seen[adm] = true
+			list = append(list, adm)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].seq < list[j].seq })
+
+	entries := make([]*protos.RaftEntry, len(list))
+	for i, adm := range list {
+		entries[i] = adm.entry
+	}
+	return entries
+}
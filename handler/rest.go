@@ -2,6 +2,8 @@ package handler
 
 import (
 	keyrand "crypto/rand"
+	"io"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"strconv"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protos"
 	"github.com/tylerztl/fabric-mempool/protoutil/btckey"
 )
 
@@ -35,14 +38,17 @@ func NewRestHandler(config *conf.DistributeConfig, handler *Handler) *RestHandle
 	}
 }
 
-// changeDistribute change distribution type
+// changeDistribute change distribution strategy, e.g. {"strategy":"stake","stakes":{"orderer":2}}
 func (h *RestHandler) changeDistribute(ctx *gin.Context) {
 	config := &conf.DistributeConfig{}
 	if err := ctx.ShouldBindJSON(config); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
 		return
 	}
-	h.handler.ChangeDistribute(config)
+	if err := h.handler.ChangeDistribute(adminContext(ctx), config); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{})
 }
 
@@ -53,18 +59,21 @@ func (h *RestHandler) changeSortSwitch(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
 		return
 	}
-	h.handler.ChangeSortSwitch(config)
+	if err := h.handler.ChangeSortSwitch(adminContext(ctx), config); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
 	ctx.JSON(http.StatusOK, gin.H{})
 }
 
-// changeSortSwitch open/close mempool tx sort by fee.
+// changeOrdererCapacity pin the capacity of one orderer, or unpin it
 func (h *RestHandler) changeOrdererCapacity(ctx *gin.Context) {
 	config := &conf.OrdererCapacityConfig{}
 	if err := ctx.ShouldBindJSON(config); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
 		return
 	}
-	if err := h.handler.ChangeOrdererCapacity(config); err != nil {
+	if err := h.handler.ChangeOrdererCapacity(adminContext(ctx), config); err != nil {
 		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
 		return
 	}
@@ -87,6 +96,16 @@ func (h *RestHandler) getOrdererInfoList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": data})
 }
 
+// getPeerScores get the misbehaviour scores of the gossip peers
+func (h *RestHandler) getPeerScores(ctx *gin.Context) {
+	scores, err := h.handler.GetPeerScores()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": scores})
+}
+
 func (h *RestHandler) invoke(ctx *gin.Context) {
 	config := &conf.TransactionFee{}
 	if err := ctx.ShouldBindJSON(config); err != nil {
@@ -104,6 +123,205 @@ func (h *RestHandler) invoke(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"msg": "invoke success"})
 }
 
+// getMinFee get the current minimum fee of the mempool, so clients can price their fee limit
+func (h *RestHandler) getMinFee(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": h.handler.GetMinFee()})
+}
+
+// estimateFee suggest a fee limit for a transaction to be fetched within the target rounds
+func (h *RestHandler) estimateFee(ctx *gin.Context) {
+	target, err := strconv.Atoi(ctx.DefaultQuery("target", "1"))
+	if err != nil || target < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": h.handler.estimator.EstimateFee(target)})
+}
+
+// submitBundle add several transactions which are fetched together and consecutively
+func (h *RestHandler) submitBundle(ctx *gin.Context) {
+	req := &protos.BundleRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+	resp, err := h.handler.SubmitBundle(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": resp})
+}
+
+// submitTimeLocked add a transaction which is not fetched before the given time and block height
+func (h *RestHandler) submitTimeLocked(ctx *gin.Context) {
+	req := &protos.TimeLockedTransaction{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+	resp, err := h.handler.SubmitTimeLocked(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": resp})
+}
+
+// addOrderer add an orderer at runtime, the TLS CA cert is base64 encoded PEM
+func (h *RestHandler) addOrderer(ctx *gin.Context) {
+	req := &protos.AddOrdererRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+	status, err := h.handler.AddOrderer(adminContext(ctx), req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": ordererStatus(status)})
+}
+
+// drainOrderer stop one orderer fetching transactions
+func (h *RestHandler) drainOrderer(ctx *gin.Context) {
+	status, err := h.handler.DrainOrderer(adminContext(ctx), &protos.OrdererRequest{Name: ctx.Param("name")})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": ordererStatus(status)})
+}
+
+// removeOrderer drain and disconnect one orderer
+func (h *RestHandler) removeOrderer(ctx *gin.Context) {
+	status, err := h.handler.RemoveOrderer(adminContext(ctx), &protos.OrdererRequest{Name: ctx.Param("name")})
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": ordererStatus(status)})
+}
+
+func ordererStatus(status *protos.OrdererStatus) gin.H {
+	return gin.H{
+		"name":        status.Name,
+		"addr":        status.Addr,
+		"state":       status.State.String(),
+		"capacity":    status.Capacity,
+		"fetched_txs": status.FetchedTxs,
+		"fee_reward":  status.FeeReward,
+		"health":      status.Health,
+		"reconnects":  status.Reconnects,
+		"last_error":  status.LastError,
+	}
+}
+
+// cancelTransaction remove a pending transaction on request of its creator
+func (h *RestHandler) cancelTransaction(ctx *gin.Context) {
+	req := &protos.CancelRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+	resp, err := h.handler.CancelTransaction(ctx, req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": gin.H{
+		"result":  resp.Result.String(),
+		"orderer": resp.Orderer,
+		"state":   resp.State,
+	}})
+}
+
+// getRewards get the fee credits, of one orderer and in a RFC3339 time range
+// when given
+func (h *RestHandler) getRewards(ctx *gin.Context) {
+	var from, to time.Time
+	var err error
+	if v := ctx.Query("from"); v != "" {
+		if from, err = time.Parse(time.RFC3339, v); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+			return
+		}
+	}
+	if v := ctx.Query("to"); v != "" {
+		if to, err = time.Parse(time.RFC3339, v); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+			return
+		}
+	}
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "params not valid"})
+		return
+	}
+
+	credits, err := h.handler.GetRewards(ctx.Query("orderer"), from, to, limit)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	total := new(big.Int)
+	for _, credit := range credits {
+		if amount, ok := new(big.Int).SetString(credit.Amount, 10); ok {
+			total.Add(total, amount)
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": gin.H{"credits": credits, "total": total.String()}})
+}
+
+// getRewardEpochs get the reward epochs with the totals of the orderers
+func (h *RestHandler) getRewardEpochs(ctx *gin.Context) {
+	epochs, err := h.handler.GetRewardEpochs()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": epochs})
+}
+
+// rolloverRewardEpoch end the current reward epoch
+func (h *RestHandler) rolloverRewardEpoch(ctx *gin.Context) {
+	epoch, err := h.handler.RolloverRewardEpoch(adminContext(ctx))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": epoch})
+}
+
+// getTxStatus get the latest status of one transaction
+func (h *RestHandler) getTxStatus(ctx *gin.Context) {
+	status, err := h.handler.GetTxStatus(ctx.Param("txid"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"msg": "operator success", "data": status})
+}
+
+// txEvents streams transaction status changes as server-sent events.
+func (h *RestHandler) txEvents(ctx *gin.Context) {
+	events, cancel := h.handler.SubscribeTxEvents()
+	defer cancel()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			ctx.SSEvent(string(event.State), event)
+			return true
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
 // Register register route info to gin
 func (h *RestHandler) Register(r *gin.Engine) {
 	r.POST("/allocation", h.changeDistribute)
@@ -111,5 +329,19 @@ func (h *RestHandler) Register(r *gin.Engine) {
 	r.POST("/capacity", h.changeOrdererCapacity)
 	//r.GET("/orderer/:sender", h.getOrdererLog)
 	r.GET("/orderers", h.getOrdererInfoList)
+	r.POST("/orderers", h.addOrderer)
+	r.POST("/orderers/:name/drain", h.drainOrderer)
+	r.DELETE("/orderers/:name", h.removeOrderer)
+	r.GET("/rewards", h.getRewards)
+	r.GET("/rewards/epochs", h.getRewardEpochs)
+	r.POST("/rewards/epochs", h.rolloverRewardEpoch)
+	r.GET("/p2p/peers", h.getPeerScores)
 	r.POST("/invoke", h.invoke)
+	r.GET("/fee/min", h.getMinFee)
+	r.GET("/fee/estimate", h.estimateFee)
+	r.POST("/bundle", h.submitBundle)
+	r.POST("/tx/timelocked", h.submitTimeLocked)
+	r.POST("/tx/cancel", h.cancelTransaction)
+	r.GET("/tx/:txid", h.getTxStatus)
+	r.GET("/events", h.txEvents)
 }
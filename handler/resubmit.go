@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"sync"
+
+	pbpeer "github.com/hyperledger/fabric/protos/peer"
+)
+
+// IsRetryable reports whether a transaction invalidated with code could
+// succeed if it was endorsed again. Read conflicts only mean the world state
+// moved on since endorsement, every other code is permanently bad.
+func IsRetryable(code pbpeer.TxValidationCode) bool {
+	switch code {
+	case pbpeer.TxValidationCode_MVCC_READ_CONFLICT,
+		pbpeer.TxValidationCode_PHANTOM_READ_CONFLICT:
+		return true
+	default:
+		return false
+	}
+}
+
+// ResubmitQueueSize bounds the number of invalidated txs waiting to be
+// endorsed again, the txs invalidated while it is full are not resubmitted.
+var ResubmitQueueSize = 1024
+
+// invocation holds everything needed to endorse a chaincode invocation again.
+type invocation struct {
+	channelID string
+	ccID      string
+	feeLimit  string
+	args      []string
+	retries   int
+}
+
+// invocations tracks the txs created by Handler.Invoke which are waiting for
+// their commit result.
+type invocations struct {
+	mtx sync.Mutex
+	txs map[string]*invocation
+}
+
+func newInvocations() *invocations {
+	return &invocations{txs: make(map[string]*invocation)}
+}
+
+func (i *invocations) add(txId string, inv *invocation) {
+	i.mtx.Lock()
+	i.txs[txId] = inv
+	i.mtx.Unlock()
+}
+
+// take removes and returns the invocation of txId, if any.
+func (i *invocations) take(txId string) *invocation {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	inv, ok := i.txs[txId]
+	if ok {
+		delete(i.txs, txId)
+	}
+	return inv
+}
+
+// onCommitted is called for every transaction reported in a committed block.
+// Retryable txs created by Invoke are re-endorsed and resubmitted if enabled,
+// settlement txs confirm the settlement of their reward epoch, and fetched
+// txs count for the inclusion ratio of their orderer.
+func (h *Handler) onCommitted(txId string, blockNum uint64, code pbpeer.TxValidationCode) {
+	if h.settler != nil {
+		h.settler.committed(txId, blockNum, code)
+	}
+	if h.accountability != nil {
+		h.accountability.committed(txId)
+	}
+	// ignore txs which were never submitted through the mempool
+	if h.statuses.Get(txId) == nil {
+		return
+	}
+	status := h.statuses.SetValidation(txId, blockNum, code)
+
+	inv := h.invocations.take(txId)
+	if inv == nil || !status.Retryable {
+		return
+	}
+	policy := AppConf.Resubmit
+	if policy == nil || !policy.Auto || inv.retries >= policy.MaxRetries {
+		logger.Info("Invalidated transaction will not be resubmitted", "txId", txId, "code", code, "retries", inv.retries)
+		return
+	}
+
+	// endorsing again takes round trips to the peers, which must not hold
+	// up the blocks delivered
+	select {
+	case h.resubmits <- &resubmission{txId: txId, blockNum: blockNum, code: code, inv: inv}:
+	default:
+		logger.Error("Resubmit queue is full, invalidated transaction will not be resubmitted", "txId", txId, "code", code)
+	}
+}
+
+// resubmission is an invalidated tx waiting to be endorsed again.
+type resubmission struct {
+	txId     string
+	blockNum uint64
+	code     pbpeer.TxValidationCode
+	inv      *invocation
+}
+
+// resubmitLoop endorses and submits again the queued invalidated txs, one at
+// a time.
+func (h *Handler) resubmitLoop() {
+	for r := range h.resubmits {
+		r.inv.retries++
+		newTxId, err := h.invoke(r.inv)
+		if err != nil {
+			logger.Error("failed to resubmit invalidated transaction", "txId", r.txId, "error", err)
+			continue
+		}
+		h.statuses.Update(r.txId, r.blockNum, func(status *TxStatus) {
+			status.State = TxResubmitted
+			status.Retries = r.inv.retries
+			status.ResubmittedAs = newTxId
+		})
+		logger.Info("Resubmitted invalidated transaction", "txId", r.txId, "newTxId", newTxId, "code", r.code, "retries", r.inv.retries)
+	}
+}
@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tm-db"
+	"github.com/tylerztl/fabric-mempool/conf"
+)
+
+// RewardCredit is a share of the fee of a tx credited to an orderer.
+type RewardCredit struct {
+	Seq     uint64 `json:"seq"`
+	Time    int64  `json:"time"` // unix nanoseconds
+	Epoch   uint64 `json:"epoch"`
+	TxId    string `json:"tx_id"`
+	Orderer string `json:"orderer"`
+	Amount  string `json:"amount"`
+	Rule    string `json:"rule"` // distribution strategy in effect
+}
+
+// RewardEpoch sums up the credits of every orderer over a period.
+type RewardEpoch struct {
+	Number uint64            `json:"number"`
+	Start  int64             `json:"start"`         // unix seconds
+	End    int64             `json:"end,omitempty"` // zero while current
+	Totals map[string]string `json:"totals"`
+
+	// on chain settlement of an ended epoch
+	SettleTxId      string `json:"settle_tx_id,omitempty"`
+	SettleSubmitted int64  `json:"settle_submitted,omitempty"` // unix seconds
+	Settled         bool   `json:"settled"`
+	SettledBlock    uint64 `json:"settled_block,omitempty"`
+}
+
+// Keys of the reward ledger.
+var (
+	rewardCreditPrefix  = []byte("c/") // time, seq -> credit
+	rewardOrdererPrefix = []byte("o/") // orderer, 0, time, seq -> credit key
+	rewardEpochPrefix   = []byte("e/") // number -> epoch
+	rewardTotalPrefix   = []byte("t/") // orderer -> lifetime total
+	rewardTxPrefix      = []byte("x/") // tx id, 0, kind -> credit key
+	rewardSeqKey        = []byte("m/seq")
+)
+
+// RewardLedger records durably the fee credited to the orderers, so that
+// their rewards survive restarts. The credits are indexed by time and by
+// orderer, and summed up by epoch.
+type RewardLedger struct {
+	mtx    sync.Mutex
+	db     dbm.DB
+	period time.Duration // zero rolls epochs over on request only
+
+	seq    uint64
+	epoch  *RewardEpoch
+	totals map[string]*big.Int // lifetime, by orderer
+
+	onRollover func(ended *RewardEpoch)
+}
+
+// NewRewardLedger loads the ledger from db, opening the first epoch of an
+// empty ledger.
+func NewRewardLedger(db dbm.DB, info *conf.RewardsInfo) (*RewardLedger, error) {
+	l := &RewardLedger{db: db, period: info.Epoch, totals: make(map[string]*big.Int)}
+
+	seq, err := db.Get(rewardSeqKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(seq) == 8 {
+		l.seq = binary.BigEndian.Uint64(seq)
+	}
+
+	it, err := db.ReverseIterator(rewardEpochPrefix, prefixEnd(rewardEpochPrefix))
+	if err != nil {
+		return nil, err
+	}
+	if it.Valid() {
+		l.epoch = &RewardEpoch{}
+		err = json.Unmarshal(it.Value(), l.epoch)
+	}
+	it.Close()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid reward epoch")
+	}
+	if l.epoch == nil {
+		l.epoch = &RewardEpoch{Number: 1, Start: time.Now().Unix(), Totals: map[string]string{}}
+		if err := l.putEpoch(db, l.epoch); err != nil {
+			return nil, err
+		}
+	}
+
+	it, err = db.Iterator(rewardTotalPrefix, prefixEnd(rewardTotalPrefix))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	for ; it.Valid(); it.Next() {
+		total, ok := new(big.Int).SetString(string(it.Value()), 10)
+		if !ok {
+			return nil, errors.Errorf("invalid reward total %q", it.Value())
+		}
+		l.totals[string(it.Key()[len(rewardTotalPrefix):])] = total
+	}
+	return l, nil
+}
+
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	end[len(end)-1]++
+	return end
+}
+
+func timeSeqKey(prefix []byte, t int64, seq uint64) []byte {
+	key := make([]byte, len(prefix)+16)
+	n := copy(key, prefix)
+	binary.BigEndian.PutUint64(key[n:], uint64(t))
+	binary.BigEndian.PutUint64(key[n+8:], seq)
+	return key
+}
+
+func ordererPrefix(orderer string) []byte {
+	return append(append(append([]byte{}, rewardOrdererPrefix...), orderer...), 0)
+}
+
+// txCreditKey marks txId credited by rule. The fee of a tx is credited once
+// whatever the rule which shared it out, and clawed back once.
+func txCreditKey(txId, rule string) []byte {
+	kind := "fee"
+	if rule == RuleClawback {
+		kind = RuleClawback
+	}
+	key := append(append(append([]byte{}, rewardTxPrefix...), txId...), 0)
+	return append(key, kind...)
+}
+
+func epochKey(number uint64) []byte {
+	key := make([]byte, len(rewardEpochPrefix)+8)
+	n := copy(key, rewardEpochPrefix)
+	binary.BigEndian.PutUint64(key[n:], number)
+	return key
+}
+
+type rewardWriter interface {
+	Set(key, value []byte) error
+}
+
+func (l *RewardLedger) putEpoch(w rewardWriter, epoch *RewardEpoch) error {
+	bz, err := json.Marshal(epoch)
+	if err != nil {
+		return err
+	}
+	return w.Set(epochKey(epoch.Number), bz)
+}
+
+// Credit records the shares of the fee of txId in one batch, rule is the
+// distribution strategy which shared it out. The fee of a tx already
+// credited is not credited again.
+func (l *RewardLedger) Credit(txId, rule string, shares map[string]*big.Int) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	txKey := txCreditKey(txId, rule)
+	credited, err := l.db.Has(txKey)
+	if err != nil {
+		return err
+	}
+	if credited {
+		return errors.Errorf("tx %s already credited", txId)
+	}
+
+	now := time.Now()
+	if err := l.rollIfDue(now); err != nil {
+		return err
+	}
+
+	batch := l.db.NewBatch()
+	defer batch.Close()
+	epochTotals := make(map[string]string, len(l.epoch.Totals))
+	for orderer, total := range l.epoch.Totals {
+		epochTotals[orderer] = total
+	}
+	totals := make(map[string]*big.Int, len(shares))
+	seq := l.seq
+	key := []byte{} // of the last credit
+	for orderer, share := range shares {
+		seq++
+		credit := &RewardCredit{
+			Seq:     seq,
+			Time:    now.UnixNano(),
+			Epoch:   l.epoch.Number,
+			TxId:    txId,
+			Orderer: orderer,
+			Amount:  share.String(),
+			Rule:    rule,
+		}
+		bz, err := json.Marshal(credit)
+		if err != nil {
+			return err
+		}
+		key = timeSeqKey(rewardCreditPrefix, credit.Time, seq)
+		if err := batch.Set(key, bz); err != nil {
+			return err
+		}
+		if err := batch.Set(timeSeqKey(ordererPrefix(orderer), credit.Time, seq), key); err != nil {
+			return err
+		}
+
+		epochTotal, _ := new(big.Int).SetString(epochTotals[orderer], 10)
+		if epochTotal == nil {
+			epochTotal = new(big.Int)
+		}
+		epochTotals[orderer] = epochTotal.Add(epochTotal, share).String()
+		totals[orderer] = new(big.Int).Add(l.total(orderer), share)
+		if err := batch.Set(append(append([]byte{}, rewardTotalPrefix...), orderer...), []byte(totals[orderer].String())); err != nil {
+			return err
+		}
+	}
+	epoch := *l.epoch
+	epoch.Totals = epochTotals
+	if err := l.putEpoch(batch, &epoch); err != nil {
+		return err
+	}
+	if err := batch.Set(txKey, key); err != nil {
+		return err
+	}
+	seqBz := make([]byte, 8)
+	binary.BigEndian.PutUint64(seqBz, seq)
+	if err := batch.Set(rewardSeqKey, seqBz); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	l.seq = seq
+	l.epoch = &epoch
+	for orderer, total := range totals {
+		l.totals[orderer] = total
+	}
+	return nil
+}
+
+func (l *RewardLedger) total(orderer string) *big.Int {
+	if total, ok := l.totals[orderer]; ok {
+		return total
+	}
+	return new(big.Int)
+}
+
+// Total returns the lifetime reward of orderer.
+func (l *RewardLedger) Total(orderer string) *big.Int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return new(big.Int).Set(l.total(orderer))
+}
+
+// Query returns the credits in [from, to), of orderer unless it is empty,
+// oldest first and up to limit of them when limit is positive.
+func (l *RewardLedger) Query(orderer string, from, to time.Time, limit int) ([]*RewardCredit, error) {
+	prefix := rewardCreditPrefix
+	if orderer != "" {
+		prefix = ordererPrefix(orderer)
+	}
+	start, end := prefix, prefixEnd(prefix)
+	if !from.IsZero() {
+		start = timeSeqKey(prefix, from.UnixNano(), 0)
+	}
+	if !to.IsZero() {
+		end = timeSeqKey(prefix, to.UnixNano(), 0)
+	}
+
+	it, err := l.db.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	credits := make([]*RewardCredit, 0)
+	for ; it.Valid() && (limit <= 0 || len(credits) < limit); it.Next() {
+		bz := it.Value()
+		if orderer != "" {
+			if bz, err = l.db.Get(bz); err != nil {
+				return nil, err
+			}
+		}
+		credit := &RewardCredit{}
+		if err := json.Unmarshal(bz, credit); err != nil {
+			return nil, errors.WithMessage(err, "invalid reward credit")
+		}
+		credits = append(credits, credit)
+	}
+	return credits, nil
+}
+
+// Epochs returns all the epochs, the current one last.
+func (l *RewardLedger) Epochs() ([]*RewardEpoch, error) {
+	l.mtx.Lock()
+	err := l.rollIfDue(time.Now())
+	l.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := l.db.Iterator(rewardEpochPrefix, prefixEnd(rewardEpochPrefix))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	epochs := make([]*RewardEpoch, 0)
+	for ; it.Valid(); it.Next() {
+		epoch := &RewardEpoch{}
+		if err := json.Unmarshal(it.Value(), epoch); err != nil {
+			return nil, errors.WithMessage(err, "invalid reward epoch")
+		}
+		epochs = append(epochs, epoch)
+	}
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i].Number < epochs[j].Number })
+	return epochs, nil
+}
+
+// Rollover ends the current epoch and opens the next one, it returns the
+// epoch ended.
+func (l *RewardLedger) Rollover() (*RewardEpoch, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.rollover(time.Now().Unix())
+}
+
+// rollIfDue rolls the epochs over when their period is elapsed, the epochs
+// keep their period even when no fee is credited for a while.
+func (l *RewardLedger) rollIfDue(now time.Time) error {
+	if l.period <= 0 {
+		return nil
+	}
+	period := int64(l.period / time.Second)
+	if period < 1 {
+		period = 1
+	}
+	elapsed := now.Unix() - l.epoch.Start
+	if elapsed < period {
+		return nil
+	}
+	_, err := l.rollover(l.epoch.Start + elapsed/period*period)
+	return err
+}
+
+func (l *RewardLedger) rollover(at int64) (*RewardEpoch, error) {
+	ended := *l.epoch
+	ended.End = at
+	next := &RewardEpoch{Number: ended.Number + 1, Start: at, Totals: map[string]string{}}
+
+	batch := l.db.NewBatch()
+	defer batch.Close()
+	if err := l.putEpoch(batch, &ended); err != nil {
+		return nil, err
+	}
+	if err := l.putEpoch(batch, next); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+	l.epoch = next
+	logger.Info("Rolled reward epoch over", "epoch", ended.Number, "start", ended.Start, "end", ended.End)
+	if l.onRollover != nil {
+		l.onRollover(&ended)
+	}
+	return &ended, nil
+}
+
+// OnRollover sets the function called with every epoch ended, it is called
+// with the ledger locked so must not block.
+func (l *RewardLedger) OnRollover(fn func(ended *RewardEpoch)) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.onRollover = fn
+}
+
+// Unsettled returns the ended epochs which are not settled yet, oldest
+// first.
+func (l *RewardLedger) Unsettled() ([]*RewardEpoch, error) {
+	epochs, err := l.Epochs()
+	if err != nil {
+		return nil, err
+	}
+	unsettled := make([]*RewardEpoch, 0)
+	for _, epoch := range epochs {
+		if epoch.End != 0 && !epoch.Settled {
+			unsettled = append(unsettled, epoch)
+		}
+	}
+	return unsettled, nil
+}
+
+// SubmitSettlement records the settlement tx of an ended epoch submitted at
+// the unix time at, waiting for its commit.
+func (l *RewardLedger) SubmitSettlement(number uint64, txId string, at int64) error {
+	return l.updateEpoch(number, func(epoch *RewardEpoch) {
+		epoch.SettleTxId, epoch.SettleSubmitted = txId, at
+		epoch.Settled, epoch.SettledBlock = false, 0
+	})
+}
+
+// SetSettlement records the settlement tx of an ended epoch, and whether
+// its commit is confirmed in block.
+func (l *RewardLedger) SetSettlement(number uint64, txId string, settled bool, block uint64) error {
+	return l.updateEpoch(number, func(epoch *RewardEpoch) {
+		epoch.SettleTxId = txId
+		epoch.Settled = settled
+		epoch.SettledBlock = block
+	})
+}
+
+func (l *RewardLedger) updateEpoch(number uint64, fn func(epoch *RewardEpoch)) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if number == l.epoch.Number {
+		return errors.Errorf("reward epoch %d is not ended", number)
+	}
+
+	bz, err := l.db.Get(epochKey(number))
+	if err != nil {
+		return err
+	} else if bz == nil {
+		return errors.Errorf("not found reward epoch %d", number)
+	}
+	epoch := &RewardEpoch{}
+	if err := json.Unmarshal(bz, epoch); err != nil {
+		return errors.WithMessage(err, "invalid reward epoch")
+	}
+	fn(epoch)
+	return l.putEpoch(l.db, epoch)
+}
+
+// GetRewards returns the fee credits of orderer, of all orderers when it is
+// empty, in the [from, to) time range.
+func (h *Handler) GetRewards(orderer string, from, to time.Time, limit int) ([]*RewardCredit, error) {
+	return h.rewards.Query(orderer, from, to, limit)
+}
+
+// GetRewardEpochs returns the reward epochs with the totals of the orderers.
+func (h *Handler) GetRewardEpochs() ([]*RewardEpoch, error) {
+	return h.rewards.Epochs()
+}
+
+// RolloverRewardEpoch ends the current reward epoch on request of an admin.
+func (h *Handler) RolloverRewardEpoch(ctx context.Context) (*RewardEpoch, error) {
+	admin, err := h.authorize(ctx, AdminRolloverRewardEpoch, nil)
+	if err != nil {
+		return nil, err
+	}
+	epoch, err := h.rewards.Rollover()
+	if err != nil {
+		return nil, err
+	}
+	h.audit(AuditRollover, map[string]interface{}{
+		"epoch":  epoch.Number,
+		"start":  epoch.Start,
+		"end":    epoch.End,
+		"totals": epoch.Totals,
+		"admin":  admin,
+	})
+	return epoch, nil
+}
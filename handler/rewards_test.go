@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+	"github.com/tylerztl/fabric-mempool/conf"
+)
+
+func TestRewardLedgerCreditsOnce(t *testing.T) {
+	db := dbm.NewMemDB()
+	ledger, err := NewRewardLedger(db, conf.DefaultRewardsInfo())
+	require.NoError(t, err)
+	shares := map[string]*big.Int{"orderer0": big.NewInt(10)}
+
+	require.NoError(t, ledger.Credit("tx1", conf.DistributeByEffort, shares))
+	// whatever the rule, even once reloaded
+	assert.EqualError(t, ledger.Credit("tx1", conf.DistributeEqually, shares), "tx tx1 already credited")
+	ledger, err = NewRewardLedger(db, conf.DefaultRewardsInfo())
+	require.NoError(t, err)
+	assert.EqualError(t, ledger.Credit("tx1", conf.DistributeByEffort, shares), "tx tx1 already credited")
+
+	// the clawback of a credited tx is recorded once
+	debit := map[string]*big.Int{"orderer0": big.NewInt(-10)}
+	require.NoError(t, ledger.Credit("tx1", RuleClawback, debit))
+	assert.Error(t, ledger.Credit("tx1", RuleClawback, debit))
+	assert.Equal(t, int64(0), ledger.Total("orderer0").Int64())
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protos"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+	"google.golang.org/grpc"
+)
+
+// Router serves the Mempool service in front of mempool shards, each holding
+// a partition of the txs. Txs are partitioned by TxKey or by channel, and
+// the fetches of the orderers are spread over the shards by fee.
+type Router struct {
+	info   *conf.RouterInfo
+	shards []*shardClient
+}
+
+type shardClient struct {
+	addr    string
+	conn    *grpc.ClientConn
+	mempool pb.MempoolClient
+	shard   protos.ShardClient
+}
+
+// NewRouter connects to the shards of info.
+func NewRouter(info *conf.RouterInfo) (*Router, error) {
+	r := &Router{info: info}
+	for _, addr := range info.Shards {
+		conn, err := grpc.Dial(addr, grpc.WithInsecure())
+		if err != nil {
+			r.Close()
+			return nil, errors.WithMessagef(err, "could not connect to shard %s", addr)
+		}
+		r.shards = append(r.shards, &shardClient{
+			addr:    addr,
+			conn:    conn,
+			mempool: pb.NewMempoolClient(conn),
+			shard:   protos.NewShardClient(conn),
+		})
+	}
+	logger.Info("Created mempool router", "shardBy", info.ShardBy, "shards", len(r.shards))
+	return r, nil
+}
+
+// Close closes the connections to the shards.
+func (r *Router) Close() {
+	for _, s := range r.shards {
+		s.conn.Close()
+	}
+}
+
+// SubmitTransaction implements the Mempool service, forwarding the tx to the
+// shard of its partition.
+func (r *Router) SubmitTransaction(ctx context.Context, etx *pb.EndorsedTransaction) (*pb.SubmitTxResponse, error) {
+	s, err := r.shardOf(etx.Tx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.info.ReqTimeout)
+	defer cancel()
+	return s.mempool.SubmitTransaction(ctx, etx)
+}
+
+// shardOf returns the shard holding the partition of tx.
+func (r *Router) shardOf(tx []byte) (*shardClient, error) {
+	var hash uint64
+	if r.info.ShardBy == conf.ShardByChannel {
+		env, err := protoutil.UnmarshalEnvelope(tx)
+		if err != nil {
+			return nil, err
+		}
+		channelID, err := protoutil.ChannelID(env)
+		if err != nil {
+			return nil, err
+		}
+		h := fnv.New64a()
+		h.Write([]byte(channelID))
+		hash = h.Sum64()
+	} else {
+		key := mempool.TxKey(tx)
+		hash = binary.BigEndian.Uint64(key[:8])
+	}
+	return r.shards[hash%uint64(len(r.shards))], nil
+}
+
+// FetchTransactions implements the Mempool service. The capacity of the
+// orderer is shared between the shards holding the txs with the highest
+// fees, and every shard broadcasts its share to the orderer. The signature
+// of the fetch request is passed on to the shards.
+func (r *Router) FetchTransactions(ctx context.Context, ftx *pb.FetchTxsRequest) (*pb.FetchTxsResponse, error) {
+	ctx, cancel := context.WithTimeout(forwardFetchSignature(ctx), r.info.ReqTimeout)
+	defer cancel()
+
+	tops := make([]*protos.TopFeesResponse, len(r.shards))
+	r.fanOut(func(i int, s *shardClient) {
+		top, err := s.shard.TopFees(ctx, &protos.TopFeesRequest{Requester: ftx.Requester, BlockHeight: ftx.BlockHeight})
+		if err != nil {
+			logger.Error("Could not get the top fees of shard", "shard", s.addr, "error", err)
+			return
+		}
+		tops[i] = top
+	})
+
+	capacity := 0
+	type shardUnit struct {
+		shard int
+		fee   int64
+		txs   int
+	}
+	var units []shardUnit
+	for i, top := range tops {
+		if top == nil {
+			continue
+		}
+		if int(top.Capacity) > capacity {
+			capacity = int(top.Capacity)
+		}
+		for j, fee := range top.Fees {
+			txs := 1
+			if j < len(top.UnitTxs) {
+				txs = int(top.UnitTxs[j])
+			}
+			units = append(units, shardUnit{shard: i, fee: fee, txs: txs})
+		}
+	}
+	if capacity == 0 {
+		return nil, errors.New("no shard of the mempool is available")
+	}
+
+	// a bundle is shared whole, as its shard reaps it whole or not at all
+	sort.SliceStable(units, func(i, j int) bool { return units[i].fee > units[j].fee })
+	shares := make([]int32, len(r.shards))
+	total := 0
+	for _, unit := range units {
+		if total+unit.txs > capacity {
+			continue
+		}
+		shares[unit.shard] += int32(unit.txs)
+		total += unit.txs
+	}
+
+	var mtx sync.Mutex
+	txNum := int32(0)
+	r.fanOut(func(i int, s *shardClient) {
+		if shares[i] == 0 {
+			return
+		}
+		resp, err := s.shard.FetchShare(ctx, &protos.FetchShareRequest{
+			Requester:   ftx.Requester,
+			BlockHeight: ftx.BlockHeight,
+			MaxTxs:      shares[i],
+		})
+		if err != nil {
+			logger.Error("Could not fetch the share of shard", "shard", s.addr, "share", shares[i], "error", err)
+			return
+		}
+		mtx.Lock()
+		txNum += resp.TxNum
+		mtx.Unlock()
+	})
+
+	logger.Info("Fetched unconfirmed transactions from shards", "OrdererName", ftx.Requester,
+		"actualTxs", txNum, "capacity", capacity, "shares", shares, "blockHeight", ftx.BlockHeight)
+	return &pb.FetchTxsResponse{TxNum: txNum, IsEmpty: int(txNum) < capacity}, nil
+}
+
+// fanOut calls f for every shard concurrently and waits for them.
+func (r *Router) fanOut(f func(i int, s *shardClient)) {
+	var wg sync.WaitGroup
+	for i, s := range r.shards {
+		wg.Add(1)
+		go func(i int, s *shardClient) {
+			defer wg.Done()
+			f(i, s)
+		}(i, s)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/common"
+	pbpeer "github.com/hyperledger/fabric/protos/peer"
+	putils "github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// errTxNotCommitted is returned by the lookup of a tx not in the ledger.
+var errTxNotCommitted = errors.New("transaction not committed")
+
+// Settler pays the rewards of the ended epochs out on chain. At every epoch
+// boundary it invokes the settlement chaincode with the totals of the epoch,
+// and marks the epoch settled once the settlement tx is committed valid.
+// An invalid settlement is submitted again. The settlements waiting for
+// their commit are kept in the reward ledger, one unconfirmed after the
+// timeout or a restart is looked up on the peer first, and submitted again
+// only if it is not committed valid.
+type Settler struct {
+	info    *conf.SettlementInfo
+	handler *Handler
+	ledger  *RewardLedger
+	// lookup returns the block and the validation code of a committed tx,
+	// errTxNotCommitted if it is not
+	lookup func(channelID, txId string) (uint64, pbpeer.TxValidationCode, error)
+
+	mtx     sync.Mutex
+	pending map[string]uint64 // epoch by txId
+	wake    chan struct{}
+	quit    chan struct{}
+}
+
+func NewSettler(info *conf.SettlementInfo, handler *Handler, ledger *RewardLedger) *Settler {
+	s := &Settler{
+		info:    info,
+		handler: handler,
+		ledger:  ledger,
+		lookup:  handler.txValidation,
+		pending: make(map[string]uint64),
+		wake:    make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+	ledger.OnRollover(func(*RewardEpoch) { s.notify() })
+	return s
+}
+
+// Start settles the ended epochs in the background until Stop is called.
+func (s *Settler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.info.Interval)
+		defer ticker.Stop()
+		for {
+			s.settle()
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Settler) Stop() {
+	close(s.quit)
+}
+
+func (s *Settler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// settle submits the settlement of every ended epoch which has none
+// waiting for its commit.
+func (s *Settler) settle() {
+	epochs, err := s.ledger.Unsettled()
+	if err != nil {
+		logger.Error("Could not list unsettled reward epochs", "error", err)
+		return
+	}
+	for _, epoch := range epochs {
+		if len(epoch.Totals) == 0 {
+			// nothing to pay out
+			if err := s.ledger.SetSettlement(epoch.Number, "", true, 0); err != nil {
+				logger.Error("Could not settle reward epoch", "epoch", epoch.Number, "error", err)
+			}
+			continue
+		}
+		if epoch.SettleTxId != "" {
+			// submitted by a previous run, its commit is still followed
+			s.track(epoch.SettleTxId, epoch.Number)
+			if time.Since(time.Unix(epoch.SettleSubmitted, 0)) < s.info.Timeout {
+				continue
+			}
+			if confirmed, err := s.confirm(epoch); err != nil {
+				logger.Error("Could not look reward epoch settlement up", "epoch", epoch.Number,
+					"txId", epoch.SettleTxId, "error", err)
+				continue
+			} else if confirmed {
+				continue
+			}
+		}
+		if err := s.submit(epoch); err != nil {
+			logger.Error("Could not submit reward epoch settlement", "epoch", epoch.Number, "error", err)
+		}
+	}
+}
+
+func (s *Settler) track(txId string, epoch uint64) {
+	s.mtx.Lock()
+	s.pending[txId] = epoch
+	s.mtx.Unlock()
+}
+
+func (s *Settler) untrack(txId string) {
+	s.mtx.Lock()
+	delete(s.pending, txId)
+	s.mtx.Unlock()
+}
+
+// confirm looks up the settlement of epoch unconfirmed after the timeout,
+// and records it settled if it is committed valid.
+func (s *Settler) confirm(epoch *RewardEpoch) (bool, error) {
+	txId := epoch.SettleTxId
+	blockNum, code, err := s.lookup(s.info.Channel, txId)
+	if err == errTxNotCommitted {
+		logger.Info("Reward epoch settlement unconfirmed, submitting it again", "epoch", epoch.Number, "txId", txId)
+		s.untrack(txId)
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	s.untrack(txId)
+	if code != pbpeer.TxValidationCode_VALID {
+		logger.Error("Reward epoch settlement invalidated, submitting it again", "epoch", epoch.Number, "txId", txId, "code", code)
+		return false, nil
+	}
+	if err := s.ledger.SetSettlement(epoch.Number, txId, true, blockNum); err != nil {
+		return false, err
+	}
+	logger.Info("Settled reward epoch", "epoch", epoch.Number, "txId", txId, "blockNum", blockNum)
+	return true, nil
+}
+
+func (s *Settler) submit(epoch *RewardEpoch) error {
+	totals, err := json.Marshal(epoch.Totals)
+	if err != nil {
+		return err
+	}
+	txId, err := s.handler.invoke(&invocation{
+		channelID: s.info.Channel,
+		ccID:      s.info.Chaincode,
+		feeLimit:  s.info.FeeLimit,
+		args:      []string{s.info.Function, strconv.FormatUint(epoch.Number, 10), string(totals)},
+	})
+	if err != nil {
+		return err
+	}
+	// the settlement is submitted again by the settler, not by the resubmit
+	// policy
+	s.handler.invocations.take(txId)
+
+	s.track(txId, epoch.Number)
+	if err := s.ledger.SubmitSettlement(epoch.Number, txId, time.Now().Unix()); err != nil {
+		return err
+	}
+	logger.Info("Submitted reward epoch settlement", "epoch", epoch.Number, "txId", txId, "totals", string(totals))
+	return nil
+}
+
+// committed settles the epoch of txId if it is a settlement tx committed
+// valid, an invalid settlement is submitted again.
+func (s *Settler) committed(txId string, blockNum uint64, code pbpeer.TxValidationCode) {
+	s.mtx.Lock()
+	epoch, ok := s.pending[txId]
+	delete(s.pending, txId)
+	s.mtx.Unlock()
+	if !ok {
+		return
+	}
+
+	if code != pbpeer.TxValidationCode_VALID {
+		logger.Error("Reward epoch settlement invalidated, submitting it again", "epoch", epoch, "txId", txId, "code", code)
+		if err := s.ledger.SetSettlement(epoch, "", false, 0); err != nil {
+			logger.Error("Could not record reward epoch settlement", "epoch", epoch, "error", err)
+		}
+		s.notify()
+		return
+	}
+	if err := s.ledger.SetSettlement(epoch, txId, true, blockNum); err != nil {
+		logger.Error("Could not record reward epoch settlement", "epoch", epoch, "txId", txId, "error", err)
+		return
+	}
+	logger.Info("Settled reward epoch", "epoch", epoch, "txId", txId, "blockNum", blockNum)
+}
+
+// txValidation queries the block and the validation code of the committed
+// tx txId from the peer.
+func (h *Handler) txValidation(channelID, txId string) (uint64, pbpeer.TxValidationCode, error) {
+	creator, _ := h.signer.Serialize()
+	invocation := &pbpeer.ChaincodeInvocationSpec{ChaincodeSpec: &pbpeer.ChaincodeSpec{
+		Type:        pbpeer.ChaincodeSpec_GOLANG,
+		ChaincodeId: &pbpeer.ChaincodeID{Name: "qscc"},
+		Input:       &pbpeer.ChaincodeInput{Args: [][]byte{[]byte("GetBlockByTxID"), []byte(channelID), []byte(txId)}},
+	}}
+	prop, _, err := putils.CreateChaincodeProposalWithTxIDAndTransient(pb.HeaderType_ENDORSER_TRANSACTION, channelID, "", invocation, creator, "", nil)
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "error creating proposal")
+	}
+	signedProp, err := GetSignedProposal(prop, h.signer)
+	if err != nil {
+		return 0, 0, errors.WithMessage(err, "error creating signed proposal")
+	}
+	resp, err := h.endorser.ProcessProposal(context.Background(), signedProp)
+	if err != nil {
+		return 0, 0, err
+	}
+	if resp.Response.Status >= shim.ERRORTHRESHOLD {
+		// the ledger has no index entry for the txs it doesn't have
+		if strings.Contains(resp.Response.Message, "not found in index") {
+			return 0, 0, errTxNotCommitted
+		}
+		return 0, 0, errors.Errorf("error querying transaction, %s", resp.Response.Message)
+	}
+
+	block := &pb.Block{}
+	if err := proto.Unmarshal(resp.Response.Payload, block); err != nil {
+		return 0, 0, errors.WithMessage(err, "invalid block")
+	}
+	var filter []byte
+	if block.Metadata != nil && len(block.Metadata.Metadata) > int(pb.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = block.Metadata.Metadata[pb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	for i, data := range block.Data.Data {
+		if id, err := protoutil.GetOrComputeTxIDFromEnvelope(data); err != nil || id != txId {
+			continue
+		}
+		if i >= len(filter) {
+			return 0, 0, errors.Errorf("block %d has no validation code of transaction %s", block.Header.Number, txId)
+		}
+		return block.Header.Number, pbpeer.TxValidationCode(filter[i]), nil
+	}
+	return 0, 0, errors.Errorf("block %d has no transaction %s", block.Header.Number, txId)
+}
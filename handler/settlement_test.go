@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	pbpeer "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+	"github.com/tylerztl/fabric-mempool/conf"
+)
+
+// newSubmittedEpoch returns a ledger whose first epoch ended with its
+// settlement tx submitted at the unix time at.
+func newSubmittedEpoch(t *testing.T, txId string, at int64) *RewardLedger {
+	info := conf.DefaultRewardsInfo()
+	info.Epoch = 0
+	ledger, err := NewRewardLedger(dbm.NewMemDB(), info)
+	require.NoError(t, err)
+	require.NoError(t, ledger.Credit("tx1", "fetcher", map[string]*big.Int{"orderer0": big.NewInt(10)}))
+	ended, err := ledger.Rollover()
+	require.NoError(t, err)
+	require.NoError(t, ledger.SubmitSettlement(ended.Number, txId, at))
+	return ledger
+}
+
+func newTestSettler(ledger *RewardLedger, lookup func(string, string) (uint64, pbpeer.TxValidationCode, error)) *Settler {
+	s := NewSettler(conf.DefaultSettlementInfo(), &Handler{}, ledger)
+	s.lookup = lookup
+	return s
+}
+
+func TestSettlerConfirmsSubmittedSettlement(t *testing.T) {
+	ledger := newSubmittedEpoch(t, "settle1", time.Now().Add(-time.Hour).Unix())
+	s := newTestSettler(ledger, func(channelID, txId string) (uint64, pbpeer.TxValidationCode, error) {
+		assert.Equal(t, "settle1", txId)
+		return 7, pbpeer.TxValidationCode_VALID, nil
+	})
+
+	// committed while the settler was down, it is not submitted again
+	s.settle()
+	epochs, err := ledger.Epochs()
+	require.NoError(t, err)
+	assert.True(t, epochs[0].Settled)
+	assert.Equal(t, "settle1", epochs[0].SettleTxId)
+	assert.Equal(t, uint64(7), epochs[0].SettledBlock)
+	assert.Empty(t, s.pending)
+}
+
+func TestSettlerWaitsForSubmittedSettlement(t *testing.T) {
+	ledger := newSubmittedEpoch(t, "settle1", time.Now().Unix())
+	s := newTestSettler(ledger, func(string, string) (uint64, pbpeer.TxValidationCode, error) {
+		t.Fatal("looked up before the timeout")
+		return 0, 0, nil
+	})
+
+	// a restarted settler follows the commit of the settlement of the ledger
+	s.settle()
+	assert.Equal(t, map[string]uint64{"settle1": 1}, s.pending)
+	s.committed("settle1", 9, pbpeer.TxValidationCode_VALID)
+	epochs, err := ledger.Epochs()
+	require.NoError(t, err)
+	assert.True(t, epochs[0].Settled)
+	assert.Equal(t, uint64(9), epochs[0].SettledBlock)
+}
+
+func TestSettlerKeepsSettlementOnLookupError(t *testing.T) {
+	ledger := newSubmittedEpoch(t, "settle1", time.Now().Add(-time.Hour).Unix())
+	s := newTestSettler(ledger, func(string, string) (uint64, pbpeer.TxValidationCode, error) {
+		return 0, 0, errors.New("peer unavailable")
+	})
+
+	// the settlement may be committed, it is looked up again rather than
+	// submitted twice
+	s.settle()
+	epochs, err := ledger.Epochs()
+	require.NoError(t, err)
+	assert.False(t, epochs[0].Settled)
+	assert.Equal(t, "settle1", epochs[0].SettleTxId)
+	assert.Equal(t, map[string]uint64{"settle1": 1}, s.pending)
+}
+
+func TestSettlerCommittedInvalid(t *testing.T) {
+	ledger := newSubmittedEpoch(t, "settle1", time.Now().Unix())
+	s := newTestSettler(ledger, nil)
+	s.track("settle1", 1)
+
+	s.committed("settle1", 9, pbpeer.TxValidationCode_MVCC_READ_CONFLICT)
+	epochs, err := ledger.Epochs()
+	require.NoError(t, err)
+	assert.False(t, epochs[0].Settled)
+	assert.Empty(t, epochs[0].SettleTxId)
+	assert.Empty(t, s.pending)
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"context"
+
+	pb "github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+	"github.com/tylerztl/fabric-mempool/protos"
+)
+
+// TopFees implements the Shard service, returning the fees of the txs the
+// orderer requester would fetch next from this shard, by units reaped whole.
+// The fetch request the router passes on is checked, but left to the fetch
+// of the share.
+func (h *Handler) TopFees(ctx context.Context, req *protos.TopFeesRequest) (*protos.TopFeesResponse, error) {
+	if h.replica != nil && !h.replica.IsLeader() {
+		return nil, errors.Errorf("not the leader of the mempool replicas, the leader is replica %d", h.replica.Leader())
+	}
+	orderer := h.fetcher.GetOrderer(req.Requester)
+	if orderer == nil {
+		return nil, errors.New("not found orderer connected client")
+	}
+	if h.fetchAuth != nil {
+		ftx := &pb.FetchTxsRequest{Requester: req.Requester, BlockHeight: req.BlockHeight}
+		if _, err := h.fetchAuth.verify(ctx, ftx, orderer); err != nil {
+			logger.Error("Rejected unauthenticated top fees request", "OrdererName", req.Requester, "error", err)
+			return nil, err
+		}
+	}
+	if orderer.State() != protos.OrdererState_READY {
+		// nothing is fetched for it
+		return &protos.TopFeesResponse{Capacity: int32(orderer.Capacity())}, nil
+	}
+
+	h.Mempool.PromoteDueTxs()
+	capacity := orderer.Capacity()
+	resp := &protos.TopFeesResponse{Capacity: int32(capacity)}
+	for _, unit := range h.Mempool.UnitFees(capacity) {
+		resp.Fees = append(resp.Fees, unit.Fee)
+		resp.UnitTxs = append(resp.UnitTxs, int32(unit.Txs))
+	}
+	return resp, nil
+}
+
+// FetchShare implements the Shard service, fetching the share of a block the
+// router assigned to this shard. The txs with the highest fees are fetched
+// whatever the sort switch, as the share was computed from them.
+func (h *Handler) FetchShare(ctx context.Context, req *protos.FetchShareRequest) (*pb.FetchTxsResponse, error) {
+	if req.MaxTxs <= 0 {
+		return &pb.FetchTxsResponse{TxNum: 0, IsEmpty: true}, nil
+	}
+	ftx := &pb.FetchTxsRequest{Requester: req.Requester, BlockHeight: req.BlockHeight}
+	return h.fetch(ctx, ftx, int(req.MaxTxs), true)
+}
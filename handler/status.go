@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	pbpeer "github.com/hyperledger/fabric/protos/peer"
+)
+
+// TxState is the lifecycle state of a transaction known to the mempool.
+type TxState string
+
+const (
+	TxPending     TxState = "pending"     // admitted into the mempool
+	TxBroadcast   TxState = "broadcast"   // fetched and sent to an orderer
+	TxFailed      TxState = "failed"      // fetched but not sent to its orderer, pending again
+	TxCommitted   TxState = "committed"   // committed as valid
+	TxInvalid     TxState = "invalid"     // committed with an invalid validation code or rejected by its orderer
+	TxResubmitted TxState = "resubmitted" // re-endorsed and submitted under a new txId
+	TxCancelled   TxState = "cancelled"   // removed on request of its creator
+)
+
+var (
+	// MaxTrackedTxs bounds the number of transaction statuses kept in memory.
+	MaxTrackedTxs = 100000
+	// EventBufferSize is the channel size of every event subscriber.
+	EventBufferSize = 1024
+)
+
+// TxStatus is the latest known status of a transaction.
+type TxStatus struct {
+	TxId           string  `json:"tx_id"`
+	State          TxState `json:"state"`
+	Orderer        string  `json:"orderer,omitempty"`
+	ValidationCode string  `json:"validation_code,omitempty"`
+	Retryable      bool    `json:"retryable"`
+	Retries        int     `json:"retries,omitempty"`
+	ResubmittedAs  string  `json:"resubmitted_as,omitempty"`
+	UpdatedAt      int64   `json:"updated_at"`
+}
+
+// TxEvent is published on every transaction status change.
+type TxEvent struct {
+	*TxStatus
+	BlockNumber uint64 `json:"block_number,omitempty"`
+}
+
+// TxStatusIndex keeps the status of recently seen transactions and fans
+// status changes out to event subscribers.
+type TxStatusIndex struct {
+	mtx      sync.RWMutex
+	statuses map[string]*list.Element
+	order    *list.List // oldest first, used to evict statuses
+
+	subMtx sync.RWMutex
+	subs   map[chan *TxEvent]struct{}
+}
+
+func NewTxStatusIndex() *TxStatusIndex {
+	return &TxStatusIndex{
+		statuses: make(map[string]*list.Element),
+		order:    list.New(),
+		subs:     make(map[chan *TxEvent]struct{}),
+	}
+}
+
+// Get returns a copy of the status of txId, or nil if it is unknown.
+func (idx *TxStatusIndex) Get(txId string) *TxStatus {
+	idx.mtx.RLock()
+	defer idx.mtx.RUnlock()
+
+	e, ok := idx.statuses[txId]
+	if !ok {
+		return nil
+	}
+	status := *e.Value.(*TxStatus)
+	return &status
+}
+
+// Update applies fn to the status of txId, creating it if missing, and
+// publishes the result to all subscribers.
+func (idx *TxStatusIndex) Update(txId string, blockNum uint64, fn func(status *TxStatus)) *TxStatus {
+	idx.mtx.Lock()
+	e, ok := idx.statuses[txId]
+	if !ok {
+		e = idx.order.PushBack(&TxStatus{TxId: txId})
+		idx.statuses[txId] = e
+		for idx.order.Len() > MaxTrackedTxs {
+			oldest := idx.order.Front()
+			idx.order.Remove(oldest)
+			delete(idx.statuses, oldest.Value.(*TxStatus).TxId)
+		}
+	}
+	status := e.Value.(*TxStatus)
+	fn(status)
+	status.UpdatedAt = time.Now().Unix()
+	snapshot := *status
+	idx.mtx.Unlock()
+
+	idx.publish(&TxEvent{TxStatus: &snapshot, BlockNumber: blockNum})
+	return &snapshot
+}
+
+// SetState moves txId into state.
+func (idx *TxStatusIndex) SetState(txId string, state TxState) *TxStatus {
+	return idx.Update(txId, 0, func(status *TxStatus) {
+		status.State = state
+	})
+}
+
+// SetValidation records the validation code txId was committed with.
+func (idx *TxStatusIndex) SetValidation(txId string, blockNum uint64, code pbpeer.TxValidationCode) *TxStatus {
+	return idx.Update(txId, blockNum, func(status *TxStatus) {
+		status.ValidationCode = code.String()
+		status.Retryable = IsRetryable(code)
+		if code == pbpeer.TxValidationCode_VALID {
+			status.State = TxCommitted
+		} else {
+			status.State = TxInvalid
+		}
+	})
+}
+
+// Subscribe registers a new event subscriber. The returned function must be
+// called to release the subscription.
+func (idx *TxStatusIndex) Subscribe() (<-chan *TxEvent, func()) {
+	ch := make(chan *TxEvent, EventBufferSize)
+	idx.subMtx.Lock()
+	idx.subs[ch] = struct{}{}
+	idx.subMtx.Unlock()
+
+	return ch, func() {
+		idx.subMtx.Lock()
+		if _, ok := idx.subs[ch]; ok {
+			delete(idx.subs, ch)
+			close(ch)
+		}
+		idx.subMtx.Unlock()
+	}
+}
+
+// publish never blocks, events are dropped for subscribers which can't keep up.
+func (idx *TxStatusIndex) publish(event *TxEvent) {
+	idx.subMtx.RLock()
+	defer idx.subMtx.RUnlock()
+
+	for ch := range idx.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
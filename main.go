@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"github.com/tendermint/tendermint/libs/log"
 	"github.com/tylerztl/fabric-mempool/conf"
 	"github.com/tylerztl/fabric-mempool/handler"
+	"github.com/tylerztl/fabric-mempool/protos"
 	"google.golang.org/grpc"
 )
 
@@ -36,17 +38,45 @@ var serverCmd = &cobra.Command{
 	},
 }
 
+var routerCmd = &cobra.Command{
+	Use:   "router",
+	Short: "Run the gRPC router in front of the mempool shards",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := RunRouter()
+		if err != nil {
+			panic(err)
+		}
+	},
+}
+
 func init() {
 	serverCmd.Flags().StringVarP(&ServerPort, "port", "p", "8080", "server port")
 	serverCmd.Flags().StringVarP(&RestPort, "rest", "r", ":80", "rest server port")
 	serverCmd.Flags().IntVarP(&distributeConfig.DistributionType, "distribute", "d", 0, "distribution type")
 	serverCmd.Flags().BoolVarP(&sortConfig.SortSwitch, "sort", "s", true, "mempool sort switch")
 
+	routerCmd.Flags().StringVarP(&ServerPort, "port", "p", "8080", "server port")
+
 	importCmd.Flags().StringVarP(&FilePath, "filepath", "f", "", "数据文件所在路径")
 	importCmd.Flags().IntVarP(&BatchNum, "batch", "b", 100, "每次上传的数据量（条/次）")
 	importCmd.Flags().Int64VarP(&Interval, "interval", "i", 0, "请求时间间隔（纳秒）")
+	rewardsCmd.PersistentFlags().StringVarP(&RewardsServer, "server", "u", "http://127.0.0.1:80", "rest server url")
+	rewardsListCmd.Flags().StringVarP(&RewardsOrderer, "orderer", "o", "", "orderer name, all orderers when empty")
+	rewardsListCmd.Flags().StringVar(&RewardsFrom, "from", "", "start of the time range (RFC3339)")
+	rewardsListCmd.Flags().StringVar(&RewardsTo, "to", "", "end of the time range, excluded (RFC3339)")
+	rewardsListCmd.Flags().IntVarP(&RewardsLimit, "limit", "l", 0, "max number of credits, unlimited when 0")
+	rewardsRolloverCmd.Flags().StringVar(&RewardsAdminMSPID, "mspid", conf.GetAppConf().Conf.AdminAuth.MSPID, "msp of the admin")
+	rewardsRolloverCmd.Flags().StringVarP(&RewardsAdminKey, "key", "k", "", "private key of the admin")
+	rewardsRolloverCmd.Flags().StringVarP(&RewardsAdminCert, "cert", "c", "", "sign certificate of the admin")
+	rewardsCmd.AddCommand(rewardsListCmd, rewardsEpochsCmd, rewardsRolloverCmd)
+	auditVerifyCmd.Flags().StringVarP(&AuditFile, "file", "f", "", "audit log, the one of app.yaml when empty")
+	auditVerifyCmd.Flags().StringVarP(&AuditCert, "cert", "c", "", "signer certificate, the user sign_cert of app.yaml when empty")
+	auditCmd.AddCommand(auditVerifyCmd)
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(routerCmd)
 	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(rewardsCmd)
+	rootCmd.AddCommand(auditCmd)
 }
 
 func main() {
@@ -63,6 +93,19 @@ var (
 	FilePath string
 	BatchNum int
 	Interval int64
+
+	RewardsServer  string
+	RewardsOrderer string
+	RewardsFrom    string
+	RewardsTo      string
+	RewardsLimit   int
+
+	RewardsAdminMSPID string
+	RewardsAdminKey   string
+	RewardsAdminCert  string
+
+	AuditFile string
+	AuditCert string
 )
 
 func Cors() gin.HandlerFunc {
@@ -71,7 +114,7 @@ func Cors() gin.HandlerFunc {
 		// 可将将* 替换为指定的域名
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, X-Admin-Creator, X-Admin-Signature, X-Admin-Timestamp")
 		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		if method == "OPTIONS" {
@@ -98,6 +141,22 @@ func Run() error {
 	logger.Info("Fabric mempool service running", "listenPort", ServerPort)
 
 	wg := new(sync.WaitGroup)
+	if raftSrv := rpcHandler.NewRaftServer(); raftSrv != nil {
+		raftAddr := conf.GetAppConf().Conf.Raft.ListenAddr
+		raftConn, err := net.Listen("tcp", raftAddr)
+		if err != nil {
+			logger.Error("TCP Listen err:%s", err)
+			return err
+		}
+		logger.Info("Fabric mempool raft service running", "listenAddr", raftAddr)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := raftSrv.Serve(raftConn); err != nil {
+				logger.Error("Raft Server: %s", err)
+			}
+		}()
+	}
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
@@ -115,10 +174,42 @@ func Run() error {
 	return err
 }
 
+// RunRouter serves the mempool service in front of the shards of the router
+// section of app.yaml.
+func RunRouter() error {
+	info := conf.GetAppConf().Conf.Router
+	if info == nil {
+		return errors.New("the router section of app.yaml is missing")
+	}
+	router, err := handler.NewRouter(info)
+	if err != nil {
+		return err
+	}
+	defer router.Close()
+
+	EndPoint = ":" + ServerPort
+	conn, err := net.Listen("tcp", EndPoint)
+	if err != nil {
+		logger.Error("TCP Listen err:%s", err)
+		return err
+	}
+
+	server := grpc.NewServer()
+	pb.RegisterMempoolServer(server, router)
+	logger.Info("Fabric mempool router running", "listenPort", ServerPort, "shards", len(info.Shards))
+	return server.Serve(conn)
+}
+
 func newGrpc(rpcHandler *handler.Handler) *grpc.Server {
 	server := grpc.NewServer()
 	// TODO
 	pb.RegisterMempoolServer(server, rpcHandler)
+	protos.RegisterFeeEstimatorServer(server, rpcHandler)
+	protos.RegisterBundleServer(server, rpcHandler)
+	protos.RegisterTimeLockServer(server, rpcHandler)
+	protos.RegisterCancelServer(server, rpcHandler)
+	protos.RegisterShardServer(server, rpcHandler)
+	protos.RegisterOrdererAdminServer(server, rpcHandler)
 
 	return server
 }
@@ -0,0 +1,227 @@
+package mempool
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/tendermint/tendermint/libs/clist"
+	"github.com/tendermint/tendermint/types"
+)
+
+// DefaultMaxBundleTxs is the maximum number of txs in a bundle unless
+// changed by WithMaxBundleTxs.
+const DefaultMaxBundleTxs = 16
+
+// txBundle is a group of txs which are admitted, reaped and removed together.
+type txBundle struct {
+	key     [TxKeySize]byte
+	txs     []*mempoolTx // in submission order
+	fee     int64        // aggregate fee of all txs, used as priority
+	expires time.Time    // zero means the bundle never expires
+}
+
+func (b *txBundle) expired(now time.Time) bool {
+	return !b.expires.IsZero() && now.After(b.expires)
+}
+
+// BundleKey is the key identifying a bundle of txs.
+func BundleKey(txs types.Txs) [TxKeySize]byte {
+	h := sha256.New()
+	for _, tx := range txs {
+		key := TxKey(tx)
+		h.Write(key[:])
+	}
+	var key [TxKeySize]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// CheckBundle adds txs to the mempool as one bundle: either all of them are
+// admitted or none. The bundle is reaped as a whole, in the given order, and
+// removed as a whole once it is committed, removed or older than ttl. A
+// ttl of zero means the bundle doesn't expire.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) CheckBundle(txs types.Txs, ttl time.Duration, txInfo TxInfo) error {
+	mem.updateMtx.RLock()
+	defer mem.updateMtx.RUnlock()
+
+	if len(txs) == 0 {
+		return errors.New("empty bundle")
+	}
+	if len(txs) > mem.maxBundleTxs {
+		return fmt.Errorf("bundle too large: %d txs, max %d", len(txs), mem.maxBundleTxs)
+	}
+
+	var (
+		totalSize int
+		fees      = new(big.Int)
+		seen      = make(map[[TxKeySize]byte]struct{}, len(txs))
+		bundle    = &txBundle{key: BundleKey(txs)}
+	)
+	for _, tx := range txs {
+		if len(tx) > mem.config.MaxTxBytes {
+			return ErrTxTooLarge{mem.config.MaxTxBytes, len(tx)}
+		}
+		if _, ok := seen[TxKey(tx)]; ok {
+			return errors.New("duplicate tx in bundle")
+		}
+		seen[TxKey(tx)] = struct{}{}
+		// the cache alone misses the txs it evicted, or all with no cache
+		if mem.pooled(TxKey(tx)) {
+			return ErrTxInCache
+		}
+		if mem.preCheck != nil {
+			if err := mem.preCheck(tx); err != nil {
+				return ErrPreCheck{err}
+			}
+		}
+		fee, txId, err := txFee(tx)
+		if err != nil && mem.requireFee {
+			return ErrTxFee{err}
+		}
+		bundle.txs = append(bundle.txs, &mempoolTx{
+			height:    mem.height,
+			gasWanted: fee.Int64(),
+			tx:        tx,
+			txID:      txId,
+			bundle:    bundle,
+		})
+		fees.Add(fees, fee)
+		totalSize += len(tx)
+	}
+	if !fees.IsInt64() {
+		return ErrTxFee{errors.New("bundle fee overflows")}
+	}
+	bundle.fee = fees.Int64()
+
+	if err := mem.isFullFor(len(txs), totalSize); err != nil {
+		return err
+	}
+	if mem.minFee != nil {
+		// the bundle must pay the floor for each of its txs
+		minFee := new(big.Int).Mul(mem.MinFee(), big.NewInt(int64(len(txs))))
+		if fees.Cmp(minFee) < 0 {
+			return ErrFeeTooLow{Fee: fees, MinFee: minFee}
+		}
+	}
+
+	// the WAL is written before the txs are cached, as CheckTx does, so that a
+	// bundle failing to be written is checked again when submitted again
+	if mem.wal != nil {
+		for _, tx := range txs {
+			if _, err := mem.wal.Write(append([]byte(tx), newline...)); err != nil {
+				return fmt.Errorf("wal.Write: %w", err)
+			}
+		}
+	}
+	for i, tx := range txs {
+		if !mem.cache.Push(tx) {
+			for _, pushed := range txs[:i] {
+				mem.cache.Remove(pushed)
+			}
+			return ErrTxInCache
+		}
+	}
+
+	if ttl > 0 {
+		bundle.expires = txInfo.At().Add(ttl)
+	}
+	for _, memTx := range bundle.txs {
+		memTx.senders.Store(txInfo.SenderID, true)
+		mem.addTx(memTx)
+	}
+	mem.logger.Info("Added transaction bundle to mempool",
+		"bundle", fmt.Sprintf("%X", bundle.key),
+		"txs", len(txs),
+		"fee", bundle.fee,
+		"poolSize", mem.Size(),
+	)
+	mem.notifyTxsAvailable()
+	mem.metrics.Size.Set(float64(mem.Size()))
+
+	return nil
+}
+
+// pooled checks the tx of txKey is in the mempool, time-locked or spilled to
+// the overflow tier.
+func (mem *CListMempool) pooled(txKey [TxKeySize]byte) bool {
+	if _, ok := mem.txsMap.Load(txKey); ok {
+		return true
+	}
+	mem.lockedMtx.Lock()
+	_, ok := mem.locked[txKey]
+	mem.lockedMtx.Unlock()
+	if ok {
+		return true
+	}
+	return mem.overflow != nil && mem.overflow.has(txKey)
+}
+
+// removeBundle removes all txs of a bundle still in the mempool.
+func (mem *CListMempool) removeBundle(bundle *txBundle, removeFromCache bool) {
+	for _, memTx := range bundle.txs {
+		if e, ok := mem.txsMap.Load(TxKey(memTx.tx)); ok {
+			mem.removeTx(memTx.tx, e.(*clist.CElement), removeFromCache)
+		}
+	}
+}
+
+// removeExpiredBundles removes the bundles which expired by now. Expired txs
+// are removed from the cache, so they can be submitted again.
+func (mem *CListMempool) removeExpiredBundles(now time.Time) {
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		memTx := e.Value.(*mempoolTx)
+		if memTx.bundle != nil && memTx.bundle.expired(now) {
+			mem.logger.Info("Removed expired transaction bundle", "bundle", fmt.Sprintf("%X", memTx.bundle.key))
+			mem.removeBundle(memTx.bundle, true)
+		}
+	}
+}
+
+// reapUnit is a single tx or a whole bundle, as handed out by reaping.
+type reapUnit struct {
+	txs []*mempoolTx
+	fee int64
+}
+
+// skipped checks a tx of the unit is to skip.
+func (u reapUnit) skipped(skip SkipFunc) bool {
+	if skip == nil {
+		return false
+	}
+	for _, memTx := range u.txs {
+		if skip(TxKey(memTx.tx)) {
+			return true
+		}
+	}
+	return false
+}
+
+// reapUnits groups the txs of the mempool into units ordered by fee, bundles
+// use their aggregate fee. Expired bundles are left out.
+func reapUnits(pairs PairList) []reapUnit {
+	var (
+		now     = time.Now()
+		units   = make([]reapUnit, 0, len(pairs))
+		bundles = make(map[*txBundle]struct{})
+	)
+	for _, p := range pairs {
+		memTx := p.Value
+		if memTx.bundle == nil {
+			units = append(units, reapUnit{txs: []*mempoolTx{memTx}, fee: memTx.gasWanted})
+			continue
+		}
+		if _, ok := bundles[memTx.bundle]; ok || memTx.bundle.expired(now) {
+			continue
+		}
+		bundles[memTx.bundle] = struct{}{}
+		units = append(units, reapUnit{txs: memTx.bundle.txs, fee: memTx.bundle.fee})
+	}
+	sort.SliceStable(units, func(i, j int) bool { return units[i].fee > units[j].fee })
+	return units
+}
@@ -0,0 +1,138 @@
+package mempool
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+func newBundleMempool(t *testing.T) (*CListMempool, cleanupFunc) {
+	config := cfg.ResetTestRoot("mempool_test")
+	mempool := NewCListMempool(config.Mempool, 0, WithMaxBundleTxs(3))
+	mempool.SetLogger(log.TestingLogger())
+	return mempool, func() { os.RemoveAll(config.RootDir) }
+}
+
+func TestCheckBundleIsAllOrNothing(t *testing.T) {
+	mempool, cleanup := newBundleMempool(t)
+	defer cleanup()
+
+	single := newFeeTx(t, "single", 10)
+	require.NoError(t, mempool.CheckTx(single, nil, TxInfo{}))
+
+	// a tx already seen rejects the whole bundle
+	err := mempool.CheckBundle(types.Txs{newFeeTx(t, "b1", 10), single}, 0, TxInfo{})
+	assert.Equal(t, ErrTxInCache, err)
+	assert.Equal(t, 1, mempool.Size())
+
+	assert.Error(t, mempool.CheckBundle(nil, 0, TxInfo{}))
+	assert.Error(t, mempool.CheckBundle(types.Txs{newFeeTx(t, "b1", 10), newFeeTx(t, "b1", 10)}, 0, TxInfo{}))
+	assert.Error(t, mempool.CheckBundle(types.Txs{
+		newFeeTx(t, "b1", 1), newFeeTx(t, "b2", 1), newFeeTx(t, "b3", 1), newFeeTx(t, "b4", 1),
+	}, 0, TxInfo{}))
+
+	// the rejected txs were not kept in the cache
+	require.NoError(t, mempool.CheckBundle(types.Txs{newFeeTx(t, "b1", 10), newFeeTx(t, "b2", 10)}, 0, TxInfo{}))
+	assert.Equal(t, 3, mempool.Size())
+}
+
+func TestCheckBundleWithoutCache(t *testing.T) {
+	config := cfg.ResetTestRoot("mempool_test")
+	defer os.RemoveAll(config.RootDir)
+	config.Mempool.CacheSize = 0
+	mempool := NewCListMempool(config.Mempool, 0)
+
+	single := newFeeTx(t, "single", 10)
+	require.NoError(t, mempool.CheckTx(single, nil, TxInfo{}))
+	bundle := types.Txs{newFeeTx(t, "b1", 10), newFeeTx(t, "b2", 10)}
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+
+	// the txs in the pool are found without the cache
+	assert.Equal(t, ErrTxInCache, mempool.CheckBundle(types.Txs{newFeeTx(t, "b3", 10), single}, 0, TxInfo{}))
+	assert.Equal(t, ErrTxInCache, mempool.CheckBundle(types.Txs{bundle[1], newFeeTx(t, "b3", 10)}, 0, TxInfo{}))
+	assert.Equal(t, 3, mempool.Size())
+}
+
+func TestCheckBundleFeeOverflow(t *testing.T) {
+	mempool, cleanup := newBundleMempool(t)
+	defer cleanup()
+
+	err := mempool.CheckBundle(types.Txs{newFeeTx(t, "b1", math.MaxInt64), newFeeTx(t, "b2", 1)}, 0, TxInfo{})
+	assert.IsType(t, ErrTxFee{}, err)
+	assert.Equal(t, 0, mempool.Size())
+}
+
+func TestReapBundles(t *testing.T) {
+	mempool, cleanup := newBundleMempool(t)
+	defer cleanup()
+
+	bundle := types.Txs{newFeeTx(t, "transfer", 20), newFeeTx(t, "settle", 20)}
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "low", 10), nil, TxInfo{}))
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "high", 30), nil, TxInfo{}))
+
+	// the bundle is prioritized by its aggregate fee 40
+	txs := mempool.ReapMaxTxsBySort(4)
+	assert.Equal(t, types.Txs{bundle[0], bundle[1], newFeeTx(t, "high", 30), newFeeTx(t, "low", 10)}, txs)
+
+	// a bundle which doesn't fit is skipped for smaller units
+	txs = mempool.ReapMaxTxsBySort(1)
+	assert.Equal(t, types.Txs{newFeeTx(t, "high", 30)}, txs)
+	txs = mempool.ReapMaxTxsBySort(2)
+	assert.Equal(t, types.Txs{bundle[0], bundle[1]}, txs)
+
+	// bundles are reaped whole in arrival order too
+	txs = mempool.ReapMaxTxs(2)
+	assert.Equal(t, types.Txs{newFeeTx(t, "low", 10), newFeeTx(t, "high", 30)}, txs)
+	txs = mempool.ReapMaxTxs(3)
+	assert.Equal(t, types.Txs{newFeeTx(t, "low", 10), bundle[0], bundle[1]}, txs)
+}
+
+func TestRemoveBundleAsWhole(t *testing.T) {
+	mempool, cleanup := newBundleMempool(t)
+	defer cleanup()
+
+	bundle := types.Txs{newFeeTx(t, "b1", 20), newFeeTx(t, "b2", 20), newFeeTx(t, "b3", 20)}
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "single", 10), nil, TxInfo{}))
+
+	mempool.RemoveTxByKey(TxKey(bundle[1]), true)
+	assert.Equal(t, 1, mempool.Size())
+	assert.EqualValues(t, len(newFeeTx(t, "single", 10)), mempool.TxsBytes())
+
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+	mempool.Lock()
+	require.NoError(t, mempool.Update(1, types.Txs{bundle[0]}, nil, nil, nil))
+	mempool.Unlock()
+	assert.Equal(t, 1, mempool.Size())
+}
+
+func TestBundleExpires(t *testing.T) {
+	mempool, cleanup := newBundleMempool(t)
+	defer cleanup()
+
+	bundle := types.Txs{newFeeTx(t, "b1", 20), newFeeTx(t, "b2", 20)}
+	require.NoError(t, mempool.CheckBundle(bundle, 50*time.Millisecond, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "single", 10), nil, TxInfo{}))
+	assert.Len(t, mempool.ReapMaxTxsBySort(-1), 3)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, types.Txs{newFeeTx(t, "single", 10)}, mempool.ReapMaxTxsBySort(-1))
+	assert.Equal(t, types.Txs{newFeeTx(t, "single", 10)}, mempool.ReapMaxBytesMaxGas(-1, -1))
+
+	mempool.Lock()
+	require.NoError(t, mempool.Update(1, nil, nil, nil, nil))
+	mempool.Unlock()
+	assert.Equal(t, 1, mempool.Size())
+
+	// expired txs can be submitted again
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+}
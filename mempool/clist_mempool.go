@@ -4,11 +4,11 @@ import (
 	"container/list"
 	"crypto/sha256"
 	"fmt"
-	"github.com/tylerztl/fabric-mempool/protoutil"
 	"math/big"
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	cfg "github.com/tendermint/tendermint/config"
@@ -35,8 +35,9 @@ var newline = []byte("\n")
 // be efficiently accessed by multiple concurrent readers.
 type CListMempool struct {
 	// Atomic integers
-	height   int64 // the last block Update()'d to
-	txsBytes int64 // total size of mempool, in bytes
+	height      int64 // the last block Update()'d to
+	txsBytes    int64 // total size of mempool, in bytes
+	lockedBytes int64 // total size of the time-locked txs, in bytes
 
 	// notify listeners (ie. consensus) when txs are available
 	notifiedTxsAvailable bool
@@ -50,6 +51,27 @@ type CListMempool struct {
 	preCheck  PreCheckFunc
 	postCheck PostCheckFunc
 
+	// minFee computes the fee floor from the pool occupancy, nil means no floor.
+	minFee MinFeeFunc
+	// requireFee rejects the txs whose fee can't be decoded.
+	requireFee bool
+
+	maxBundleTxs int
+
+	// Txs which are not reapable before a time or height, they are promoted
+	// to txs once due.
+	timestampLock bool          // lock txs until their channel header timestamp
+	maxTimeLock   time.Duration // zero means no limit
+	lockedMtx     tmsync.Mutex
+	locked        map[[TxKeySize]byte]*lockedTx
+	lockedSeq     int64
+
+	// Txs with the lowest fees spilled to disk while the mempool is full,
+	// nil unless enabled by WithOverflow.
+	overflow *overflow
+	spillMtx tmsync.Mutex
+	fees     *feeIndex // single txs in memory by fee, to spill the lowest
+
 	wal *auto.AutoFile // a log of mempool txs
 	txs *clist.CList   // concurrent linked-list of good txs
 
@@ -63,6 +85,10 @@ type CListMempool struct {
 	// txsMap: txKey -> CElement
 	txsMap sync.Map
 
+	// Map to find pending txs, time-locked ones included, by their Fabric txId.
+	// txIDs: txId -> txKey
+	txIDs sync.Map
+
 	// Keep a cache of already-seen txs.
 	// This reduces the pressure on the proxyApp.
 	cache txCache
@@ -85,14 +111,16 @@ func (p PairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p PairList) Len() int           { return len(p) }
 func (p PairList) Less(i, j int) bool { return p[i].Value.gasWanted > p[j].Value.gasWanted }
 
+// sortMapByValue sorts the txs of m, size is the number of txs expected. The
+// txs added or removed concurrently may be missed or included.
 func sortMapByValue(m *sync.Map, size int) PairList {
-	p := make(PairList, size)
-	i := 0
+	p := make(PairList, 0, size)
 	walk := func(key, value interface{}) bool {
 		bk := key.([TxKeySize]byte)
 		bv := value.(*clist.CElement)
-		p[i] = Pair{bk, bv.Value.(*mempoolTx)}
-		i++
+		if memTx, ok := bv.Value.(*mempoolTx); ok {
+			p = append(p, Pair{bk, memTx})
+		}
 		return true
 	}
 	m.Range(walk)
@@ -101,18 +129,65 @@ func sortMapByValue(m *sync.Map, size int) PairList {
 }
 
 func (mem *CListMempool) ReapMaxTxsBySort(max int) types.Txs {
+	return mem.ReapMaxTxsBySortSkipping(max, nil)
+}
+
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) ReapMaxTxsBySortSkipping(max int, skip SkipFunc) types.Txs {
+	mem.PromoteDueTxs()
+
+	units := mem.topUnits(max, skip)
+	txs := make([]types.Tx, 0, len(units))
+	for _, unit := range units {
+		for _, memTx := range unit.txs {
+			txs = append(txs, memTx.tx)
+		}
+	}
+	return txs
+}
+
+// UnitFees returns the fees of the units ReapMaxTxsBySort(max) reaps.
+func (mem *CListMempool) UnitFees(max int) []UnitFee {
+	mem.PromoteDueTxs()
+
+	units := mem.topUnits(max, nil)
+	fees := make([]UnitFee, len(units))
+	for i, unit := range units {
+		fees[i] = UnitFee{Fee: unit.fee, Txs: len(unit.txs)}
+	}
+	return fees
+}
+
+// topUnits returns the units with the highest fees up to max txs, but the
+// units with a tx to skip.
+func (mem *CListMempool) topUnits(max int, skip SkipFunc) []reapUnit {
 	if max < 0 {
 		max = mem.txs.Len()
 	}
-	num := tmmath.MinInt(mem.txs.Len(), max)
-	txs := make([]types.Tx, 0, num)
-	lists := sortMapByValue(&mem.txsMap, mem.txs.Len())
-	for i := 0; i < num; i++ {
-		tx := lists[i]
-		txs = append(txs, tx.Value.tx)
-
+	mem.updateMtx.RLock()
+	pairs := sortMapByValue(&mem.txsMap, mem.txs.Len())
+	mem.updateMtx.RUnlock()
+	var (
+		num   = tmmath.MinInt(mem.txs.Len(), max)
+		size  = 0
+		units = reapUnits(pairs)
+		top   = make([]reapUnit, 0, num)
+	)
+	for _, unit := range units {
+		if unit.skipped(skip) {
+			continue
+		}
+		if size+len(unit.txs) > num {
+			// a bundle is reaped whole or not at all, smaller units may still fit
+			if len(unit.txs) > 1 {
+				continue
+			}
+			break
+		}
+		top = append(top, unit)
+		size += len(unit.txs)
 	}
-	return txs
+	return top
 }
 
 var _ Mempool = &CListMempool{}
@@ -134,6 +209,8 @@ func NewCListMempool(
 		recheckEnd:    nil,
 		logger:        log.NewNopLogger(),
 		metrics:       NopMetrics(),
+		maxBundleTxs:  DefaultMaxBundleTxs,
+		locked:        make(map[[TxKeySize]byte]*lockedTx),
 	}
 	if config.CacheSize > 0 {
 		mempool.cache = newMapTxCache(config.CacheSize)
@@ -170,6 +247,34 @@ func WithPostCheck(f PostCheckFunc) CListMempoolOption {
 	return func(mem *CListMempool) { mem.postCheck = f }
 }
 
+// WithMinFee sets the function computing the minimum fee a tx must pay to be
+// admitted, based on the pool occupancy.
+func WithMinFee(f MinFeeFunc) CListMempoolOption {
+	return func(mem *CListMempool) { mem.minFee = f }
+}
+
+// WithRequireFee rejects the txs whose fee can't be decoded from their
+// envelope, rather than admitting them with no fee.
+func WithRequireFee() CListMempoolOption {
+	return func(mem *CListMempool) { mem.requireFee = true }
+}
+
+// WithMaxBundleTxs sets the maximum number of txs in a bundle.
+func WithMaxBundleTxs(max int) CListMempoolOption {
+	return func(mem *CListMempool) { mem.maxBundleTxs = max }
+}
+
+// WithTimestampLock makes txs whose channel header timestamp lies in the
+// future not reapable before that time.
+func WithTimestampLock() CListMempoolOption {
+	return func(mem *CListMempool) { mem.timestampLock = true }
+}
+
+// WithMaxTimeLock sets how far in the future a tx may be time-locked.
+func WithMaxTimeLock(max time.Duration) CListMempoolOption {
+	return func(mem *CListMempool) { mem.maxTimeLock = max }
+}
+
 // WithMetrics sets the metrics.
 func WithMetrics(metrics *Metrics) CListMempoolOption {
 	return func(mem *CListMempool) { mem.metrics = metrics }
@@ -217,11 +322,101 @@ func (mem *CListMempool) Size() int {
 	return mem.txs.Len()
 }
 
+// Height returns the last Fabric block height the mempool was updated to.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) Height() int64 {
+	return atomic.LoadInt64(&mem.height)
+}
+
 // Safe for concurrent use by multiple goroutines.
 func (mem *CListMempool) TxsBytes() int64 {
 	return atomic.LoadInt64(&mem.txsBytes)
 }
 
+// Occupancy returns how full the mempool is, as the greater of the tx count
+// and the tx bytes ratios to their limits.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) Occupancy() float64 {
+	bySize := float64(mem.Size()) / float64(mem.config.Size)
+	byBytes := float64(mem.TxsBytes()) / float64(mem.config.MaxTxsBytes)
+	if byBytes > bySize {
+		return byBytes
+	}
+	return bySize
+}
+
+// MinFee returns the current minimum fee for a tx to be admitted.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) MinFee() *big.Int {
+	if mem.minFee == nil {
+		return new(big.Int)
+	}
+	return mem.minFee(mem.Occupancy())
+}
+
+// TxFees returns the fees of all txs in the mempool, highest first.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) TxFees() []int64 {
+	fees := make([]int64, 0, mem.txs.Len())
+	for e := mem.txs.Front(); e != nil; e = e.Next() {
+		fees = append(fees, e.Value.(*mempoolTx).gasWanted)
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] > fees[j] })
+	return fees
+}
+
+// TxByID returns the pending tx with the given Fabric txId, time-locked and
+// spilled txs included.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) TxByID(txID string) (types.Tx, bool) {
+	key, ok := mem.txIDs.Load(txID)
+	if !ok {
+		return nil, false
+	}
+	if e, ok := mem.txsMap.Load(key); ok {
+		return e.(*clist.CElement).Value.(*mempoolTx).tx, true
+	}
+
+	mem.lockedMtx.Lock()
+	locked, ok := mem.locked[key.([TxKeySize]byte)]
+	mem.lockedMtx.Unlock()
+	if ok {
+		return locked.memTx.tx, true
+	}
+	if mem.overflow != nil {
+		return mem.overflow.get(key.([TxKeySize]byte))
+	}
+	return nil, false
+}
+
+// BundleTxs returns the txs of the bundle of the pending tx with the given
+// Fabric txId, the tx alone if it isn't bundled.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) BundleTxs(txID string) types.Txs {
+	if key, ok := mem.txIDs.Load(txID); ok {
+		if e, ok := mem.txsMap.Load(key); ok {
+			if bundle := e.(*clist.CElement).Value.(*mempoolTx).bundle; bundle != nil {
+				txs := make(types.Txs, len(bundle.txs))
+				for i, memTx := range bundle.txs {
+					txs[i] = memTx.tx
+				}
+				return txs
+			}
+		}
+	}
+	// bundles are neither time-locked nor spilled
+	if tx, ok := mem.TxByID(txID); ok {
+		return types.Txs{tx}
+	}
+	return nil
+}
+
 // Lock() must be help by the caller during execution.
 func (mem *CListMempool) FlushAppConn() error {
 	return nil
@@ -244,6 +439,22 @@ func (mem *CListMempool) Flush() {
 		mem.txsMap.Delete(key)
 		return true
 	})
+	mem.txIDs.Range(func(key, _ interface{}) bool {
+		mem.txIDs.Delete(key)
+		return true
+	})
+
+	mem.lockedMtx.Lock()
+	mem.locked = make(map[[TxKeySize]byte]*lockedTx)
+	_ = atomic.SwapInt64(&mem.lockedBytes, 0)
+	mem.lockedMtx.Unlock()
+
+	if mem.overflow != nil {
+		mem.fees.reset()
+		if err := mem.overflow.reset(); err != nil {
+			mem.logger.Error("Could not flush the mempool overflow", "err", err)
+		}
+	}
 }
 
 // TxsFront returns the first transaction in the ordered list for peer
@@ -266,7 +477,9 @@ func (mem *CListMempool) TxsWaitChan() <-chan struct{} {
 
 // It blocks if we're waiting on Update() or Reap().
 // cb: A callback from the CheckTx command.
-//     It gets called from another goroutine.
+//
+//	It gets called from another goroutine.
+//
 // CONTRACT: Either cb will get called, or err returned.
 //
 // Safe for concurrent use by multiple goroutines.
@@ -278,19 +491,52 @@ func (mem *CListMempool) CheckTx(tx types.Tx, cb func(*abci.Response), txInfo Tx
 	txSize := len(tx)
 
 	if err := mem.isFull(txSize); err != nil {
-		return err
+		// a full mempool spills txs to the overflow, if there is room there
+		if mem.overflow == nil || !mem.overflow.hasRoom(txSize) {
+			return err
+		}
 	}
 
 	if txSize > mem.config.MaxTxBytes {
 		return ErrTxTooLarge{mem.config.MaxTxBytes, txSize}
 	}
 
+	if mem.requireFee || mem.minFee != nil {
+		fee, _, err := txFee(tx)
+		if err != nil && mem.requireFee {
+			return ErrTxFee{err}
+		}
+		if mem.minFee != nil {
+			if minFee := mem.MinFee(); fee.Cmp(minFee) < 0 {
+				return ErrFeeTooLow{Fee: fee, MinFee: minFee}
+			}
+		}
+	}
+
 	if mem.preCheck != nil {
 		if err := mem.preCheck(tx); err != nil {
 			return ErrPreCheck{err}
 		}
 	}
 
+	lock, err := mem.txLock(tx, txInfo)
+	if err != nil {
+		return err
+	}
+
+	if mem.overflow != nil {
+		// time-locked txs are never spilled
+		if !lock.due(txInfo.At(), mem.Height()) {
+			if err := mem.isFull(txSize); err != nil {
+				return err
+			}
+		}
+		// the cache may have forgotten a spilled tx
+		if mem.overflow.has(TxKey(tx)) {
+			return ErrTxInCache
+		}
+	}
+
 	// NOTE: writing to the WAL and calling proxy must be done before adding tx
 	// to the cache. otherwise, if either of them fails, next time CheckTx is
 	// called with tx, ErrTxInCache will be returned without tx being checked at
@@ -311,14 +557,12 @@ func (mem *CListMempool) CheckTx(tx types.Tx, cb func(*abci.Response), txInfo Tx
 		if e, ok := mem.txsMap.Load(TxKey(tx)); ok {
 			memTx := e.(*clist.CElement).Value.(*mempoolTx)
 			memTx.senders.LoadOrStore(txInfo.SenderID, true)
-			// TODO: consider punishing peer for dups,
-			// its non-trivial since invalid txs can become valid,
-			// but they can spam the same tx with little cost to them atm.
+			// The Reactor scores peers sending dups, see peerScores.
 		}
 
 		return ErrTxInCache
 	}
-	mem.reqResCb(tx, txInfo.SenderID)
+	mem.reqResCb(tx, txInfo.SenderID, lock, txInfo.At())
 
 	return nil
 }
@@ -335,34 +579,44 @@ func (mem *CListMempool) CheckTx(tx types.Tx, cb func(*abci.Response), txInfo Tx
 func (mem *CListMempool) reqResCb(
 	tx []byte,
 	peerID uint16,
+	lock timeLock,
+	now time.Time,
 ) {
 	//if mem.recheckCursor != nil {
 	//	// this should never happen
 	//	panic("recheck cursor is not nil in reqResCb")
 	//}
 
-	mem.resCbFirstTime(tx, peerID)
+	mem.resCbFirstTime(tx, peerID, lock, now)
 
 	// update metrics
 	mem.metrics.Size.Set(float64(mem.Size()))
 }
 
 // Called from:
-//  - resCbFirstTime (lock not held) if tx is valid
+//   - resCbFirstTime (lock not held) if tx is valid
 func (mem *CListMempool) addTx(memTx *mempoolTx) {
 	e := mem.txs.PushBack(memTx)
 	mem.txsMap.Store(TxKey(memTx.tx), e)
+	if mem.fees != nil && memTx.bundle == nil {
+		mem.fees.add(e)
+	}
+	mem.txIDs.Store(memTx.txID, TxKey(memTx.tx))
 	atomic.AddInt64(&mem.txsBytes, int64(len(memTx.tx)))
 	mem.metrics.TxSizeBytes.Observe(float64(len(memTx.tx)))
 }
 
 // Called from:
-//  - Update (lock held) if tx was committed
-// 	- resCbRecheck (lock not held) if tx was invalidated
+//   - Update (lock held) if tx was committed
+//   - resCbRecheck (lock not held) if tx was invalidated
 func (mem *CListMempool) removeTx(tx types.Tx, elem *clist.CElement, removeFromCache bool) {
 	mem.txs.Remove(elem)
 	elem.DetachPrev()
 	mem.txsMap.Delete(TxKey(tx))
+	if mem.fees != nil {
+		mem.fees.remove(elem)
+	}
+	mem.forgetTxID(elem.Value.(*mempoolTx))
 	atomic.AddInt64(&mem.txsBytes, int64(-len(tx)))
 
 	if removeFromCache {
@@ -370,23 +624,46 @@ func (mem *CListMempool) removeTx(tx types.Tx, elem *clist.CElement, removeFromC
 	}
 }
 
+// forgetTxID drops the txId of memTx from txIDs, unless another tx with the
+// same txId took its place.
+func (mem *CListMempool) forgetTxID(memTx *mempoolTx) {
+	if key, ok := mem.txIDs.Load(memTx.txID); ok && key.([TxKeySize]byte) == TxKey(memTx.tx) {
+		mem.txIDs.Delete(memTx.txID)
+	}
+}
+
 // RemoveTxByKey removes a transaction from the mempool by its TxKey index.
+// The other txs of its bundle, if any, are removed as well.
 func (mem *CListMempool) RemoveTxByKey(txKey [TxKeySize]byte, removeFromCache bool) {
+	if mem.removeLockedTx(txKey, removeFromCache) || mem.removeSpilledTx(txKey, removeFromCache) {
+		return
+	}
 	if e, ok := mem.txsMap.Load(txKey); ok {
 		memTx := e.(*clist.CElement).Value.(*mempoolTx)
-		if memTx != nil {
+		if memTx == nil {
+			return
+		}
+		if memTx.bundle != nil {
+			mem.removeBundle(memTx.bundle, removeFromCache)
+		} else {
 			mem.removeTx(memTx.tx, e.(*clist.CElement), removeFromCache)
 		}
+		mem.refill()
 	}
 }
 
 func (mem *CListMempool) isFull(txSize int) error {
+	return mem.isFullFor(1, txSize)
+}
+
+// isFullFor checks there is room for numTxs more txs of txsSize bytes in total.
+func (mem *CListMempool) isFullFor(numTxs int, txsSize int) error {
 	var (
-		memSize  = mem.Size()
-		txsBytes = mem.TxsBytes()
+		memSize  = mem.Size() + mem.LockedSize()
+		txsBytes = mem.TxsBytes() + mem.LockedTxsBytes()
 	)
 
-	if memSize >= mem.config.Size || int64(txSize)+txsBytes > mem.config.MaxTxsBytes {
+	if memSize+numTxs > mem.config.Size || int64(txsSize)+txsBytes > mem.config.MaxTxsBytes {
 		return ErrMempoolIsFull{
 			memSize, mem.config.Size,
 			txsBytes, mem.config.MaxTxsBytes,
@@ -403,31 +680,58 @@ func (mem *CListMempool) isFull(txSize int) error {
 func (mem *CListMempool) resCbFirstTime(
 	tx []byte,
 	peerID uint16,
+	lock timeLock,
+	now time.Time,
 ) {
 	// Check mempool isn't full again to reduce the chance of exceeding the
-	// limits.
-	if err := mem.isFull(len(tx)); err != nil {
-		// remove from cache (mempool might have a space later)
-		mem.cache.Remove(tx)
-		mem.logger.Error(err.Error())
-		return
+	// limits. With an overflow, spill checks it below.
+	if mem.overflow == nil {
+		if err := mem.isFull(len(tx)); err != nil {
+			// remove from cache (mempool might have a space later)
+			mem.cache.Remove(tx)
+			mem.logger.Error(err.Error())
+			return
+		}
 	}
 
-	fee, txId, err := protoutil.GetTxFeeFromEnvelope(tx)
-	if err != nil {
-		fmt.Printf("Unmarshal unconfirmed transaction failed: %s", err)
-		fee = new(big.Int).SetInt64(0)
-	}
-	if txId == "" {
-		txId = txID(tx)
-	}
+	// a tx whose fee can't be decoded was rejected by CheckTx if fees are
+	// required, otherwise it pays no fee
+	fee, txId, _ := txFee(tx)
 
 	memTx := &mempoolTx{
 		height:    mem.height,
 		gasWanted: fee.Int64(),
 		tx:        tx,
+		txID:      txId,
 	}
 	memTx.senders.Store(peerID, true)
+	if !lock.due(now, mem.height) {
+		mem.holdTx(memTx, lock)
+		mem.logger.Info("Added time-locked transaction to mempool",
+			"txId", txId,
+			"fee", fee,
+			"notBefore", lock.notBefore,
+			"notBeforeHeight", lock.notBeforeHeight,
+		)
+		return
+	}
+	if mem.overflow != nil {
+		spilled, err := mem.spill(memTx)
+		if err != nil {
+			mem.cache.Remove(tx)
+			mem.logger.Error(err.Error())
+			return
+		}
+		if spilled {
+			mem.txIDs.Store(txId, TxKey(tx))
+			mem.logger.Info("Spilled unconfirmed transaction to mempool overflow",
+				"txId", txId,
+				"fee", fee,
+				"spilled", mem.overflow.size(),
+			)
+			return
+		}
+	}
 	mem.addTx(memTx)
 	mem.logger.Info("Added unconfirmed transaction to mempool",
 		"txId", txId,
@@ -461,16 +765,35 @@ func (mem *CListMempool) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
 	mem.updateMtx.RLock()
 	defer mem.updateMtx.RUnlock()
 
+	mem.PromoteDueTxs()
+
 	var totalGas int64
 
 	// TODO: we will get a performance boost if we have a good estimate of avg
 	// size per tx, and set the initial capacity based off of that.
 	// txs := make([]types.Tx, 0, tmmath.MinInt(mem.txs.Len(), max/mem.avgTxSize))
 	txs := make([]types.Tx, 0, mem.txs.Len())
+	reaped := make(map[*txBundle]struct{})
 	for e := mem.txs.Front(); e != nil; e = e.Next() {
 		memTx := e.Value.(*mempoolTx)
 
-		dataSize := types.ComputeProtoSizeForTxs(append(txs, memTx.tx))
+		// bundles are reaped whole, when their first tx is reached
+		unit := []*mempoolTx{memTx}
+		if memTx.bundle != nil {
+			if _, ok := reaped[memTx.bundle]; ok || memTx.bundle.expired(time.Now()) {
+				continue
+			}
+			reaped[memTx.bundle] = struct{}{}
+			unit = memTx.bundle.txs
+		}
+
+		newTxs := txs
+		newTotalGas := totalGas
+		for _, unitTx := range unit {
+			newTxs = append(newTxs, unitTx.tx)
+			newTotalGas += unitTx.gasWanted
+		}
+		dataSize := types.ComputeProtoSizeForTxs(newTxs)
 
 		// Check total size requirement
 		if maxBytes > -1 && dataSize > maxBytes {
@@ -480,29 +803,57 @@ func (mem *CListMempool) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
 		// If maxGas is negative, skip this check.
 		// Since newTotalGas < masGas, which
 		// must be non-negative, it follows that this won't overflow.
-		newTotalGas := totalGas + memTx.gasWanted
 		if maxGas > -1 && newTotalGas > maxGas {
 			return txs
 		}
 		totalGas = newTotalGas
-		txs = append(txs, memTx.tx)
+		txs = newTxs
 	}
 	return txs
 }
 
 // Safe for concurrent use by multiple goroutines.
 func (mem *CListMempool) ReapMaxTxs(max int) types.Txs {
+	return mem.ReapMaxTxsSkipping(max, nil)
+}
+
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) ReapMaxTxsSkipping(max int, skip SkipFunc) types.Txs {
 	mem.updateMtx.RLock()
 	defer mem.updateMtx.RUnlock()
 
+	mem.PromoteDueTxs()
+
 	if max < 0 {
 		max = mem.txs.Len()
 	}
 
 	txs := make([]types.Tx, 0, tmmath.MinInt(mem.txs.Len(), max))
-	for e := mem.txs.Front(); e != nil && len(txs) <= max; e = e.Next() {
+	reaped := make(map[*txBundle]struct{})
+	for e := mem.txs.Front(); e != nil && len(txs) < max; e = e.Next() {
 		memTx := e.Value.(*mempoolTx)
-		txs = append(txs, memTx.tx)
+		if memTx.bundle == nil {
+			if skip == nil || !skip(TxKey(memTx.tx)) {
+				txs = append(txs, memTx.tx)
+			}
+			continue
+		}
+
+		// bundles are reaped whole, when their first tx is reached
+		if _, ok := reaped[memTx.bundle]; ok || memTx.bundle.expired(time.Now()) {
+			continue
+		}
+		if (reapUnit{txs: memTx.bundle.txs}).skipped(skip) {
+			reaped[memTx.bundle] = struct{}{}
+			continue
+		}
+		if len(txs)+len(memTx.bundle.txs) > max {
+			continue
+		}
+		reaped[memTx.bundle] = struct{}{}
+		for _, bundleTx := range memTx.bundle.txs {
+			txs = append(txs, bundleTx.tx)
+		}
 	}
 	return txs
 }
@@ -514,9 +865,21 @@ func (mem *CListMempool) Update(
 	deliverTxResponses []*abci.ResponseDeliverTx,
 	preCheck PreCheckFunc,
 	postCheck PostCheckFunc,
+) error {
+	return mem.UpdateAt(time.Now(), height, txs, deliverTxResponses, preCheck, postCheck)
+}
+
+// Lock() must be help by the caller during execution.
+func (mem *CListMempool) UpdateAt(
+	now time.Time,
+	height int64,
+	txs types.Txs,
+	deliverTxResponses []*abci.ResponseDeliverTx,
+	preCheck PreCheckFunc,
+	postCheck PostCheckFunc,
 ) error {
 	// Set height
-	mem.height = height
+	atomic.StoreInt64(&mem.height, height)
 	mem.notifiedTxsAvailable = false
 
 	if preCheck != nil {
@@ -547,9 +910,18 @@ func (mem *CListMempool) Update(
 		//   100
 		// https://github.com/tendermint/tendermint/issues/3322.
 		if e, ok := mem.txsMap.Load(TxKey(tx)); ok {
+			if bundle := e.(*clist.CElement).Value.(*mempoolTx).bundle; bundle != nil {
+				mem.removeBundle(bundle, false)
+				continue
+			}
 			mem.removeTx(tx, e.(*clist.CElement), false)
+			continue
 		}
+		mem.removeSpilledTx(TxKey(tx), false)
 	}
+	mem.removeExpiredBundles(now)
+	mem.promoteDueTxs(now)
+	mem.refill()
 
 	// Either recheck non-committed txs to see if they became invalid
 	// or just notify there're some txs left.
@@ -584,9 +956,11 @@ func (mem *CListMempool) recheckTxs() {
 
 // mempoolTx is a transaction that successfully ran
 type mempoolTx struct {
-	height    int64    // height that this tx had been validated in
-	gasWanted int64    // amount of gas this tx states it will require
-	tx        types.Tx //
+	height    int64     // height that this tx had been validated in
+	gasWanted int64     // amount of gas this tx states it will require
+	tx        types.Tx  //
+	txID      string    // Fabric txId, or the tx hash if it can't be read
+	bundle    *txBundle // bundle the tx belongs to, nil for a single tx
 
 	// ids of peers who've sent us this tx (as a map for quick lookups).
 	// senders: PeerID -> bool
@@ -604,6 +978,7 @@ type txCache interface {
 	Reset()
 	Push(tx types.Tx) bool
 	Remove(tx types.Tx)
+	Has(txKey [TxKeySize]byte) bool
 }
 
 // mapTxCache maintains a LRU cache of transactions. This only stores the hash
@@ -673,13 +1048,23 @@ func (cache *mapTxCache) Remove(tx types.Tx) {
 	cache.mtx.Unlock()
 }
 
+// Has returns true if the tx of txKey is in the cache.
+func (cache *mapTxCache) Has(txKey [TxKeySize]byte) bool {
+	cache.mtx.Lock()
+	defer cache.mtx.Unlock()
+
+	_, exists := cache.cacheMap[txKey]
+	return exists
+}
+
 type nopTxCache struct{}
 
 var _ txCache = (*nopTxCache)(nil)
 
-func (nopTxCache) Reset()             {}
-func (nopTxCache) Push(types.Tx) bool { return true }
-func (nopTxCache) Remove(types.Tx)    {}
+func (nopTxCache) Reset()                   {}
+func (nopTxCache) Push(types.Tx) bool       { return true }
+func (nopTxCache) Remove(types.Tx)          {}
+func (nopTxCache) Has([TxKeySize]byte) bool { return false }
 
 //--------------------------------------------------------------------------------
 
@@ -595,6 +595,32 @@ func TestMempoolTxsBytes(t *testing.T) {
 
 }
 
+func TestMempoolTxByID(t *testing.T) {
+	config := cfg.ResetTestRoot("mempool_test")
+	defer os.RemoveAll(config.RootDir)
+	mempool := NewCListMempool(config.Mempool, 0)
+	mempool.SetLogger(log.TestingLogger())
+
+	tx := newFeeTx(t, "tx1", 10)
+	locked := newFeeTx(t, "tx2", 10)
+	require.NoError(t, mempool.CheckTx(tx, nil, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(locked, nil, TxInfo{NotBeforeHeight: 10}))
+
+	found, ok := mempool.TxByID("tx1")
+	assert.True(t, ok)
+	assert.Equal(t, tx, found)
+	found, ok = mempool.TxByID("tx2")
+	assert.True(t, ok)
+	assert.Equal(t, locked, found)
+
+	mempool.RemoveTxByKey(TxKey(tx), true)
+	mempool.RemoveTxByKey(TxKey(locked), true)
+	_, ok = mempool.TxByID("tx1")
+	assert.False(t, ok)
+	_, ok = mempool.TxByID("tx2")
+	assert.False(t, ok)
+}
+
 // This will non-deterministically catch some concurrency failures like
 // https://github.com/tendermint/tendermint/issues/3509
 // TODO: all of the tests should probably also run using the remote proxy app
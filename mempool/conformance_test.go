@@ -0,0 +1,19 @@
+package mempool_test
+
+import (
+	"testing"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/mempool/mempooltest"
+)
+
+func TestCListMempoolConformance(t *testing.T) {
+	mempooltest.Run(t, func(t *testing.T, config *cfg.MempoolConfig) mempool.Mempool {
+		config.RootDir = t.TempDir()
+		mem := mempool.NewCListMempool(config, 0)
+		mem.SetLogger(log.NewNopLogger())
+		return mem
+	})
+}
@@ -3,6 +3,8 @@ package mempool
 import (
 	"errors"
 	"fmt"
+	"math/big"
+	"time"
 )
 
 var (
@@ -12,28 +14,61 @@ var (
 
 // ErrTxTooLarge means the tx is too big to be sent in a message to other peers
 type ErrTxTooLarge struct {
-	max    int
-	actual int
+	Max    int
+	Actual int
 }
 
 func (e ErrTxTooLarge) Error() string {
-	return fmt.Sprintf("Tx too large. Max size is %d, but got %d", e.max, e.actual)
+	return fmt.Sprintf("Tx too large. Max size is %d, but got %d", e.Max, e.Actual)
 }
 
 // ErrMempoolIsFull means Tendermint & an application can't handle that much load
 type ErrMempoolIsFull struct {
-	numTxs int
-	maxTxs int
+	NumTxs int
+	MaxTxs int
 
-	txsBytes    int64
-	maxTxsBytes int64
+	TxsBytes    int64
+	MaxTxsBytes int64
 }
 
 func (e ErrMempoolIsFull) Error() string {
 	return fmt.Sprintf(
 		"mempool is full: number of txs %d (max: %d), total txs bytes %d (max: %d)",
-		e.numTxs, e.maxTxs,
-		e.txsBytes, e.maxTxsBytes)
+		e.NumTxs, e.MaxTxs,
+		e.TxsBytes, e.MaxTxsBytes)
+}
+
+// ErrFeeTooLow means the tx fee is below the current minimum fee of the mempool
+type ErrFeeTooLow struct {
+	Fee    *big.Int
+	MinFee *big.Int
+}
+
+func (e ErrFeeTooLow) Error() string {
+	return fmt.Sprintf("tx fee too low: fee %s, min fee %s", e.Fee, e.MinFee)
+}
+
+// ErrTxFee means the fee of a tx can't be decoded from its envelope.
+type ErrTxFee struct {
+	Reason error
+}
+
+func (e ErrTxFee) Error() string {
+	return fmt.Sprintf("tx fee undecodable: %v", e.Reason)
+}
+
+func (e ErrTxFee) Unwrap() error {
+	return e.Reason
+}
+
+// ErrTimeLockTooFar means a tx asks to be held longer than the mempool allows.
+type ErrTimeLockTooFar struct {
+	NotBefore time.Time
+	Max       time.Duration
+}
+
+func (e ErrTimeLockTooFar) Error() string {
+	return fmt.Sprintf("tx time lock too far: not before %s, max %s from now", e.NotBefore.Format(time.RFC3339), e.Max)
 }
 
 // ErrPreCheck is returned when tx is too big
@@ -0,0 +1,203 @@
+package mempool
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/tendermint/tendermint/types"
+)
+
+const (
+	// EstimatorSuccessRatio is the share of recently reaped txs paying at
+	// least the estimated fee which must have been reaped within the target.
+	EstimatorSuccessRatio = 0.9
+
+	defaultMaxSamples = 10000
+	defaultMaxRounds  = 1000
+)
+
+// feeSample records the fee of a reaped tx and how many fetch rounds it waited.
+type feeSample struct {
+	fee  int64
+	wait int64
+}
+
+// FeeEstimate is the answer of FeeEstimator.EstimateFee.
+type FeeEstimate struct {
+	TargetRounds int   `json:"target_rounds"`
+	Fee          int64 `json:"fee"`            // the suggested fee limit
+	MinFee       int64 `json:"min_fee"`        // current admission floor of the mempool
+	PoolFee      int64 `json:"pool_fee"`       // fee needed to outbid the pending txs
+	HistoryFee   int64 `json:"history_fee"`    // fee which recently got reaped within target
+	AvgBatchSize int64 `json:"avg_batch_size"` // average txs reaped per fetch round
+	Samples      int   `json:"samples"`
+	// Percentiles of the fees pending in the mempool, keyed by percentile.
+	Percentiles map[int]int64 `json:"percentiles"`
+}
+
+// FeeEstimator suggests fee limits from the fees of txs reaped in the last
+// fetch rounds and the fees currently pending in the mempool.
+// Every call of TxsReaped is a fetch round.
+type FeeEstimator struct {
+	mtx     sync.Mutex
+	mempool *CListMempool
+
+	round     int64
+	admitted  map[[TxKeySize]byte]int64 // txKey -> admission round
+	byRound   map[int64][][TxKeySize]byte
+	maxRounds int64
+
+	samples    []feeSample // ring buffer
+	next       int
+	maxSamples int
+	batches    []int // reaped txs of the last rounds, ring buffer
+}
+
+// NewFeeEstimator returns an estimator for txs of mempool.
+func NewFeeEstimator(mempool *CListMempool) *FeeEstimator {
+	return &FeeEstimator{
+		mempool:    mempool,
+		admitted:   make(map[[TxKeySize]byte]int64),
+		byRound:    make(map[int64][][TxKeySize]byte),
+		maxRounds:  defaultMaxRounds,
+		samples:    make([]feeSample, 0, defaultMaxSamples),
+		maxSamples: defaultMaxSamples,
+		batches:    make([]int, 0, 100),
+	}
+}
+
+// TxAdmitted records the round tx was added to the mempool in.
+func (est *FeeEstimator) TxAdmitted(tx types.Tx) {
+	est.mtx.Lock()
+	defer est.mtx.Unlock()
+
+	key := TxKey(tx)
+	est.admitted[key] = est.round
+	est.byRound[est.round] = append(est.byRound[est.round], key)
+}
+
+// TxsReaped closes a fetch round in which txs were reaped.
+func (est *FeeEstimator) TxsReaped(txs types.Txs) {
+	est.mtx.Lock()
+	defer est.mtx.Unlock()
+
+	for _, tx := range txs {
+		key := TxKey(tx)
+		admitted, ok := est.admitted[key]
+		if !ok {
+			continue
+		}
+		delete(est.admitted, key)
+		fee, _, _ := txFee(tx)
+		est.addSample(feeSample{fee: fee.Int64(), wait: est.round - admitted + 1})
+	}
+
+	if len(est.batches) < cap(est.batches) {
+		est.batches = append(est.batches, len(txs))
+	} else {
+		est.batches[int(est.round)%len(est.batches)] = len(txs)
+	}
+
+	// forget txs which have been pending for longer than we look back
+	if keys, ok := est.byRound[est.round-est.maxRounds]; ok {
+		for _, key := range keys {
+			if est.admitted[key] == est.round-est.maxRounds {
+				delete(est.admitted, key)
+			}
+		}
+		delete(est.byRound, est.round-est.maxRounds)
+	}
+	est.round++
+}
+
+func (est *FeeEstimator) addSample(sample feeSample) {
+	if len(est.samples) < est.maxSamples {
+		est.samples = append(est.samples, sample)
+		return
+	}
+	est.samples[est.next] = sample
+	est.next = (est.next + 1) % est.maxSamples
+}
+
+// EstimateFee returns the fee limit a tx should pay to be reaped within
+// targetRounds fetch rounds.
+func (est *FeeEstimator) EstimateFee(targetRounds int) *FeeEstimate {
+	if targetRounds < 1 {
+		targetRounds = 1
+	}
+	est.mtx.Lock()
+	samples := append([]feeSample(nil), est.samples...)
+	var reaped int
+	for _, n := range est.batches {
+		reaped += n
+	}
+	var avgBatch int64
+	if len(est.batches) > 0 {
+		avgBatch = int64(math.Ceil(float64(reaped) / float64(len(est.batches))))
+	}
+	est.mtx.Unlock()
+
+	poolFees := est.mempool.TxFees()
+	estimate := &FeeEstimate{
+		TargetRounds: targetRounds,
+		MinFee:       est.mempool.MinFee().Int64(),
+		HistoryFee:   historyFee(samples, int64(targetRounds)),
+		AvgBatchSize: avgBatch,
+		Samples:      len(samples),
+		Percentiles:  make(map[int]int64),
+	}
+
+	// a tx gets reaped in time if it outbids the pending txs beyond
+	// the capacity of the next targetRounds rounds
+	if capacity := avgBatch * int64(targetRounds); capacity > 0 && int64(len(poolFees)) >= capacity {
+		estimate.PoolFee = poolFees[capacity-1] + 1
+	}
+	for _, p := range []int{10, 25, 50, 75, 90} {
+		if n := len(poolFees); n > 0 {
+			// nearest rank, poolFees is sorted in descending order
+			rank := int(math.Ceil(float64(p) / 100 * float64(n)))
+			estimate.Percentiles[p] = poolFees[n-rank]
+		}
+	}
+
+	estimate.Fee = estimate.MinFee
+	if estimate.PoolFee > estimate.Fee {
+		estimate.Fee = estimate.PoolFee
+	}
+	if estimate.HistoryFee > estimate.Fee {
+		estimate.Fee = estimate.HistoryFee
+	}
+	return estimate
+}
+
+// historyFee returns the lowest fee for which at least EstimatorSuccessRatio
+// of the sampled txs paying as much or more were reaped within target rounds.
+func historyFee(samples []feeSample, target int64) int64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].fee > samples[j].fee })
+
+	var fee int64
+	found := false
+	var total, inTime int
+	for i, s := range samples {
+		total++
+		if s.wait <= target {
+			inTime++
+		}
+		// only consider a fee once all samples paying it have been counted
+		if i+1 < len(samples) && samples[i+1].fee == s.fee {
+			continue
+		}
+		if float64(inTime)/float64(total) >= EstimatorSuccessRatio {
+			fee, found = s.fee, true
+		}
+	}
+	if !found {
+		// nothing got reaped in time, suggest the highest fee seen
+		return samples[0].fee
+	}
+	return fee
+}
@@ -0,0 +1,86 @@
+package mempool
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+func TestFeeEstimator(t *testing.T) {
+	config := cfg.ResetTestRoot("mempool_test")
+	defer os.RemoveAll(config.RootDir)
+	mempool := NewCListMempool(config.Mempool, 0)
+	mempool.SetLogger(log.TestingLogger())
+	est := NewFeeEstimator(mempool)
+
+	// an empty pool without history only asks for the min fee
+	estimate := est.EstimateFee(1)
+	assert.EqualValues(t, 0, estimate.Fee)
+	assert.Equal(t, 0, estimate.Samples)
+
+	submit := func(round, fee int) types.Tx {
+		tx := newFeeTx(t, fmt.Sprintf("tx-%d-%d", round, fee), int64(fee))
+		require.NoError(t, mempool.CheckTx(tx, nil, TxInfo{}))
+		est.TxAdmitted(tx)
+		return tx
+	}
+	reap := func(max int) {
+		txs := mempool.ReapMaxTxsBySort(max)
+		est.TxsReaped(txs)
+		mempool.Lock()
+		require.NoError(t, mempool.Update(1, txs, nil, nil, nil))
+		mempool.Unlock()
+	}
+
+	// 20 txs are submitted every round and orderers reap the 10 best
+	for round := 0; round < 10; round++ {
+		for fee := 1; fee <= 20; fee++ {
+			submit(round, fee*10)
+		}
+		reap(10)
+	}
+
+	estimate = est.EstimateFee(1)
+	assert.EqualValues(t, 10, estimate.AvgBatchSize)
+	assert.Equal(t, 100, estimate.Samples)
+	// the cheapest pending txs paying 10 to 100 were never outbid in time
+	assert.Equal(t, 100, mempool.Size())
+	assert.EqualValues(t, 101, estimate.PoolFee)
+	assert.EqualValues(t, 110, estimate.HistoryFee)
+	assert.EqualValues(t, 110, estimate.Fee)
+	assert.EqualValues(t, 90, estimate.Percentiles[90])
+	assert.EqualValues(t, 10, estimate.Percentiles[10])
+
+	// a burst of expensive txs raises the bar above the history
+	for i := 0; i < 20; i++ {
+		submit(100+i, 500)
+	}
+	estimate = est.EstimateFee(1)
+	assert.EqualValues(t, 501, estimate.PoolFee)
+	assert.EqualValues(t, 501, estimate.Fee)
+	// waiting for the burst to drain is cheaper
+	assert.EqualValues(t, 110, est.EstimateFee(3).Fee)
+}
+
+func TestHistoryFee(t *testing.T) {
+	samples := []feeSample{
+		{fee: 100, wait: 1},
+		{fee: 90, wait: 1},
+		{fee: 80, wait: 2},
+		{fee: 50, wait: 5},
+		{fee: 10, wait: 9},
+	}
+	assert.EqualValues(t, 90, historyFee(append([]feeSample(nil), samples...), 1))
+	assert.EqualValues(t, 80, historyFee(append([]feeSample(nil), samples...), 2))
+	assert.EqualValues(t, 50, historyFee(append([]feeSample(nil), samples...), 5))
+	assert.EqualValues(t, 10, historyFee(append([]feeSample(nil), samples...), 9))
+	assert.EqualValues(t, 100, historyFee([]feeSample{{fee: 100, wait: 3}}, 1))
+	assert.EqualValues(t, 0, historyFee(nil, 1))
+}
@@ -0,0 +1,73 @@
+package mempool
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// Names of the supported minimum fee curves.
+const (
+	FeeCurveLinear      = "linear"
+	FeeCurveQuadratic   = "quadratic"
+	FeeCurveExponential = "exponential"
+	FeeCurveStep        = "step"
+)
+
+// MinFeeFunc returns the minimum fee a tx must pay to be admitted when the
+// mempool occupancy is occupancy, a value in [0, 1].
+type MinFeeFunc func(occupancy float64) *big.Int
+
+// NewMinFeeFunc returns a MinFeeFunc which stays at base until the occupancy
+// reaches threshold and then rises along curve up to max when the mempool is
+// full.
+func NewMinFeeFunc(curve string, base, max int64, threshold float64) (MinFeeFunc, error) {
+	if base < 0 || max < base {
+		return nil, fmt.Errorf("invalid fee range [%d, %d]", base, max)
+	}
+	if threshold < 0 || threshold >= 1 {
+		return nil, fmt.Errorf("threshold must be in [0, 1), got %v", threshold)
+	}
+
+	var shape func(t float64) float64
+	switch curve {
+	case FeeCurveLinear:
+		shape = func(t float64) float64 { return t }
+	case FeeCurveQuadratic:
+		shape = func(t float64) float64 { return t * t }
+	case FeeCurveExponential:
+		// grows from base to max by a constant factor, 1 stands in for a zero base
+		low := math.Max(float64(base), 1)
+		shape = func(t float64) float64 {
+			return (low*math.Pow(float64(max)/low, t) - float64(base)) / float64(max-base)
+		}
+	case FeeCurveStep:
+		shape = func(t float64) float64 { return 1 }
+	default:
+		return nil, fmt.Errorf("unknown fee curve %q", curve)
+	}
+
+	return func(occupancy float64) *big.Int {
+		if occupancy < threshold || max == base {
+			return big.NewInt(base)
+		}
+		t := math.Min((occupancy-threshold)/(1-threshold), 1)
+		return big.NewInt(base + int64(math.Round(shape(t)*float64(max-base))))
+	}, nil
+}
+
+// txFee returns the fee limit and the id of an envelope. Txs which can't be
+// decoded pay no fee and are identified by their hash.
+func txFee(tx types.Tx) (*big.Int, string, error) {
+	fee, txId, err := protoutil.GetTxFeeFromEnvelope(tx)
+	if err != nil {
+		fee = new(big.Int).SetInt64(0)
+	}
+	if txId == "" {
+		txId = txID(tx)
+	}
+	return fee, txId, err
+}
@@ -0,0 +1,116 @@
+package mempool
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+// newFeeTx returns an envelope paying fee, txId makes it unique.
+func newFeeTx(t *testing.T, txId string, fee int64) types.Tx {
+	chdr, err := proto.Marshal(&cb.ChannelHeader{
+		TxId:     txId,
+		FeeLimit: []byte(strconv.FormatInt(fee, 10)),
+	})
+	require.NoError(t, err)
+	payload, err := proto.Marshal(&cb.Payload{Header: &cb.Header{ChannelHeader: chdr}})
+	require.NoError(t, err)
+	env, err := proto.Marshal(&cb.Envelope{Payload: payload})
+	require.NoError(t, err)
+	return env
+}
+
+func TestNewMinFeeFunc(t *testing.T) {
+	tests := []struct {
+		curve     string
+		occupancy float64
+		expected  int64
+	}{
+		{FeeCurveLinear, 0, 10},
+		{FeeCurveLinear, 0.5, 10},
+		{FeeCurveLinear, 0.75, 55},
+		{FeeCurveLinear, 1, 100},
+		{FeeCurveQuadratic, 0.75, 33},
+		{FeeCurveQuadratic, 1, 100},
+		{FeeCurveExponential, 0.5, 10},
+		{FeeCurveExponential, 0.75, 32},
+		{FeeCurveExponential, 1, 100},
+		{FeeCurveStep, 0.49, 10},
+		{FeeCurveStep, 0.5, 100},
+		{FeeCurveLinear, 2, 100},
+	}
+	for i, tt := range tests {
+		minFee, err := NewMinFeeFunc(tt.curve, 10, 100, 0.5)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, minFee(tt.occupancy).Int64(), "tc #%d %s at %v", i, tt.curve, tt.occupancy)
+	}
+
+	_, err := NewMinFeeFunc("cubic", 10, 100, 0.5)
+	assert.Error(t, err)
+	_, err = NewMinFeeFunc(FeeCurveLinear, 100, 10, 0.5)
+	assert.Error(t, err)
+	_, err = NewMinFeeFunc(FeeCurveLinear, 10, 100, 1)
+	assert.Error(t, err)
+}
+
+func TestCheckTxRejectsFeeBelowFloor(t *testing.T) {
+	config := cfg.ResetTestRoot("mempool_test")
+	defer os.RemoveAll(config.RootDir)
+	config.Mempool.Size = 4
+	minFee, err := NewMinFeeFunc(FeeCurveLinear, 0, 100, 0.5)
+	require.NoError(t, err)
+	mempool := NewCListMempool(config.Mempool, 0, WithMinFee(minFee))
+	mempool.SetLogger(log.TestingLogger())
+
+	assert.Equal(t, big.NewInt(0), mempool.MinFee())
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx1", 0), nil, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx2", 0), nil, TxInfo{}))
+
+	// half full, the floor starts rising
+	assert.Equal(t, big.NewInt(0), mempool.MinFee())
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx3", 0), nil, TxInfo{}))
+
+	// three quarters full
+	assert.Equal(t, big.NewInt(50), mempool.MinFee())
+	err = mempool.CheckTx(newFeeTx(t, "tx4", 49), nil, TxInfo{})
+	if assert.IsType(t, ErrFeeTooLow{}, err) {
+		assert.Equal(t, big.NewInt(50), err.(ErrFeeTooLow).MinFee)
+	}
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx5", 50), nil, TxInfo{}))
+	assert.Equal(t, 4, mempool.Size())
+
+	// a rejected tx can be submitted again with a higher fee once there is room
+	mempool.Lock()
+	require.NoError(t, mempool.Update(1, types.Txs{newFeeTx(t, "tx1", 0)}, nil, nil, nil))
+	mempool.Unlock()
+	assert.Equal(t, big.NewInt(50), mempool.MinFee())
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx4", 60), nil, TxInfo{}))
+}
+
+func TestCheckTxRejectsUndecodableFee(t *testing.T) {
+	config := cfg.ResetTestRoot("mempool_test")
+	defer os.RemoveAll(config.RootDir)
+	mempool := NewCListMempool(config.Mempool, 0, WithRequireFee())
+	mempool.SetLogger(log.TestingLogger())
+
+	var feeErr ErrTxFee
+	err := mempool.CheckTx(types.Tx("not an envelope"), nil, TxInfo{})
+	assert.True(t, errors.As(err, &feeErr), "got %v", err)
+	err = mempool.CheckBundle(types.Txs{newFeeTx(t, "tx1", 1), types.Tx("not an envelope")}, 0, TxInfo{})
+	assert.True(t, errors.As(err, &feeErr), "got %v", err)
+	assert.Zero(t, mempool.Size())
+
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "tx2", 1), nil, TxInfo{}))
+	assert.Equal(t, 1, mempool.Size())
+}
@@ -2,6 +2,8 @@ package mempool
 
 import (
 	"fmt"
+	"math/big"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/p2p"
@@ -17,6 +19,11 @@ type Mempool interface {
 	// its validity and whether it should be added to the mempool.
 	CheckTx(tx types.Tx, callback func(*abci.Response), txInfo TxInfo) error
 
+	// CheckBundle adds txs to the mempool as a bundle which is admitted,
+	// reaped and removed as a whole. The bundle expires after ttl, unless it
+	// is zero.
+	CheckBundle(txs types.Txs, ttl time.Duration, txInfo TxInfo) error
+
 	// ReapMaxBytesMaxGas reaps transactions from the mempool up to maxBytes
 	// bytes total with the condition that the total gasWanted must be less than
 	// maxGas.
@@ -31,6 +38,14 @@ type Mempool interface {
 
 	ReapMaxTxsBySort(max int) types.Txs
 
+	// ReapMaxTxsSkipping reaps like ReapMaxTxs, leaving out the txs for which
+	// skip returns true. A bundle is left out whole if any of its txs is.
+	ReapMaxTxsSkipping(max int, skip SkipFunc) types.Txs
+
+	// ReapMaxTxsBySortSkipping reaps like ReapMaxTxsBySort, leaving out the
+	// txs like ReapMaxTxsSkipping.
+	ReapMaxTxsBySortSkipping(max int, skip SkipFunc) types.Txs
+
 	// Lock locks the mempool. The consensus must be able to hold lock to safely update.
 	Lock()
 
@@ -48,6 +63,18 @@ type Mempool interface {
 		newPostFn PostCheckFunc,
 	) error
 
+	// UpdateAt updates like Update, expiring the bundles and promoting the
+	// time-locked txs as of now rather than the local time, so that replicas
+	// applying the same updates agree.
+	UpdateAt(
+		now time.Time,
+		blockHeight int64,
+		blockTxs types.Txs,
+		deliverTxResponses []*abci.ResponseDeliverTx,
+		newPreFn PreCheckFunc,
+		newPostFn PostCheckFunc,
+	) error
+
 	// FlushAppConn flushes the mempool connection to ensure async reqResCb calls are
 	// done. E.g. from CheckTx.
 	// NOTE: Lock/Unlock must be managed by caller
@@ -71,6 +98,30 @@ type Mempool interface {
 	// TxsBytes returns the total size of all txs in the mempool.
 	TxsBytes() int64
 
+	// MinFee returns the minimum fee a tx must currently pay to be admitted.
+	MinFee() *big.Int
+
+	// TxFees returns the fees of all txs in the mempool, highest first.
+	TxFees() []int64
+
+	// UnitFees returns the fees of the units ReapMaxTxsBySort(max) reaps, in
+	// the order they are reaped. A bundle is a single unit.
+	UnitFees(max int) []UnitFee
+
+	// PromoteDueTxs makes the time-locked txs whose lock is due reapable.
+	PromoteDueTxs()
+
+	// TxByID returns the pending tx with the given Fabric txId.
+	TxByID(txID string) (types.Tx, bool)
+
+	// BundleTxs returns the txs of the bundle of the pending tx with the
+	// given Fabric txId, the tx alone if it isn't bundled.
+	BundleTxs(txID string) types.Txs
+
+	// RemoveTxByKey removes a pending tx, and the rest of its bundle, by its
+	// TxKey. The tx stays in the cache unless removeFromCache is set.
+	RemoveTxByKey(txKey [TxKeySize]byte, removeFromCache bool)
+
 	// InitWAL creates a directory for the WAL file and opens a file itself. If
 	// there is an error, it will be of type *PathError.
 	InitWAL() error
@@ -82,6 +133,10 @@ type Mempool interface {
 
 //--------------------------------------------------------------------------------
 
+// SkipFunc tells a tx reaped by its TxKey must be left out, e.g. since it is
+// already handed out.
+type SkipFunc func(txKey [TxKeySize]byte) bool
+
 // PreCheckFunc is an optional filter executed before CheckTx and rejects
 // transaction if false is returned. An example would be to ensure that a
 // transaction doesn't exceeded the block size.
@@ -92,6 +147,12 @@ type PreCheckFunc func(types.Tx) error
 // transaction doesn't require more gas than available for the block.
 type PostCheckFunc func(types.Tx, *abci.ResponseCheckTx) error
 
+// UnitFee is the fee of a unit reaped whole, a tx or a bundle of Txs txs.
+type UnitFee struct {
+	Fee int64
+	Txs int
+}
+
 // TxInfo are parameters that get passed when attempting to add a tx to the
 // mempool.
 type TxInfo struct {
@@ -100,6 +161,22 @@ type TxInfo struct {
 	SenderID uint16
 	// SenderP2PID is the actual p2p.ID of the sender, used e.g. for logging.
 	SenderP2PID p2p.ID
+	// NotBefore and NotBeforeHeight keep the tx from being reaped before the
+	// given time and block height, zero values don't lock the tx.
+	NotBefore       time.Time
+	NotBeforeHeight int64
+	// Now is the time the tx is admitted at, which its lock and the expiry of
+	// its bundle are checked against. Zero is the local time, replicas set it
+	// to admit the same txs alike.
+	Now time.Time
+}
+
+// At returns the time the tx is admitted at.
+func (info TxInfo) At() time.Time {
+	if info.Now.IsZero() {
+		return time.Now()
+	}
+	return info.Now
 }
 
 //--------------------------------------------------------------------------------
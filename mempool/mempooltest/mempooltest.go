@@ -0,0 +1,481 @@
+// Package mempooltest provides a conformance suite for implementations of
+// mempool.Mempool.
+//
+// An implementation runs the suite from one of its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		mempooltest.Run(t, func(t *testing.T, config *cfg.MempoolConfig) mempool.Mempool {
+//			return NewMempool(config)
+//		})
+//	}
+package mempooltest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/mempool"
+)
+
+// NewMempoolFunc returns an empty mempool at height 0, honouring the Size,
+// MaxTxsBytes, MaxTxBytes and CacheSize limits of config. It has no minimum
+// fee and doesn't lock txs beyond their TxInfo.
+type NewMempoolFunc func(t *testing.T, config *cfg.MempoolConfig) mempool.Mempool
+
+// Run runs the conformance suite against the mempools of newMempool.
+func Run(t *testing.T, newMempool NewMempoolFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, newMempool NewMempoolFunc)
+	}{
+		{"ReapInArrivalOrder", testReapInArrivalOrder},
+		{"ReapByFee", testReapByFee},
+		{"ReapMaxTxs", testReapMaxTxs},
+		{"ReapMaxBytesMaxGas", testReapMaxBytesMaxGas},
+		{"ReapSkipping", testReapSkipping},
+		{"Dedupe", testDedupe},
+		{"Limits", testLimits},
+		{"Update", testUpdate},
+		{"Bundles", testBundles},
+		{"TimeLock", testTimeLock},
+		{"UpdateAt", testUpdateAt},
+		{"TxByID", testTxByID},
+		{"Flush", testFlush},
+		{"TxsAvailable", testTxsAvailable},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newMempool)
+		})
+	}
+}
+
+// Config returns the limits of the mempools created by the suite.
+func Config() *cfg.MempoolConfig {
+	config := cfg.DefaultMempoolConfig()
+	config.Recheck = false
+	config.Size = 100
+	config.MaxTxsBytes = 1 << 20
+	config.MaxTxBytes = 1 << 10
+	config.CacheSize = 1000
+	return config
+}
+
+// NewTx returns an envelope paying fee, txId makes it unique.
+func NewTx(t *testing.T, txId string, fee int64) types.Tx {
+	chdr, err := proto.Marshal(&cb.ChannelHeader{
+		TxId:     txId,
+		FeeLimit: []byte(strconv.FormatInt(fee, 10)),
+	})
+	require.NoError(t, err)
+	payload, err := proto.Marshal(&cb.Payload{Header: &cb.Header{ChannelHeader: chdr}})
+	require.NoError(t, err)
+	env, err := proto.Marshal(&cb.Envelope{Payload: payload})
+	require.NoError(t, err)
+	return env
+}
+
+// checkTxs adds a tx for every fee, in order, and returns them.
+func checkTxs(t *testing.T, mem mempool.Mempool, prefix string, fees ...int64) types.Txs {
+	txs := make(types.Txs, len(fees))
+	for i, fee := range fees {
+		txs[i] = NewTx(t, fmt.Sprintf("%s-%d", prefix, i), fee)
+		require.NoError(t, mem.CheckTx(txs[i], nil, mempool.TxInfo{}))
+	}
+	return txs
+}
+
+func update(t *testing.T, mem mempool.Mempool, height int64, txs types.Txs) {
+	mem.Lock()
+	defer mem.Unlock()
+	require.NoError(t, mem.Update(height, txs, nil, nil, nil))
+}
+
+func txsBytes(txs types.Txs) int64 {
+	var n int64
+	for _, tx := range txs {
+		n += int64(len(tx))
+	}
+	return n
+}
+
+func testReapInArrivalOrder(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	assert.Empty(t, mem.ReapMaxTxs(-1))
+	txs := checkTxs(t, mem, "tx", 30, 10, 20)
+	assert.Equal(t, 3, mem.Size())
+	assert.Equal(t, txsBytes(txs), mem.TxsBytes())
+	assert.Equal(t, txs, mem.ReapMaxTxs(-1))
+	assert.Equal(t, txs, mem.ReapMaxBytesMaxGas(-1, -1))
+
+	// reaping doesn't remove txs
+	assert.Equal(t, 3, mem.Size())
+}
+
+func testReapByFee(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 30, 10, 50, 20, 40)
+	assert.Equal(t, types.Txs{txs[2], txs[4], txs[0], txs[3], txs[1]}, mem.ReapMaxTxsBySort(-1))
+	assert.Equal(t, types.Txs{txs[2], txs[4]}, mem.ReapMaxTxsBySort(2))
+	assert.Equal(t, []int64{50, 40, 30, 20, 10}, mem.TxFees())
+	assert.True(t, mem.MinFee().Sign() >= 0)
+}
+
+func testReapMaxTxs(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 10, 20, 30, 40, 50)
+	for max := 0; max <= len(txs)+1; max++ {
+		expected := txs
+		if max < len(txs) {
+			expected = txs[:max]
+		}
+		assert.Equal(t, len(expected), len(mem.ReapMaxTxs(max)), "ReapMaxTxs(%d)", max)
+		assert.Equal(t, expected, append(types.Txs{}, mem.ReapMaxTxs(max)...), "ReapMaxTxs(%d)", max)
+		assert.Equal(t, len(expected), len(mem.ReapMaxTxsBySort(max)), "ReapMaxTxsBySort(%d)", max)
+	}
+}
+
+func testReapSkipping(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 10, 30, 20)
+	bundle := types.Txs{NewTx(t, "transfer", 20), NewTx(t, "settle", 20)}
+	require.NoError(t, mem.CheckBundle(bundle, 0, mempool.TxInfo{}))
+	skipped := map[[mempool.TxKeySize]byte]bool{mempool.TxKey(txs[1]): true, mempool.TxKey(bundle[1]): true}
+	skip := func(txKey [mempool.TxKeySize]byte) bool { return skipped[txKey] }
+
+	// the skipped txs leave room for the others, a bundle is skipped whole
+	assert.Equal(t, types.Txs{txs[0], txs[2]}, mem.ReapMaxTxsSkipping(-1, skip))
+	assert.Equal(t, types.Txs{txs[0]}, mem.ReapMaxTxsSkipping(1, skip))
+	assert.Equal(t, types.Txs{txs[2], txs[0]}, mem.ReapMaxTxsBySortSkipping(-1, skip))
+	assert.Equal(t, types.Txs{txs[2]}, mem.ReapMaxTxsBySortSkipping(1, skip))
+	assert.Equal(t, mem.ReapMaxTxs(-1), mem.ReapMaxTxsSkipping(-1, nil))
+	assert.Equal(t, 5, mem.Size())
+}
+
+func testReapMaxBytesMaxGas(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	// the gas wanted of a tx is its fee
+	txs := checkTxs(t, mem, "tx", 10, 20, 30)
+	size := types.ComputeProtoSizeForTxs(txs[:2])
+	assert.Equal(t, txs[:2], mem.ReapMaxBytesMaxGas(size, -1))
+	assert.Equal(t, txs[:1], mem.ReapMaxBytesMaxGas(size-1, -1))
+	assert.Equal(t, txs[:2], mem.ReapMaxBytesMaxGas(-1, 30))
+	assert.Equal(t, txs[:1], mem.ReapMaxBytesMaxGas(-1, 29))
+	assert.Empty(t, mem.ReapMaxBytesMaxGas(-1, 0))
+}
+
+func testDedupe(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 10, 20, 30)
+	assert.Equal(t, mempool.ErrTxInCache, mem.CheckTx(txs[0], nil, mempool.TxInfo{SenderID: 1}))
+	assert.Equal(t, 3, mem.Size())
+
+	// a committed tx stays in the cache
+	update(t, mem, 1, txs[:1])
+	assert.Equal(t, mempool.ErrTxInCache, mem.CheckTx(txs[0], nil, mempool.TxInfo{}))
+
+	// a tx removed from the cache can be added again
+	mem.RemoveTxByKey(mempool.TxKey(txs[1]), false)
+	assert.Equal(t, mempool.ErrTxInCache, mem.CheckTx(txs[1], nil, mempool.TxInfo{}))
+	mem.RemoveTxByKey(mempool.TxKey(txs[2]), true)
+	assert.NoError(t, mem.CheckTx(txs[2], nil, mempool.TxInfo{}))
+	assert.Equal(t, types.Txs{txs[2]}, mem.ReapMaxTxs(-1))
+}
+
+func testLimits(t *testing.T, newMempool NewMempoolFunc) {
+	config := Config()
+	config.Size = 3
+	mem := newMempool(t, config)
+
+	txs := checkTxs(t, mem, "tx", 10, 20, 30)
+	err := mem.CheckTx(NewTx(t, "full", 40), nil, mempool.TxInfo{})
+	assert.IsType(t, mempool.ErrMempoolIsFull{}, err)
+	assert.Equal(t, 3, mem.Size())
+
+	// a removed tx makes room
+	mem.RemoveTxByKey(mempool.TxKey(txs[0]), true)
+	assert.NoError(t, mem.CheckTx(NewTx(t, "room", 40), nil, mempool.TxInfo{}))
+
+	config = Config()
+	txs = types.Txs{NewTx(t, "a", 10), NewTx(t, "b", 20), NewTx(t, "c", 30)}
+	config.MaxTxsBytes = txsBytes(txs[:2])
+	mem = newMempool(t, config)
+	require.NoError(t, mem.CheckTx(txs[0], nil, mempool.TxInfo{}))
+	require.NoError(t, mem.CheckTx(txs[1], nil, mempool.TxInfo{}))
+	err = mem.CheckTx(txs[2], nil, mempool.TxInfo{})
+	assert.IsType(t, mempool.ErrMempoolIsFull{}, err)
+
+	config = Config()
+	mem = newMempool(t, config)
+	large := NewTx(t, string(make([]byte, config.MaxTxBytes)), 10)
+	err = mem.CheckTx(large, nil, mempool.TxInfo{})
+	assert.Equal(t, mempool.ErrTxTooLarge{Max: config.MaxTxBytes, Actual: len(large)}, err)
+	assert.Zero(t, mem.Size())
+	assert.Zero(t, mem.TxsBytes())
+}
+
+func testUpdate(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 10, 20, 30, 40)
+	unknown := NewTx(t, "unknown", 50)
+	update(t, mem, 1, types.Txs{txs[2], unknown, txs[0]})
+	assert.Equal(t, types.Txs{txs[1], txs[3]}, mem.ReapMaxTxs(-1))
+	assert.Equal(t, 2, mem.Size())
+	assert.Equal(t, txsBytes(types.Txs{txs[1], txs[3]}), mem.TxsBytes())
+
+	// a tx unknown at the update was never admitted
+	assert.NoError(t, mem.CheckTx(unknown, nil, mempool.TxInfo{}))
+
+	update(t, mem, 2, nil)
+	assert.Equal(t, 3, mem.Size())
+}
+
+func testBundles(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	single := checkTxs(t, mem, "single", 30)[0]
+	bundle := types.Txs{NewTx(t, "transfer", 20), NewTx(t, "settle", 20)}
+
+	// a tx already seen rejects the whole bundle
+	assert.Equal(t, mempool.ErrTxInCache, mem.CheckBundle(types.Txs{bundle[0], single}, 0, mempool.TxInfo{}))
+	assert.Error(t, mem.CheckBundle(types.Txs{bundle[0], bundle[0]}, 0, mempool.TxInfo{}))
+	assert.Error(t, mem.CheckBundle(nil, 0, mempool.TxInfo{}))
+	assert.Equal(t, 1, mem.Size())
+
+	require.NoError(t, mem.CheckBundle(bundle, 0, mempool.TxInfo{}))
+	assert.Equal(t, 3, mem.Size())
+
+	// bundles are reaped whole, by their aggregate fee 40
+	assert.Equal(t, types.Txs{bundle[0], bundle[1], single}, mem.ReapMaxTxsBySort(-1))
+	assert.Equal(t, types.Txs{single}, mem.ReapMaxTxsBySort(1))
+	assert.Equal(t, []mempool.UnitFee{{Fee: 40, Txs: 2}, {Fee: 30, Txs: 1}}, mem.UnitFees(-1))
+	assert.Equal(t, []mempool.UnitFee{{Fee: 30, Txs: 1}}, mem.UnitFees(1))
+	assert.Equal(t, types.Txs{single}, mem.ReapMaxTxs(2))
+	assert.Equal(t, types.Txs{single, bundle[0], bundle[1]}, mem.ReapMaxTxs(3))
+	assert.Equal(t, bundle, mem.BundleTxs("settle"))
+	assert.Equal(t, types.Txs{single}, mem.BundleTxs("single-0"))
+	assert.Empty(t, mem.BundleTxs("missing"))
+
+	// a bundle is committed and removed whole
+	update(t, mem, 1, bundle[1:])
+	assert.Equal(t, types.Txs{single}, mem.ReapMaxTxs(-1))
+
+	// an expired bundle isn't reaped and is removed by the next update
+	expiring := types.Txs{NewTx(t, "expiring-0", 10), NewTx(t, "expiring-1", 10)}
+	require.NoError(t, mem.CheckBundle(expiring, 50*time.Millisecond, mempool.TxInfo{}))
+	assert.Equal(t, types.Txs{single, expiring[0], expiring[1]}, mem.ReapMaxTxs(-1))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, types.Txs{single}, mem.ReapMaxTxs(-1))
+	update(t, mem, 2, nil)
+	assert.Equal(t, 1, mem.Size())
+	assert.NoError(t, mem.CheckBundle(expiring, 0, mempool.TxInfo{}))
+}
+
+func testTimeLock(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	byHeight := NewTx(t, "by-height", 30)
+	require.NoError(t, mem.CheckTx(byHeight, nil, mempool.TxInfo{NotBeforeHeight: 2}))
+	byTime := NewTx(t, "by-time", 20)
+	require.NoError(t, mem.CheckTx(byTime, nil, mempool.TxInfo{NotBefore: time.Now().Add(50 * time.Millisecond)}))
+	free := checkTxs(t, mem, "free", 10)[0]
+
+	// locked txs are neither counted nor reaped
+	assert.Equal(t, 1, mem.Size())
+	assert.Equal(t, txsBytes(types.Txs{free}), mem.TxsBytes())
+	assert.Equal(t, types.Txs{free}, mem.ReapMaxTxs(-1))
+	assert.Equal(t, types.Txs{free}, mem.ReapMaxTxsBySort(-1))
+	assert.Equal(t, mempool.ErrTxInCache, mem.CheckTx(byHeight, nil, mempool.TxInfo{}))
+
+	time.Sleep(100 * time.Millisecond)
+	mem.PromoteDueTxs()
+	assert.Equal(t, types.Txs{free, byTime}, mem.ReapMaxTxs(-1))
+
+	update(t, mem, 1, nil)
+	assert.Equal(t, 2, mem.Size())
+	update(t, mem, 2, nil)
+	assert.Equal(t, types.Txs{free, byTime, byHeight}, mem.ReapMaxTxs(-1))
+	assert.Equal(t, types.Txs{byHeight, byTime, free}, mem.ReapMaxTxsBySort(-1))
+}
+
+func testUpdateAt(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+	past := time.Now().Add(-time.Hour)
+	updateAt := func(now time.Time, height int64) {
+		mem.Lock()
+		defer mem.Unlock()
+		require.NoError(t, mem.UpdateAt(now, height, nil, nil, nil, nil))
+	}
+
+	// expired by the local clock but not as of its admission
+	bundle := types.Txs{NewTx(t, "bundle-0", 10), NewTx(t, "bundle-1", 10)}
+	require.NoError(t, mem.CheckBundle(bundle, time.Minute, mempool.TxInfo{Now: past}))
+	// due by the local clock but locked as of its admission
+	locked := NewTx(t, "locked", 20)
+	require.NoError(t, mem.CheckTx(locked, nil, mempool.TxInfo{NotBefore: past.Add(time.Minute), Now: past}))
+	assert.Equal(t, 2, mem.Size())
+
+	updateAt(past, 1)
+	assert.Equal(t, 2, mem.Size())
+	updateAt(past.Add(2*time.Minute), 2)
+	assert.Equal(t, types.Txs{locked}, mem.ReapMaxTxs(-1))
+}
+
+func testTxByID(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	tx := checkTxs(t, mem, "tx", 10)[0]
+	locked := NewTx(t, "locked", 20)
+	require.NoError(t, mem.CheckTx(locked, nil, mempool.TxInfo{NotBeforeHeight: 10}))
+
+	found, ok := mem.TxByID("tx-0")
+	assert.True(t, ok)
+	assert.Equal(t, tx, found)
+	found, ok = mem.TxByID("locked")
+	assert.True(t, ok)
+	assert.Equal(t, locked, found)
+	_, ok = mem.TxByID("missing")
+	assert.False(t, ok)
+
+	update(t, mem, 1, types.Txs{tx})
+	_, ok = mem.TxByID("tx-0")
+	assert.False(t, ok)
+	mem.RemoveTxByKey(mempool.TxKey(locked), false)
+	_, ok = mem.TxByID("locked")
+	assert.False(t, ok)
+}
+
+func testFlush(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+
+	txs := checkTxs(t, mem, "tx", 10, 20)
+	require.NoError(t, mem.CheckTx(NewTx(t, "locked", 30), nil, mempool.TxInfo{NotBeforeHeight: 10}))
+	mem.Flush()
+	assert.Zero(t, mem.Size())
+	assert.Zero(t, mem.TxsBytes())
+	assert.Empty(t, mem.ReapMaxTxs(-1))
+	_, ok := mem.TxByID("locked")
+	assert.False(t, ok)
+
+	// the cache is flushed too
+	assert.NoError(t, mem.CheckTx(txs[0], nil, mempool.TxInfo{}))
+}
+
+func testTxsAvailable(t *testing.T, newMempool NewMempoolFunc) {
+	mem := newMempool(t, Config())
+	mem.EnableTxsAvailable()
+
+	ensureNoFire(t, mem.TxsAvailable())
+	txs := checkTxs(t, mem, "tx", 10, 20)
+	ensureFire(t, mem.TxsAvailable())
+	// fires once per height
+	checkTxs(t, mem, "more", 30)
+	ensureNoFire(t, mem.TxsAvailable())
+
+	update(t, mem, 1, txs)
+	ensureFire(t, mem.TxsAvailable())
+	update(t, mem, 2, mem.ReapMaxTxs(-1))
+	ensureNoFire(t, mem.TxsAvailable())
+}
+
+func ensureFire(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected to fire")
+	}
+}
+
+func ensureNoFire(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+		t.Fatal("Expected not to fire")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// testConcurrency adds txs from several goroutines while others reap them
+// and commit them.
+func testConcurrency(t *testing.T, newMempool NewMempoolFunc) {
+	config := Config()
+	config.Size = 10000
+	mem := newMempool(t, config)
+
+	const (
+		senders = 8
+		perSend = 100
+	)
+	var (
+		wg        sync.WaitGroup
+		done      = make(chan struct{})
+		committed = make(map[[mempool.TxKeySize]byte]bool)
+	)
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 0; i < perSend; i++ {
+				tx := NewTx(t, fmt.Sprintf("tx-%d-%d", s, i), int64(i))
+				assert.NoError(t, mem.CheckTx(tx, nil, mempool.TxInfo{SenderID: uint16(s)}))
+				// a dup from another sender
+				assert.Equal(t, mempool.ErrTxInCache, mem.CheckTx(tx, nil, mempool.TxInfo{SenderID: uint16(s + 1)}))
+			}
+		}(s)
+	}
+	commitDone := make(chan struct{})
+	go func() {
+		defer close(commitDone)
+		for height := int64(1); ; height++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			txs := mem.ReapMaxTxsBySort(10)
+			_ = mem.ReapMaxTxs(10)
+			_ = mem.TxFees()
+			mem.Lock()
+			assert.NoError(t, mem.Update(height, txs, nil, nil, nil))
+			mem.Unlock()
+			for _, tx := range txs {
+				key := mempool.TxKey(tx)
+				assert.False(t, committed[key], "tx reaped after it was committed")
+				committed[key] = true
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	<-commitDone
+
+	pending := mem.ReapMaxTxs(-1)
+	assert.Equal(t, senders*perSend, len(committed)+len(pending))
+	assert.Equal(t, len(pending), mem.Size())
+	assert.Equal(t, txsBytes(pending), mem.TxsBytes())
+	for _, tx := range pending {
+		assert.False(t, committed[mempool.TxKey(tx)], "committed tx still pending")
+	}
+	fees := mem.TxFees()
+	assert.True(t, sort.SliceIsSorted(fees, func(i, j int) bool { return fees[i] > fees[j] }))
+	assert.Equal(t, len(pending), len(fees))
+}
@@ -1,42 +1,614 @@
 package mock
 
 import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
 	abci "github.com/tendermint/tendermint/abci/types"
-	"github.com/tendermint/tendermint/libs/clist"
-	mempl "github.com/tendermint/tendermint/mempool"
+	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/types"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/protoutil"
 )
 
-// Mempool is an empty implementation of a Mempool, useful for testing.
-type Mempool struct{}
+// Mempool is an in-memory implementation of mempool.Mempool, useful for
+// testing. It honours the limits, cache, bundles and time locks of the
+// CListMempool but keeps its txs in plain slices, has no WAL and no minimum
+// fee, and doesn't recheck txs.
+type Mempool struct {
+	config *cfg.MempoolConfig
+
+	// updateMtx is held by Lock for the caller of Update.
+	updateMtx sync.RWMutex
+
+	mtx         sync.Mutex
+	height      int64
+	preCheck    mempool.PreCheckFunc
+	txs         []*memTx // reapable txs, in arrival order
+	locked      []*memTx // time-locked txs, in arrival order
+	txsBytes    int64
+	lockedBytes int64 // total size of the time-locked txs, in bytes
+	cache       *txCache
+
+	txsAvailable         chan struct{}
+	notifiedTxsAvailable bool
+}
+
+type memTx struct {
+	tx     types.Tx
+	key    [mempool.TxKeySize]byte
+	txID   string
+	fee    int64
+	bundle *bundle
+
+	notBefore       time.Time
+	notBeforeHeight int64
+}
+
+type bundle struct {
+	txs     []*memTx
+	fee     int64
+	expires time.Time
+}
+
+func (b *bundle) expired(now time.Time) bool {
+	return b != nil && !b.expires.IsZero() && now.After(b.expires)
+}
+
+var _ mempool.Mempool = (*Mempool)(nil)
+
+// NewMempool returns an empty mempool with the limits of config.
+func NewMempool(config *cfg.MempoolConfig) *Mempool {
+	return &Mempool{config: config, cache: newTxCache(config.CacheSize)}
+}
+
+func newMemTx(tx types.Tx) *memTx {
+	fee, txID, err := protoutil.GetTxFeeFromEnvelope(tx)
+	if err != nil {
+		fee = new(big.Int)
+	}
+	if txID == "" {
+		txID = fmt.Sprintf("%X", tx.Hash())
+	}
+	return &memTx{tx: tx, key: mempool.TxKey(tx), txID: txID, fee: fee.Int64()}
+}
+
+func (m *Mempool) Lock()   { m.updateMtx.Lock() }
+func (m *Mempool) Unlock() { m.updateMtx.Unlock() }
+
+func (m *Mempool) Size() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return len(m.txs)
+}
+
+func (m *Mempool) TxsBytes() int64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.txsBytes
+}
+
+func (m *Mempool) MinFee() *big.Int { return new(big.Int) }
+
+// checkFull checks there is room for numTxs more txs of size bytes in total.
+func (m *Mempool) checkFull(numTxs int, size int64) error {
+	memSize := len(m.txs) + len(m.locked)
+	txsBytes := m.txsBytes + m.lockedBytes
+	if memSize+numTxs > m.config.Size || txsBytes+size > m.config.MaxTxsBytes {
+		return mempool.ErrMempoolIsFull{
+			NumTxs: memSize, MaxTxs: m.config.Size,
+			TxsBytes: txsBytes, MaxTxsBytes: m.config.MaxTxsBytes,
+		}
+	}
+	return nil
+}
+
+// checkTx returns why tx can't be admitted, but for the cache.
+func (m *Mempool) checkTx(tx types.Tx) error {
+	if len(tx) > m.config.MaxTxBytes {
+		return mempool.ErrTxTooLarge{Max: m.config.MaxTxBytes, Actual: len(tx)}
+	}
+	if m.preCheck != nil {
+		if err := m.preCheck(tx); err != nil {
+			return mempool.ErrPreCheck{Reason: err}
+		}
+	}
+	return nil
+}
+
+func (m *Mempool) CheckTx(tx types.Tx, callback func(*abci.Response), txInfo mempool.TxInfo) error {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err := m.checkFull(1, int64(len(tx))); err != nil {
+		return err
+	}
+	if err := m.checkTx(tx); err != nil {
+		return err
+	}
+	if !m.cache.push(mempool.TxKey(tx)) {
+		return mempool.ErrTxInCache
+	}
+
+	memTx := newMemTx(tx)
+	memTx.notBefore = txInfo.NotBefore
+	memTx.notBeforeHeight = txInfo.NotBeforeHeight
+	if !memTx.due(txInfo.At(), m.height) {
+		m.locked = append(m.locked, memTx)
+		m.lockedBytes += int64(len(tx))
+	} else {
+		m.addTx(memTx)
+	}
+
+	if callback != nil {
+		callback(abci.ToResponseCheckTx(abci.ResponseCheckTx{GasWanted: memTx.fee}))
+	}
+	return nil
+}
+
+func (m *Mempool) CheckBundle(txs types.Txs, ttl time.Duration, txInfo mempool.TxInfo) error {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if len(txs) == 0 {
+		return errors.New("empty bundle")
+	}
+	if len(txs) > mempool.DefaultMaxBundleTxs {
+		return fmt.Errorf("bundle too large: %d txs, max %d", len(txs), mempool.DefaultMaxBundleTxs)
+	}
 
-var _ mempl.Mempool = Mempool{}
+	b := &bundle{}
+	if ttl > 0 {
+		b.expires = txInfo.At().Add(ttl)
+	}
+	var size int64
+	seen := make(map[[mempool.TxKeySize]byte]bool, len(txs))
+	for _, tx := range txs {
+		if err := m.checkTx(tx); err != nil {
+			return err
+		}
+		memTx := newMemTx(tx)
+		if seen[memTx.key] {
+			return errors.New("duplicate tx in bundle")
+		}
+		seen[memTx.key] = true
+		memTx.bundle = b
+		b.txs = append(b.txs, memTx)
+		b.fee += memTx.fee
+		size += int64(len(tx))
+	}
+	if err := m.checkFull(len(txs), size); err != nil {
+		return err
+	}
+	for i, memTx := range b.txs {
+		if !m.cache.push(memTx.key) {
+			for _, pushed := range b.txs[:i] {
+				m.cache.remove(pushed.key)
+			}
+			return mempool.ErrTxInCache
+		}
+	}
 
-func (Mempool) Lock()     {}
-func (Mempool) Unlock()   {}
-func (Mempool) Size() int { return 0 }
-func (Mempool) CheckTx(_ types.Tx, _ func(*abci.Response), _ mempl.TxInfo) error {
+	for _, memTx := range b.txs {
+		m.addTx(memTx)
+	}
 	return nil
 }
-func (Mempool) ReapMaxBytesMaxGas(_, _ int64) types.Txs { return types.Txs{} }
-func (Mempool) ReapMaxTxs(n int) types.Txs              { return types.Txs{} }
-func (Mempool) Update(
-	_ int64,
-	_ types.Txs,
+
+func (tx *memTx) due(now time.Time, height int64) bool {
+	return !now.Before(tx.notBefore) && height >= tx.notBeforeHeight
+}
+
+// addTx appends memTx to the reapable txs, mtx must be held.
+func (m *Mempool) addTx(memTx *memTx) {
+	m.txs = append(m.txs, memTx)
+	m.txsBytes += int64(len(memTx.tx))
+	m.notifyTxsAvailable()
+}
+
+func (m *Mempool) notifyTxsAvailable() {
+	if m.txsAvailable != nil && !m.notifiedTxsAvailable {
+		m.notifiedTxsAvailable = true
+		select {
+		case m.txsAvailable <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// units groups the reapable txs in arrival order, a bundle is one unit at
+// the place of its first tx. Expired bundles are left out.
+func (m *Mempool) units() [][]*memTx {
+	var (
+		now     = time.Now()
+		units   = make([][]*memTx, 0, len(m.txs))
+		bundles = make(map[*bundle]bool)
+	)
+	for _, tx := range m.txs {
+		if tx.bundle == nil {
+			units = append(units, []*memTx{tx})
+			continue
+		}
+		if bundles[tx.bundle] || tx.bundle.expired(now) {
+			continue
+		}
+		bundles[tx.bundle] = true
+		units = append(units, tx.bundle.txs)
+	}
+	return units
+}
+
+// skipUnits leaves out the units with a tx to skip.
+func skipUnits(units [][]*memTx, skip mempool.SkipFunc) [][]*memTx {
+	if skip == nil {
+		return units
+	}
+	kept := make([][]*memTx, 0, len(units))
+next:
+	for _, unit := range units {
+		for _, tx := range unit {
+			if skip(tx.key) {
+				continue next
+			}
+		}
+		kept = append(kept, unit)
+	}
+	return kept
+}
+
+func unitFee(unit []*memTx) int64 {
+	if unit[0].bundle != nil {
+		return unit[0].bundle.fee
+	}
+	return unit[0].fee
+}
+
+// reap returns the txs of units up to max txs, the units which don't fit
+// are skipped.
+func reap(units [][]*memTx, max int) types.Txs {
+	txs := types.Txs{}
+	for _, unit := range fit(units, max) {
+		for _, memTx := range unit {
+			txs = append(txs, memTx.tx)
+		}
+	}
+	return txs
+}
+
+// fit returns the units reaped up to max txs.
+func fit(units [][]*memTx, max int) [][]*memTx {
+	fitting := make([][]*memTx, 0, len(units))
+	size := 0
+	for _, unit := range units {
+		if max >= 0 && size+len(unit) > max {
+			continue
+		}
+		fitting = append(fitting, unit)
+		size += len(unit)
+	}
+	return fitting
+}
+
+func (m *Mempool) ReapMaxBytesMaxGas(maxBytes, maxGas int64) types.Txs {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.promoteDueTxs(time.Now())
+
+	var (
+		txs      = types.Txs{}
+		totalGas int64
+	)
+	for _, unit := range m.units() {
+		newTxs := txs
+		newGas := totalGas
+		for _, memTx := range unit {
+			newTxs = append(newTxs, memTx.tx)
+			newGas += memTx.fee
+		}
+		if maxBytes > -1 && types.ComputeProtoSizeForTxs(newTxs) > maxBytes {
+			break
+		}
+		if maxGas > -1 && newGas > maxGas {
+			break
+		}
+		txs, totalGas = newTxs, newGas
+	}
+	return txs
+}
+
+func (m *Mempool) ReapMaxTxs(max int) types.Txs {
+	return m.ReapMaxTxsSkipping(max, nil)
+}
+
+func (m *Mempool) ReapMaxTxsSkipping(max int, skip mempool.SkipFunc) types.Txs {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.promoteDueTxs(time.Now())
+
+	return reap(skipUnits(m.units(), skip), max)
+}
+
+func (m *Mempool) ReapMaxTxsBySort(max int) types.Txs {
+	return m.ReapMaxTxsBySortSkipping(max, nil)
+}
+
+func (m *Mempool) ReapMaxTxsBySortSkipping(max int, skip mempool.SkipFunc) types.Txs {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.promoteDueTxs(time.Now())
+
+	units := skipUnits(m.units(), skip)
+	sort.SliceStable(units, func(i, j int) bool { return unitFee(units[i]) > unitFee(units[j]) })
+	return reap(units, max)
+}
+
+func (m *Mempool) UnitFees(max int) []mempool.UnitFee {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.promoteDueTxs(time.Now())
+
+	units := m.units()
+	sort.SliceStable(units, func(i, j int) bool { return unitFee(units[i]) > unitFee(units[j]) })
+	fees := []mempool.UnitFee{}
+	for _, unit := range fit(units, max) {
+		fees = append(fees, mempool.UnitFee{Fee: unitFee(unit), Txs: len(unit)})
+	}
+	return fees
+}
+
+func (m *Mempool) TxFees() []int64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	fees := make([]int64, len(m.txs))
+	for i, memTx := range m.txs {
+		fees[i] = memTx.fee
+	}
+	sort.Slice(fees, func(i, j int) bool { return fees[i] > fees[j] })
+	return fees
+}
+
+// Update removes the committed txs, with the rest of their bundles, and the
+// expired bundles. Lock must be held by the caller.
+func (m *Mempool) Update(
+	height int64,
+	txs types.Txs,
+	deliverTxResponses []*abci.ResponseDeliverTx,
+	preCheck mempool.PreCheckFunc,
+	postCheck mempool.PostCheckFunc,
+) error {
+	return m.UpdateAt(time.Now(), height, txs, deliverTxResponses, preCheck, postCheck)
+}
+
+// UpdateAt updates like Update as of now. Lock must be held by the caller.
+func (m *Mempool) UpdateAt(
+	now time.Time,
+	height int64,
+	txs types.Txs,
 	_ []*abci.ResponseDeliverTx,
-	_ mempl.PreCheckFunc,
-	_ mempl.PostCheckFunc,
+	preCheck mempool.PreCheckFunc,
+	_ mempool.PostCheckFunc,
 ) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.height = height
+	m.notifiedTxsAvailable = false
+	if preCheck != nil {
+		m.preCheck = preCheck
+	}
+
+	committed := make(map[[mempool.TxKeySize]byte]bool, len(txs))
+	for _, tx := range txs {
+		committed[mempool.TxKey(tx)] = true
+	}
+	m.removeTxs(func(memTx *memTx) bool {
+		return committed[memTx.key] || memTx.bundle.expired(now)
+	}, func(memTx *memTx) bool {
+		return memTx.bundle.expired(now)
+	})
+
+	m.promoteDueTxs(now)
+	if len(m.txs) > 0 {
+		m.notifyTxsAvailable()
+	}
+	return nil
+}
+
+// removeTxs removes the reapable txs matching remove, with the rest of their
+// bundles. Those matching uncache are removed from the cache too.
+func (m *Mempool) removeTxs(remove, uncache func(*memTx) bool) {
+	bundles := make(map[*bundle]bool)
+	for _, memTx := range m.txs {
+		if memTx.bundle != nil && remove(memTx) {
+			bundles[memTx.bundle] = true
+		}
+	}
+	kept := m.txs[:0]
+	for _, memTx := range m.txs {
+		if remove(memTx) || (memTx.bundle != nil && bundles[memTx.bundle]) {
+			m.txsBytes -= int64(len(memTx.tx))
+			if uncache(memTx) {
+				m.cache.remove(memTx.key)
+			}
+			continue
+		}
+		kept = append(kept, memTx)
+	}
+	for i := len(kept); i < len(m.txs); i++ {
+		m.txs[i] = nil
+	}
+	m.txs = kept
+}
+
+func (m *Mempool) PromoteDueTxs() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.promoteDueTxs(time.Now())
+}
+
+// promoteDueTxs moves the time-locked txs due by now to the reapable txs,
+// ordered by their time lock and then by arrival. mtx must be held.
+func (m *Mempool) promoteDueTxs(now time.Time) {
+	var (
+		due    []*memTx
+		locked = m.locked[:0]
+	)
+	for _, memTx := range m.locked {
+		if memTx.due(now, m.height) {
+			due = append(due, memTx)
+		} else {
+			locked = append(locked, memTx)
+		}
+	}
+	for i := len(locked); i < len(m.locked); i++ {
+		m.locked[i] = nil
+	}
+	m.locked = locked
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].notBefore.Before(due[j].notBefore) })
+	for _, memTx := range due {
+		m.lockedBytes -= int64(len(memTx.tx))
+		m.addTx(memTx)
+	}
+}
+
+func (m *Mempool) TxByID(txID string) (types.Tx, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, txs := range [][]*memTx{m.txs, m.locked} {
+		for _, memTx := range txs {
+			if memTx.txID == txID {
+				return memTx.tx, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (m *Mempool) BundleTxs(txID string) types.Txs {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, txs := range [][]*memTx{m.txs, m.locked} {
+		for _, memTx := range txs {
+			if memTx.txID != txID {
+				continue
+			}
+			if memTx.bundle == nil {
+				return types.Txs{memTx.tx}
+			}
+			bundled := make(types.Txs, len(memTx.bundle.txs))
+			for i, tx := range memTx.bundle.txs {
+				bundled[i] = tx.tx
+			}
+			return bundled
+		}
+	}
 	return nil
 }
-func (Mempool) Flush()                        {}
-func (Mempool) FlushAppConn() error           { return nil }
-func (Mempool) TxsAvailable() <-chan struct{} { return make(chan struct{}) }
-func (Mempool) EnableTxsAvailable()           {}
-func (Mempool) TxsBytes() int64               { return 0 }
 
-func (Mempool) TxsFront() *clist.CElement    { return nil }
-func (Mempool) TxsWaitChan() <-chan struct{} { return nil }
+func (m *Mempool) RemoveTxByKey(txKey [mempool.TxKeySize]byte, removeFromCache bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i, memTx := range m.locked {
+		if memTx.key == txKey {
+			m.locked = append(m.locked[:i], m.locked[i+1:]...)
+			m.lockedBytes -= int64(len(memTx.tx))
+			if removeFromCache {
+				m.cache.remove(txKey)
+			}
+			return
+		}
+	}
+	m.removeTxs(func(memTx *memTx) bool {
+		return memTx.key == txKey
+	}, func(*memTx) bool {
+		return removeFromCache
+	})
+}
+
+func (m *Mempool) Flush() {
+	m.updateMtx.RLock()
+	defer m.updateMtx.RUnlock()
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.txs = nil
+	m.locked = nil
+	m.txsBytes = 0
+	m.lockedBytes = 0
+	m.cache.reset()
+}
+
+func (m *Mempool) FlushAppConn() error { return nil }
+
+func (m *Mempool) TxsAvailable() <-chan struct{} { return m.txsAvailable }
+
+func (m *Mempool) EnableTxsAvailable() {
+	m.txsAvailable = make(chan struct{}, 1)
+}
+
+func (m *Mempool) InitWAL() error { return nil }
+func (m *Mempool) CloseWAL()      {}
+
+// txCache is a LRU cache of the keys of the txs seen, a size of zero caches
+// nothing.
+type txCache struct {
+	size int
+	keys map[[mempool.TxKeySize]byte]*list.Element
+	list *list.List
+}
+
+func newTxCache(size int) *txCache {
+	c := &txCache{size: size}
+	c.reset()
+	return c
+}
+
+func (c *txCache) reset() {
+	c.keys = make(map[[mempool.TxKeySize]byte]*list.Element)
+	c.list = list.New()
+}
 
-func (Mempool) InitWAL() error { return nil }
-func (Mempool) CloseWAL()      {}
+// push adds key to the cache, it returns false if key was already there.
+func (c *txCache) push(key [mempool.TxKeySize]byte) bool {
+	if c.size <= 0 {
+		return true
+	}
+	if e, ok := c.keys[key]; ok {
+		c.list.MoveToBack(e)
+		return false
+	}
+	if c.list.Len() >= c.size {
+		front := c.list.Front()
+		delete(c.keys, front.Value.([mempool.TxKeySize]byte))
+		c.list.Remove(front)
+	}
+	c.keys[key] = c.list.PushBack(key)
+	return true
+}
+
+func (c *txCache) remove(key [mempool.TxKeySize]byte) {
+	if e, ok := c.keys[key]; ok {
+		delete(c.keys, key)
+		c.list.Remove(e)
+	}
+}
@@ -0,0 +1,15 @@
+package mock
+
+import (
+	"testing"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tylerztl/fabric-mempool/mempool"
+	"github.com/tylerztl/fabric-mempool/mempool/mempooltest"
+)
+
+func TestMempoolConformance(t *testing.T) {
+	mempooltest.Run(t, func(t *testing.T, config *cfg.MempoolConfig) mempool.Mempool {
+		return NewMempool(config)
+	})
+}
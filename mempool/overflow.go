@@ -0,0 +1,360 @@
+package mempool
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/tendermint/tendermint/libs/clist"
+	tmsync "github.com/tendermint/tendermint/libs/sync"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// overflow is the on-disk tier of the mempool. It keeps the txs spilled
+// from memory when the mempool is full, ordered by fee, until there is room
+// for them again.
+//
+// The txs are stored under their fee, highest first, followed by their
+// spilling order. Only their keys are kept in memory.
+type overflow struct {
+	mtx      tmsync.Mutex
+	db       dbm.DB
+	maxTxs   int
+	maxBytes int64
+
+	seq   uint64
+	bytes int64
+	keys  map[[TxKeySize]byte][]byte // txKey -> db key
+}
+
+// WithOverflow spills the txs with the lowest fees to db when the mempool
+// is full, up to maxTxs txs and maxBytes bytes. They are moved back by fee
+// as memory frees up. The txs left in db by a previous run are dropped.
+func WithOverflow(db dbm.DB, maxTxs int, maxBytes int64) CListMempoolOption {
+	return func(mem *CListMempool) {
+		mem.overflow = &overflow{
+			db:       db,
+			maxTxs:   maxTxs,
+			maxBytes: maxBytes,
+			keys:     make(map[[TxKeySize]byte][]byte),
+		}
+		if err := mem.overflow.reset(); err != nil {
+			panic(fmt.Sprintf("could not reset the mempool overflow: %v", err))
+		}
+		mem.fees = newFeeIndex()
+	}
+}
+
+func overflowKey(fee int64, seq uint64) []byte {
+	key := make([]byte, 16)
+	// flipping the sign bit orders fees as unsigned, inverting sorts them
+	// from the highest
+	binary.BigEndian.PutUint64(key, ^(uint64(fee) ^ 1<<63))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func (o *overflow) size() int {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return len(o.keys)
+}
+
+// hasRoom checks there is room for a tx of txSize bytes.
+func (o *overflow) hasRoom(txSize int) bool {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	return len(o.keys) < o.maxTxs && o.bytes+int64(txSize) <= o.maxBytes
+}
+
+func (o *overflow) has(txKey [TxKeySize]byte) bool {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	_, ok := o.keys[txKey]
+	return ok
+}
+
+func (o *overflow) push(tx types.Tx, fee int64) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	txKey := TxKey(tx)
+	if _, ok := o.keys[txKey]; ok {
+		return nil
+	}
+	if len(o.keys) >= o.maxTxs || o.bytes+int64(len(tx)) > o.maxBytes {
+		return ErrMempoolIsFull{len(o.keys), o.maxTxs, o.bytes, o.maxBytes}
+	}
+	o.seq++
+	key := overflowKey(fee, o.seq)
+	if err := o.db.Set(key, tx); err != nil {
+		return err
+	}
+	o.keys[txKey] = key
+	o.bytes += int64(len(tx))
+	return nil
+}
+
+func (o *overflow) get(txKey [TxKeySize]byte) (types.Tx, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key, ok := o.keys[txKey]
+	if !ok {
+		return nil, false
+	}
+	tx, err := o.db.Get(key)
+	if err != nil || tx == nil {
+		return nil, false
+	}
+	return tx, true
+}
+
+// remove removes the tx of txKey, it returns false if it isn't spilled.
+func (o *overflow) remove(txKey [TxKeySize]byte) (types.Tx, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key, ok := o.keys[txKey]
+	if !ok {
+		return nil, false
+	}
+	tx, err := o.db.Get(key)
+	if err == nil {
+		err = o.db.Delete(key)
+	}
+	if err != nil {
+		return nil, false
+	}
+	delete(o.keys, txKey)
+	o.bytes -= int64(len(tx))
+	return tx, true
+}
+
+// peek returns the spilled tx with the highest fee.
+func (o *overflow) peek() (types.Tx, bool) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if len(o.keys) == 0 {
+		return nil, false
+	}
+	it, err := o.db.Iterator(nil, nil)
+	if err != nil {
+		return nil, false
+	}
+	defer it.Close()
+	if !it.Valid() {
+		return nil, false
+	}
+	return it.Value(), true
+}
+
+func (o *overflow) reset() error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	it, err := o.db.Iterator(nil, nil)
+	if err != nil {
+		return err
+	}
+	var keys [][]byte
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, it.Key())
+	}
+	it.Close()
+	for _, key := range keys {
+		if err := o.db.Delete(key); err != nil {
+			return err
+		}
+	}
+	o.keys = make(map[[TxKeySize]byte][]byte)
+	o.bytes = 0
+	return nil
+}
+
+//--------------------------------------------------------------------------------
+
+// SpilledSize returns the number of txs spilled to the overflow tier, they
+// aren't counted by Size.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) SpilledSize() int {
+	if mem.overflow == nil {
+		return 0
+	}
+	return mem.overflow.size()
+}
+
+// spill makes room for memTx in memory by moving the txs with lower fees to
+// the overflow, or spills memTx itself. It returns false if memTx must be
+// kept in memory, as there is room for it now.
+func (mem *CListMempool) spill(memTx *mempoolTx) (bool, error) {
+	mem.spillMtx.Lock()
+	defer mem.spillMtx.Unlock()
+
+	for mem.isFull(len(memTx.tx)) != nil {
+		e := mem.lowestFeeTx()
+		if e == nil || e.Value.(*mempoolTx).gasWanted >= memTx.gasWanted {
+			return true, mem.overflow.push(memTx.tx, memTx.gasWanted)
+		}
+		lowest := e.Value.(*mempoolTx)
+		if err := mem.overflow.push(lowest.tx, lowest.gasWanted); err != nil {
+			return true, err
+		}
+		mem.removeTx(lowest.tx, e, false)
+		// still pending, findable by its txId
+		mem.txIDs.Store(lowest.txID, TxKey(lowest.tx))
+		mem.logger.Debug("Spilled transaction to the mempool overflow", "txId", lowest.txID, "fee", lowest.gasWanted)
+	}
+	return false, nil
+}
+
+// lowestFeeTx returns the single tx with the lowest fee, the latest one
+// among equal fees. Bundles are never spilled.
+func (mem *CListMempool) lowestFeeTx() *clist.CElement {
+	return mem.fees.lowest()
+}
+
+// feeIndex is a heap of the single txs in memory, the lowest fee on top and
+// the latest tx first among equal fees.
+type feeIndex struct {
+	mtx   tmsync.Mutex
+	seq   uint64
+	items feeItems
+	elems map[*clist.CElement]*feeItem
+}
+
+type feeItem struct {
+	e     *clist.CElement
+	fee   int64
+	seq   uint64
+	index int
+}
+
+func newFeeIndex() *feeIndex {
+	return &feeIndex{elems: make(map[*clist.CElement]*feeItem)}
+}
+
+func (f *feeIndex) add(e *clist.CElement) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.seq++
+	item := &feeItem{e: e, fee: e.Value.(*mempoolTx).gasWanted, seq: f.seq}
+	f.elems[e] = item
+	heap.Push(&f.items, item)
+}
+
+func (f *feeIndex) remove(e *clist.CElement) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if item, ok := f.elems[e]; ok {
+		delete(f.elems, e)
+		heap.Remove(&f.items, item.index)
+	}
+}
+
+func (f *feeIndex) lowest() *clist.CElement {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if len(f.items) == 0 {
+		return nil
+	}
+	return f.items[0].e
+}
+
+func (f *feeIndex) reset() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.items = nil
+	f.elems = make(map[*clist.CElement]*feeItem)
+}
+
+// feeItems implements heap.Interface.
+type feeItems []*feeItem
+
+func (items feeItems) Len() int { return len(items) }
+
+func (items feeItems) Less(i, j int) bool {
+	if items[i].fee != items[j].fee {
+		return items[i].fee < items[j].fee
+	}
+	return items[i].seq > items[j].seq
+}
+
+func (items feeItems) Swap(i, j int) {
+	items[i], items[j] = items[j], items[i]
+	items[i].index = i
+	items[j].index = j
+}
+
+func (items *feeItems) Push(x interface{}) {
+	item := x.(*feeItem)
+	item.index = len(*items)
+	*items = append(*items, item)
+}
+
+func (items *feeItems) Pop() interface{} {
+	old := *items
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*items = old[:len(old)-1]
+	return item
+}
+
+// removeSpilledTx removes a tx from the overflow, it returns false if the tx
+// isn't spilled.
+func (mem *CListMempool) removeSpilledTx(txKey [TxKeySize]byte, removeFromCache bool) bool {
+	if mem.overflow == nil {
+		return false
+	}
+	tx, ok := mem.overflow.remove(txKey)
+	if !ok {
+		return false
+	}
+	_, txId, _ := txFee(tx)
+	mem.forgetTxID(&mempoolTx{tx: tx, txID: txId})
+	if removeFromCache {
+		mem.cache.Remove(tx)
+	}
+	return true
+}
+
+// refill moves the spilled txs with the highest fees back to memory while
+// there is room for them.
+func (mem *CListMempool) refill() {
+	if mem.overflow == nil {
+		return
+	}
+	mem.spillMtx.Lock()
+	defer mem.spillMtx.Unlock()
+
+	refilled := 0
+	for {
+		tx, ok := mem.overflow.peek()
+		if !ok || mem.isFull(len(tx)) != nil {
+			break
+		}
+		if _, ok := mem.overflow.remove(TxKey(tx)); !ok {
+			break
+		}
+		fee, txId, _ := txFee(tx)
+		mem.addTx(&mempoolTx{
+			height:    mem.height,
+			gasWanted: fee.Int64(),
+			tx:        tx,
+			txID:      txId,
+		})
+		refilled++
+	}
+	if refilled > 0 {
+		mem.logger.Info("Refilled transactions from the mempool overflow", "txs", refilled,
+			"poolSize", mem.Size(), "spilled", mem.overflow.size())
+		mem.notifyTxsAvailable()
+	}
+}
@@ -0,0 +1,107 @@
+package mempool
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/clist"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func newOverflowMempool(t *testing.T, maxSpilled int) (*CListMempool, cleanupFunc) {
+	config := cfg.ResetTestRoot("mempool_test")
+	config.Mempool.Size = 2
+	mempool := NewCListMempool(config.Mempool, 0, WithOverflow(dbm.NewMemDB(), maxSpilled, 1<<20))
+	mempool.SetLogger(log.TestingLogger())
+	return mempool, func() { os.RemoveAll(config.RootDir) }
+}
+
+func TestOverflowKeepsHighestFeesInMemory(t *testing.T) {
+	mempool, cleanup := newOverflowMempool(t, 3)
+	defer cleanup()
+
+	low, high, mid, lowest := newFeeTx(t, "low", 10), newFeeTx(t, "high", 30), newFeeTx(t, "mid", 20), newFeeTx(t, "lowest", 5)
+	for _, tx := range []types.Tx{low, high, mid, lowest} {
+		require.NoError(t, mempool.CheckTx(tx, nil, TxInfo{}))
+	}
+
+	// mid took the place of low, lowest was spilled right away
+	assert.Equal(t, 2, mempool.Size())
+	assert.Equal(t, 2, mempool.SpilledSize())
+	assert.Equal(t, types.Txs{high, mid}, mempool.ReapMaxTxsBySort(-1))
+
+	// spilled txs are still pending and deduped
+	found, ok := mempool.TxByID("low")
+	assert.True(t, ok)
+	assert.Equal(t, low, found)
+	mempool.cache.Remove(low)
+	assert.Equal(t, ErrTxInCache, mempool.CheckTx(low, nil, TxInfo{}))
+
+	// the overflow is full too
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "lower", 2), nil, TxInfo{}))
+	assert.IsType(t, ErrMempoolIsFull{}, mempool.CheckTx(newFeeTx(t, "rejected", 1), nil, TxInfo{}))
+
+	// the highest spilled fee comes back first
+	mempool.Lock()
+	require.NoError(t, mempool.Update(1, types.Txs{high}, nil, nil, nil))
+	mempool.Unlock()
+	assert.Equal(t, types.Txs{mid, low}, mempool.ReapMaxTxsBySort(-1))
+	assert.Equal(t, 2, mempool.SpilledSize())
+
+	// spilled txs are removed like the others
+	mempool.RemoveTxByKey(TxKey(lowest), true)
+	assert.Equal(t, 1, mempool.SpilledSize())
+	_, ok = mempool.TxByID("lowest")
+	assert.False(t, ok)
+	mempool.RemoveTxByKey(TxKey(mid), true)
+	assert.Equal(t, types.Txs{low, newFeeTx(t, "lower", 2)}, mempool.ReapMaxTxs(-1))
+}
+
+func TestOverflowKeepsBundlesAndTimeLocksInMemory(t *testing.T) {
+	mempool, cleanup := newOverflowMempool(t, 10)
+	defer cleanup()
+
+	bundle := types.Txs{newFeeTx(t, "transfer", 1), newFeeTx(t, "settle", 1)}
+	require.NoError(t, mempool.CheckBundle(bundle, 0, TxInfo{}))
+
+	// nothing can be spilled to make room for a higher fee
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "high", 30), nil, TxInfo{}))
+	assert.Equal(t, 1, mempool.SpilledSize())
+	assert.Equal(t, bundle, mempool.ReapMaxTxs(-1))
+
+	err := mempool.CheckTx(newFeeTx(t, "locked", 30), nil, TxInfo{NotBefore: time.Now().Add(time.Hour)})
+	assert.IsType(t, ErrMempoolIsFull{}, err)
+
+	mempool.Flush()
+	assert.Equal(t, 0, mempool.SpilledSize())
+}
+
+func TestFeeIndexLowest(t *testing.T) {
+	list := clist.New()
+	fees := newFeeIndex()
+	assert.Nil(t, fees.lowest())
+
+	var elems []*clist.CElement
+	for _, fee := range []int64{20, 10, 30, 10} {
+		e := list.PushBack(&mempoolTx{gasWanted: fee})
+		fees.add(e)
+		elems = append(elems, e)
+	}
+	// the latest among equal fees
+	assert.Equal(t, elems[3], fees.lowest())
+	fees.remove(elems[3])
+	assert.Equal(t, elems[1], fees.lowest())
+	fees.remove(elems[1])
+	fees.remove(elems[1])
+	assert.Equal(t, elems[0], fees.lowest())
+
+	fees.reset()
+	assert.Nil(t, fees.lowest())
+}
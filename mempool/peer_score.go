@@ -0,0 +1,216 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	tmsync "github.com/tendermint/tendermint/libs/sync"
+	"github.com/tendermint/tendermint/p2p"
+)
+
+// PeerScoreConfig sets how much each kind of misbehaviour adds to the score
+// of a peer, and the scores at which the peer is punished.
+type PeerScoreConfig struct {
+	// DuplicateWeight is added for a tx already in the cache.
+	DuplicateWeight float64
+	// InvalidWeight is added for a tx rejected as invalid.
+	InvalidWeight float64
+	// OversizeWeight is added for a tx larger than MaxTxBytes.
+	OversizeWeight float64
+	// ThrottleScore is the score from which the txs of the peer are neither
+	// requested nor checked, zero disables throttling. The txs a throttled
+	// peer sends anyway are dropped and scored as duplicates.
+	ThrottleScore float64
+	// DisconnectScore is the score from which the peer is disconnected, zero
+	// disables disconnecting.
+	DisconnectScore float64
+	// HalfLife is the time it takes a score to decay by half, zero means it
+	// never decays.
+	HalfLife time.Duration
+}
+
+// DefaultPeerScoreConfig returns the weights and thresholds used unless
+// WithPeerScoring sets others.
+func DefaultPeerScoreConfig() PeerScoreConfig {
+	return PeerScoreConfig{
+		DuplicateWeight: 1,
+		InvalidWeight:   10,
+		OversizeWeight:  20,
+		ThrottleScore:   100,
+		DisconnectScore: 1000,
+		HalfLife:        time.Minute,
+	}
+}
+
+// ReactorOption sets an optional parameter on the Reactor.
+type ReactorOption func(*Reactor)
+
+// WithPeerScoring sets the weights and thresholds of the peer scores.
+func WithPeerScoring(config PeerScoreConfig) ReactorOption {
+	return func(memR *Reactor) { memR.scores.config = config }
+}
+
+// PeerScore is the misbehaviour recorded for a peer. The counters are
+// kept for the life of the reactor, the score decays over time.
+type PeerScore struct {
+	ID         p2p.ID  `json:"id"`
+	Score      float64 `json:"score"`
+	Duplicates int64   `json:"duplicates"`
+	Invalid    int64   `json:"invalid"`
+	Oversize   int64   `json:"oversize"`
+	Dropped    int64   `json:"dropped"`
+	Throttled  bool    `json:"throttled"`
+}
+
+// peerPenalty is the outcome of scoring a peer.
+type peerPenalty int
+
+const (
+	penaltyNone peerPenalty = iota
+	penaltyThrottle
+	penaltyDisconnect
+)
+
+type peerScore struct {
+	PeerScore
+	updated time.Time
+}
+
+// decay lowers the score by the time passed since it was last updated.
+func (ps *peerScore) decay(now time.Time, halfLife time.Duration) {
+	if halfLife > 0 && !ps.updated.IsZero() {
+		ps.Score *= math.Pow(0.5, float64(now.Sub(ps.updated))/float64(halfLife))
+	}
+	ps.updated = now
+}
+
+// peerScores keeps the scores of the peers by ID, so a peer reconnecting
+// keeps its score.
+type peerScores struct {
+	mtx    tmsync.Mutex
+	config PeerScoreConfig
+	peers  map[p2p.ID]*peerScore
+}
+
+func newPeerScores() *peerScores {
+	return &peerScores{
+		config: DefaultPeerScoreConfig(),
+		peers:  make(map[p2p.ID]*peerScore),
+	}
+}
+
+// record scores the CheckTx error err of a tx received from id, errors
+// which aren't the peer's fault are ignored.
+func (s *peerScores) record(id p2p.ID, err error, now time.Time) (PeerScore, peerPenalty) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ps := s.get(id, now)
+	var (
+		tooLarge ErrTxTooLarge
+		preCheck ErrPreCheck
+		tooFar   ErrTimeLockTooFar
+		badFee   ErrTxFee
+		lowFee   ErrFeeTooLow
+	)
+	switch {
+	case errors.Is(err, ErrTxInCache):
+		ps.Duplicates++
+		ps.Score += s.config.DuplicateWeight
+	case errors.As(err, &tooLarge):
+		ps.Oversize++
+		ps.Score += s.config.OversizeWeight
+	case errors.As(err, &preCheck), errors.As(err, &tooFar), errors.As(err, &badFee), errors.As(err, &lowFee):
+		ps.Invalid++
+		ps.Score += s.config.InvalidWeight
+	}
+	penalty := s.penalty(ps)
+	return ps.PeerScore, penalty
+}
+
+// drop scores n txs of id dropped while it is throttled.
+func (s *peerScores) drop(id p2p.ID, n int, now time.Time) (PeerScore, peerPenalty) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ps := s.get(id, now)
+	ps.Dropped += int64(n)
+	ps.Score += float64(n) * s.config.DuplicateWeight
+	penalty := s.penalty(ps)
+	return ps.PeerScore, penalty
+}
+
+// check returns the penalty of id without scoring anything.
+func (s *peerScores) check(id p2p.ID, now time.Time) peerPenalty {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.penalty(s.get(id, now))
+}
+
+// get returns the decayed score of id. The mutex must be held.
+func (s *peerScores) get(id p2p.ID, now time.Time) *peerScore {
+	ps, ok := s.peers[id]
+	if !ok {
+		ps = &peerScore{PeerScore: PeerScore{ID: id}}
+		s.peers[id] = ps
+	}
+	ps.decay(now, s.config.HalfLife)
+	return ps
+}
+
+// penalty updates whether ps is throttled. The mutex must be held.
+func (s *peerScores) penalty(ps *peerScore) peerPenalty {
+	ps.Throttled = s.config.ThrottleScore > 0 && ps.Score >= s.config.ThrottleScore
+	switch {
+	case s.config.DisconnectScore > 0 && ps.Score >= s.config.DisconnectScore:
+		return penaltyDisconnect
+	case ps.Throttled:
+		return penaltyThrottle
+	}
+	return penaltyNone
+}
+
+// list returns the decayed scores of all peers, highest first.
+func (s *peerScores) list(now time.Time) []PeerScore {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	scores := make([]PeerScore, 0, len(s.peers))
+	for id := range s.peers {
+		ps := s.get(id, now)
+		s.penalty(ps)
+		scores = append(scores, ps.PeerScore)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	return scores
+}
+
+// PeerScores returns the scores of all peers seen, highest first.
+//
+// Safe for concurrent use by multiple goroutines.
+func (memR *Reactor) PeerScores() []PeerScore {
+	return memR.scores.list(time.Now())
+}
+
+// punish disconnects src if penalty asks for it and returns true if it did.
+func (memR *Reactor) punish(src p2p.Peer, score PeerScore, penalty peerPenalty) bool {
+	switch penalty {
+	case penaltyDisconnect:
+		memR.Logger.Info("Disconnecting misbehaving peer", "src", src, "score", score.Score)
+		memR.Switch.StopPeerForError(src, errPeerScore(score))
+		return true
+	case penaltyThrottle:
+		memR.Logger.Debug("Throttling misbehaving peer", "src", src, "score", score.Score)
+	}
+	return false
+}
+
+// errPeerScore is the reason a peer is disconnected for its score.
+func errPeerScore(ps PeerScore) error {
+	return fmt.Errorf("peer score %.1f too high (duplicates %d, invalid %d, oversize %d, dropped %d)",
+		ps.Score, ps.Duplicates, ps.Invalid, ps.Oversize, ps.Dropped)
+}
@@ -0,0 +1,67 @@
+package mempool
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tendermint/tendermint/p2p"
+)
+
+func TestPeerScores(t *testing.T) {
+	s := newPeerScores()
+	s.config = PeerScoreConfig{
+		DuplicateWeight: 1,
+		InvalidWeight:   10,
+		OversizeWeight:  20,
+		ThrottleScore:   30,
+		DisconnectScore: 60,
+		HalfLife:        time.Minute,
+	}
+	const id = p2p.ID("peer")
+	now := time.Now()
+
+	// not the peer's fault
+	_, penalty := s.record(id, ErrMempoolIsFull{}, now)
+	assert.Equal(t, penaltyNone, penalty)
+
+	_, penalty = s.record(id, ErrTxInCache, now)
+	assert.Equal(t, penaltyNone, penalty)
+	_, penalty = s.record(id, ErrPreCheck{Reason: ErrTxInCache}, now)
+	assert.Equal(t, penaltyNone, penalty)
+	score, penalty := s.record(id, ErrTxTooLarge{}, now)
+	assert.Equal(t, penaltyThrottle, penalty)
+	assert.Equal(t, PeerScore{ID: id, Score: 31, Duplicates: 1, Invalid: 1, Oversize: 1, Throttled: true}, score)
+
+	// the score halves every half-life
+	assert.Equal(t, penaltyNone, s.check(id, now.Add(time.Minute)))
+	assert.Equal(t, 15.5, s.list(now.Add(time.Minute))[0].Score)
+
+	score, penalty = s.drop(id, 45, now.Add(time.Minute))
+	assert.Equal(t, penaltyDisconnect, penalty)
+	assert.EqualValues(t, 45, score.Dropped)
+}
+
+func TestPeerScoresInvalidFees(t *testing.T) {
+	s := newPeerScores()
+	s.config = PeerScoreConfig{InvalidWeight: 10, DisconnectScore: 20, HalfLife: time.Minute}
+	now := time.Now()
+
+	_, penalty := s.record("peer", ErrTxFee{Reason: errors.New("bad envelope")}, now)
+	assert.Equal(t, penaltyNone, penalty)
+	score, penalty := s.record("peer", ErrFeeTooLow{}, now)
+	assert.Equal(t, penaltyDisconnect, penalty)
+	assert.EqualValues(t, 2, score.Invalid)
+}
+
+func TestPeerScoresDisabled(t *testing.T) {
+	s := newPeerScores()
+	s.config = PeerScoreConfig{DuplicateWeight: 1}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		_, penalty := s.record("peer", ErrTxInCache, now)
+		assert.Equal(t, penaltyNone, penalty)
+	}
+	assert.Equal(t, 1000.0, s.list(now.Add(time.Hour))[0].Score, "decayed without a half-life")
+}
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sync/atomic"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	cfg "github.com/tendermint/tendermint/config"
 	"github.com/tendermint/tendermint/libs/clist"
 	"github.com/tendermint/tendermint/libs/log"
+	tmmath "github.com/tendermint/tendermint/libs/math"
 	tmsync "github.com/tendermint/tendermint/libs/sync"
 	"github.com/tendermint/tendermint/p2p"
 	protomem "github.com/tendermint/tendermint/proto/tendermint/mempool"
@@ -16,9 +19,20 @@ import (
 )
 
 const (
-	MempoolChannel = byte(0x30)
+	MempoolChannel          = byte(0x30)
+	MempoolStatusChannel    = byte(0x31)
+	MempoolInventoryChannel = byte(0x32)
 
 	peerCatchupSleepIntervalMS = 100 // If peer is behind, sleep this amount
+	statusIntervalMS           = 500 // How often the block height is checked for changes to announce
+
+	maxInventoryKeys = 1000            // Max tx keys announced or requested in one message
+	maxWantedTxs     = 100000          // Requests remembered before the timed out ones are dropped
+	wantTimeout      = 5 * time.Second // A tx is requested from another peer after this
+	maxAnnouncers    = 8               // Other announcers of a requested tx kept to request it from next
+
+	// peerStatusKey is the key of the *peerStatus of a peer.
+	peerStatusKey = "MempoolReactor.peerStatus"
 
 	// UnknownPeerID is the peer ID to use when running CheckTx when there is
 	// no peer (e.g. RPC)
@@ -35,6 +49,8 @@ type Reactor struct {
 	config  *cfg.MempoolConfig
 	mempool *CListMempool
 	ids     *mempoolIDs
+	wanted  *wantedTxs
+	scores  *peerScores
 }
 
 type mempoolIDs struct {
@@ -101,19 +117,25 @@ func newMempoolIDs() *mempoolIDs {
 }
 
 // NewReactor returns a new Reactor with the given config and mempool.
-func NewReactor(config *cfg.MempoolConfig, mempool *CListMempool) *Reactor {
+func NewReactor(config *cfg.MempoolConfig, mempool *CListMempool, options ...ReactorOption) *Reactor {
 	memR := &Reactor{
 		config:  config,
 		mempool: mempool,
 		ids:     newMempoolIDs(),
+		wanted:  newWantedTxs(),
+		scores:  newPeerScores(),
 	}
 	memR.BaseReactor = *p2p.NewBaseReactor("Mempool", memR)
+	for _, option := range options {
+		option(memR)
+	}
 	return memR
 }
 
 // InitPeer implements Reactor by creating a state for the peer.
 func (memR *Reactor) InitPeer(peer p2p.Peer) p2p.Peer {
 	memR.ids.ReserveForPeer(peer)
+	peer.Set(peerStatusKey, &peerStatus{height: -1})
 	return peer
 }
 
@@ -128,31 +150,58 @@ func (memR *Reactor) OnStart() error {
 	if !memR.config.Broadcast {
 		memR.Logger.Info("Tx broadcasting is disabled")
 	}
+	go memR.broadcastStatusRoutine()
+	go memR.rerequestRoutine()
 	return nil
 }
 
 // GetChannels implements Reactor by returning the list of channels for this
 // reactor.
 func (memR *Reactor) GetChannels() []*p2p.ChannelDescriptor {
-	largestTx := make([]byte, memR.config.MaxTxBytes)
-	batchMsg := protomem.Message{
-		Sum: &protomem.Message_Txs{
-			Txs: &protomem.Txs{Txs: [][]byte{largestTx}},
-		},
+	statusMsg := &StatusMessage{Height: math.MaxInt64}
+	inventoryMsg := &InventoryMessage{Have: make([][]byte, maxInventoryKeys)}
+	for i := range inventoryMsg.Have {
+		inventoryMsg.Have[i] = make([]byte, TxKeySize)
 	}
 
 	return []*p2p.ChannelDescriptor{
 		{
 			ID:                  MempoolChannel,
 			Priority:            5,
-			RecvMessageCapacity: batchMsg.Size(),
+			RecvMessageCapacity: memR.txsMessageCapacity(),
+		},
+		{
+			ID:                  MempoolInventoryChannel,
+			Priority:            5,
+			RecvMessageCapacity: proto.Size(inventoryMsg),
+		},
+		{
+			ID:                  MempoolStatusChannel,
+			Priority:            1,
+			RecvMessageCapacity: proto.Size(statusMsg),
 		},
 	}
 }
 
+// txsMessageCapacity is the size of a message with the largest tx allowed,
+// which batches of txs are limited to.
+func (memR *Reactor) txsMessageCapacity() int {
+	largestTx := make([]byte, memR.config.MaxTxBytes)
+	batchMsg := protomem.Message{
+		Sum: &protomem.Message_Txs{
+			Txs: &protomem.Txs{Txs: [][]byte{largestTx}},
+		},
+	}
+	return batchMsg.Size()
+}
+
 // AddPeer implements Reactor.
-// It starts a broadcast routine ensuring all txs are forwarded to the given peer.
+// It tells the peer our block height and starts a broadcast routine ensuring
+// all txs are forwarded to the given peer.
 func (memR *Reactor) AddPeer(peer p2p.Peer) {
+	if bz, err := memR.encodeStatus(); err == nil {
+		peer.Send(MempoolStatusChannel, bz)
+	}
 	if memR.config.Broadcast {
 		go memR.broadcastTxRoutine(peer)
 	}
@@ -165,8 +214,18 @@ func (memR *Reactor) RemovePeer(peer p2p.Peer, reason interface{}) {
 }
 
 // Receive implements Reactor.
-// It adds any received transactions to the mempool.
+// It adds any received transactions to the mempool and records the block
+// height of the peer.
 func (memR *Reactor) Receive(chID byte, src p2p.Peer, msgBytes []byte) {
+	switch chID {
+	case MempoolStatusChannel:
+		memR.receiveStatus(src, msgBytes)
+		return
+	case MempoolInventoryChannel:
+		memR.receiveInventory(src, msgBytes)
+		return
+	}
+
 	msg, err := memR.decodeMsg(msgBytes)
 	if err != nil {
 		memR.Logger.Error("Error decoding message", "src", src, "chId", chID, "err", err)
@@ -178,11 +237,26 @@ func (memR *Reactor) Receive(chID byte, src p2p.Peer, msgBytes []byte) {
 	txInfo := TxInfo{SenderID: memR.ids.GetForPeer(src)}
 	if src != nil {
 		txInfo.SenderP2PID = src.ID()
+		if memR.scores.check(src.ID(), time.Now()) != penaltyNone {
+			score, penalty := memR.scores.drop(src.ID(), len(msg.Txs), time.Now())
+			memR.punish(src, score, penalty)
+			return
+		}
 	}
 	for _, tx := range msg.Txs {
+		key := TxKey(tx)
+		memR.wanted.forget([][]byte{key[:]})
 		err = memR.mempool.CheckTx(tx, nil, txInfo)
-		if err != nil {
-			memR.Logger.Info("Could not check tx", "tx", txID(tx), "err", err)
+		if err == nil {
+			continue
+		}
+		memR.Logger.Info("Could not check tx", "tx", txID(tx), "err", err)
+		if src == nil {
+			continue
+		}
+		score, penalty := memR.scores.record(src.ID(), err, time.Now())
+		if memR.punish(src, score, penalty) || penalty == penaltyThrottle {
+			return
 		}
 	}
 	// broadcasting happens from go routines per peer
@@ -193,6 +267,58 @@ type PeerState interface {
 	GetHeight() int64
 }
 
+// peerStatus is the PeerState learnt from the status messages of a peer.
+type peerStatus struct {
+	height int64 // atomic, -1 until the peer told us
+}
+
+func (ps *peerStatus) GetHeight() int64 {
+	return atomic.LoadInt64(&ps.height)
+}
+
+func (memR *Reactor) receiveStatus(src p2p.Peer, msgBytes []byte) {
+	msg := &StatusMessage{}
+	err := proto.Unmarshal(msgBytes, msg)
+	if err == nil && msg.Height < 0 {
+		err = fmt.Errorf("negative height %d", msg.Height)
+	}
+	if err != nil {
+		memR.Logger.Error("Error decoding status message", "src", src, "err", err)
+		memR.Switch.StopPeerForError(src, fmt.Errorf("invalid status message: %w", err))
+		return
+	}
+	if ps, ok := src.Get(peerStatusKey).(*peerStatus); ok {
+		atomic.StoreInt64(&ps.height, msg.Height)
+	}
+}
+
+func (memR *Reactor) encodeStatus() ([]byte, error) {
+	return proto.Marshal(&StatusMessage{Height: memR.mempool.Height()})
+}
+
+// Tell all peers the block height whenever it changes.
+func (memR *Reactor) broadcastStatusRoutine() {
+	ticker := time.NewTicker(statusIntervalMS * time.Millisecond)
+	defer ticker.Stop()
+
+	announced := memR.mempool.Height()
+	for {
+		select {
+		case <-ticker.C:
+			if height := memR.mempool.Height(); height != announced {
+				bz, err := memR.encodeStatus()
+				if err != nil {
+					panic(err)
+				}
+				memR.Switch.Broadcast(MempoolStatusChannel, bz)
+				announced = height
+			}
+		case <-memR.Quit():
+			return
+		}
+	}
+}
+
 // Send new mempool txs to peer.
 func (memR *Reactor) broadcastTxRoutine(peer p2p.Peer) {
 	peerID := memR.ids.GetForPeer(peer)
@@ -220,38 +346,43 @@ func (memR *Reactor) broadcastTxRoutine(peer p2p.Peer) {
 		}
 
 		// Make sure the peer is up to date.
-		peerState, ok := peer.Get(types.PeerStateKey).(PeerState)
-		if !ok {
-			// Peer does not have a state yet. We set it in the consensus reactor, but
-			// when we add peer in Switch, the order we call reactors#AddPeer is
-			// different every time due to us using a map. Sometimes other reactors
-			// will be initialized before the consensus reactor. We should wait a few
-			// milliseconds and retry.
+		peerState, ok := peer.Get(peerStatusKey).(PeerState)
+		if !ok || peerState.GetHeight() < 0 {
+			// The peer hasn't told us its block height yet, it does so right
+			// after connecting. We should wait a few milliseconds and retry.
 			time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
 			continue
 		}
 
-		// Allow for a lag of 1 block.
-		memTx := next.Value.(*mempoolTx)
-		if peerState.GetHeight() < memTx.Height()-1 {
+		// Announce the keys of the txs following next the peer doesn't have
+		// yet, as long as the peer isn't lagging behind. Allow for a lag of
+		// 1 block.
+		var (
+			keys [][]byte
+			last *clist.CElement
+		)
+		for e := next; e != nil && len(keys) < maxInventoryKeys; e = e.Next() {
+			memTx := e.Value.(*mempoolTx)
+			if peerState.GetHeight() < memTx.Height()-1 {
+				break
+			}
+			if _, ok := memTx.senders.Load(peerID); !ok {
+				key := TxKey(memTx.tx)
+				keys = append(keys, key[:])
+			}
+			last = e
+		}
+		if last == nil {
 			time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
 			continue
 		}
 
-		// NOTE: Transaction batching was disabled due to
-		// https://github.com/tendermint/tendermint/issues/5796
-
-		if _, ok := memTx.senders.Load(peerID); !ok {
-			msg := protomem.Message{
-				Sum: &protomem.Message_Txs{
-					Txs: &protomem.Txs{Txs: [][]byte{memTx.tx}},
-				},
-			}
-			bz, err := msg.Marshal()
+		if len(keys) > 0 {
+			bz, err := proto.Marshal(&InventoryMessage{Have: keys})
 			if err != nil {
 				panic(err)
 			}
-			success := peer.Send(MempoolChannel, bz)
+			success := peer.Send(MempoolInventoryChannel, bz)
 			if !success {
 				time.Sleep(peerCatchupSleepIntervalMS * time.Millisecond)
 				continue
@@ -259,9 +390,9 @@ func (memR *Reactor) broadcastTxRoutine(peer p2p.Peer) {
 		}
 
 		select {
-		case <-next.NextWaitChan():
+		case <-last.NextWaitChan():
 			// see the start of the for loop for nil check
-			next = next.Next()
+			next = last.Next()
 		case <-peer.Quit():
 			return
 		case <-memR.Quit():
@@ -270,6 +401,223 @@ func (memR *Reactor) broadcastTxRoutine(peer p2p.Peer) {
 	}
 }
 
+// receiveInventory requests the announced txs we don't have and sends the
+// requested txs we have.
+func (memR *Reactor) receiveInventory(src p2p.Peer, msgBytes []byte) {
+	msg := &InventoryMessage{}
+	if err := proto.Unmarshal(msgBytes, msg); err != nil {
+		memR.Logger.Error("Error decoding inventory message", "src", src, "err", err)
+		memR.Switch.StopPeerForError(src, err)
+		return
+	}
+	if len(msg.Have) > maxInventoryKeys || len(msg.Want) > maxInventoryKeys {
+		memR.Switch.StopPeerForError(src, fmt.Errorf("inventory message has more than %d keys", maxInventoryKeys))
+		return
+	}
+	for _, keys := range [][][]byte{msg.Have, msg.Want} {
+		for _, key := range keys {
+			if len(key) != TxKeySize {
+				memR.Switch.StopPeerForError(src, fmt.Errorf("invalid tx key size %d", len(key)))
+				return
+			}
+		}
+	}
+
+	if len(msg.Have) > 0 && memR.scores.check(src.ID(), time.Now()) == penaltyNone {
+		memR.requestTxs(src, msg.Have)
+	}
+	if len(msg.Want) > 0 {
+		memR.sendTxs(src, msg.Want)
+	}
+}
+
+// requestTxs asks src for the txs we neither have nor asked another peer for.
+func (memR *Reactor) requestTxs(src p2p.Peer, have [][]byte) {
+	peerID := memR.ids.GetForPeer(src)
+	now := time.Now()
+
+	var want [][]byte
+	for _, k := range have {
+		var key [TxKeySize]byte
+		copy(key[:], k)
+		if e, ok := memR.mempool.txsMap.Load(key); ok {
+			// don't announce the tx back to src
+			e.(*clist.CElement).Value.(*mempoolTx).senders.LoadOrStore(peerID, true)
+			continue
+		}
+		if memR.mempool.cache.Has(key) || !memR.wanted.request(key, src, now) {
+			continue
+		}
+		want = append(want, k)
+	}
+	if len(want) == 0 {
+		return
+	}
+	if !memR.sendWant(src, want) {
+		memR.wanted.forget(want)
+	}
+}
+
+func (memR *Reactor) sendWant(dst p2p.Peer, want [][]byte) bool {
+	bz, err := proto.Marshal(&InventoryMessage{Want: want})
+	if err != nil {
+		panic(err)
+	}
+	return dst.Send(MempoolInventoryChannel, bz)
+}
+
+// rerequestTxs asks the next announcer for the txs whose request timed out.
+func (memR *Reactor) rerequestTxs(now time.Time) {
+	for peer, keys := range memR.wanted.rerequest(now) {
+		for len(keys) > 0 {
+			n := tmmath.MinInt(len(keys), maxInventoryKeys)
+			// a failed request is tried with the next announcer on timeout
+			memR.sendWant(peer, keys[:n])
+			keys = keys[n:]
+		}
+	}
+}
+
+// Request the txs whose request timed out from another peer.
+func (memR *Reactor) rerequestRoutine() {
+	ticker := time.NewTicker(wantTimeout / 5)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			memR.rerequestTxs(time.Now())
+		case <-memR.Quit():
+			return
+		}
+	}
+}
+
+// sendTxs sends the wanted txs still in the mempool to src, batched up to
+// the capacity of the mempool channel.
+func (memR *Reactor) sendTxs(src p2p.Peer, want [][]byte) {
+	peerID := memR.ids.GetForPeer(src)
+	capacity := memR.txsMessageCapacity()
+
+	var batch [][]byte
+	send := func() {
+		bz, err := (&protomem.Message{Sum: &protomem.Message_Txs{Txs: &protomem.Txs{Txs: batch}}}).Marshal()
+		if err != nil {
+			panic(err)
+		}
+		src.Send(MempoolChannel, bz)
+		batch = nil
+	}
+	for _, k := range want {
+		var key [TxKeySize]byte
+		copy(key[:], k)
+		e, ok := memR.mempool.txsMap.Load(key)
+		if !ok {
+			continue
+		}
+		memTx := e.(*clist.CElement).Value.(*mempoolTx)
+		memTx.senders.LoadOrStore(peerID, true)
+
+		next := append(batch, memTx.tx)
+		msg := protomem.Message{Sum: &protomem.Message_Txs{Txs: &protomem.Txs{Txs: next}}}
+		if len(batch) > 0 && msg.Size() > capacity {
+			send()
+			next = [][]byte{memTx.tx}
+		}
+		batch = next
+	}
+	if len(batch) > 0 {
+		send()
+	}
+}
+
+// wantedTxs remembers the txs requested from peers, so a tx announced by
+// several peers is only requested once. The other peers announcing it are
+// kept, and the tx is requested from the next one when the request times
+// out.
+type wantedTxs struct {
+	mtx  tmsync.Mutex
+	keys map[[TxKeySize]byte]*wantedTx
+}
+
+type wantedTx struct {
+	requested  time.Time
+	from       p2p.ID
+	announcers []p2p.Peer // to request the tx from next, in announcing order
+}
+
+func newWantedTxs() *wantedTxs {
+	return &wantedTxs{keys: make(map[[TxKeySize]byte]*wantedTx)}
+}
+
+// request returns true if key should be requested from src now, otherwise
+// src is kept to request key from if the pending request times out.
+func (w *wantedTxs) request(key [TxKeySize]byte, src p2p.Peer, now time.Time) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if wanted, ok := w.keys[key]; ok && now.Sub(wanted.requested) < wantTimeout {
+		if src != nil && src.ID() != wanted.from && len(wanted.announcers) < maxAnnouncers {
+			for _, announcer := range wanted.announcers {
+				if announcer.ID() == src.ID() {
+					return false
+				}
+			}
+			wanted.announcers = append(wanted.announcers, src)
+		}
+		return false
+	}
+	if len(w.keys) >= maxWantedTxs {
+		for k, wanted := range w.keys {
+			if now.Sub(wanted.requested) >= wantTimeout && len(wanted.announcers) == 0 {
+				delete(w.keys, k)
+			}
+		}
+	}
+	wanted := &wantedTx{requested: now}
+	if src != nil {
+		wanted.from = src.ID()
+	}
+	w.keys[key] = wanted
+	return true
+}
+
+// rerequest returns the keys whose request timed out by the next announcer
+// still connected to request them from, and records them as requested.
+func (w *wantedTxs) rerequest(now time.Time) map[p2p.Peer][][]byte {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	requests := make(map[p2p.Peer][][]byte)
+	for key, wanted := range w.keys {
+		if now.Sub(wanted.requested) < wantTimeout {
+			continue
+		}
+		for len(wanted.announcers) > 0 {
+			next := wanted.announcers[0]
+			wanted.announcers = wanted.announcers[1:]
+			if !next.IsRunning() {
+				continue
+			}
+			wanted.requested, wanted.from = now, next.ID()
+			key := key
+			requests[next] = append(requests[next], key[:])
+			break
+		}
+	}
+	return requests
+}
+
+func (w *wantedTxs) forget(keys [][]byte) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, k := range keys {
+		var key [TxKeySize]byte
+		copy(key[:], k)
+		delete(w.keys, key)
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Messages
 
@@ -304,6 +652,29 @@ func (memR *Reactor) decodeMsg(bz []byte) (TxsMessage, error) {
 
 //-------------------------------------
 
+// StatusMessage tells peers the latest Fabric block height a node has seen
+// on the MempoolStatusChannel whenever it changes.
+type StatusMessage struct {
+	Height int64 `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+}
+
+func (m *StatusMessage) Reset()         { *m = StatusMessage{} }
+func (m *StatusMessage) String() string { return proto.CompactTextString(m) }
+func (*StatusMessage) ProtoMessage()    {}
+
+// InventoryMessage announces the keys of txs a node has, or requests the
+// txs of the keys it wants, on the MempoolInventoryChannel.
+type InventoryMessage struct {
+	Have [][]byte `protobuf:"bytes,1,rep,name=have,proto3" json:"have,omitempty"`
+	Want [][]byte `protobuf:"bytes,2,rep,name=want,proto3" json:"want,omitempty"`
+}
+
+func (m *InventoryMessage) Reset()         { *m = InventoryMessage{} }
+func (m *InventoryMessage) String() string { return proto.CompactTextString(m) }
+func (*InventoryMessage) ProtoMessage()    {}
+
+//-------------------------------------
+
 // TxsMessage is a Message containing transactions.
 type TxsMessage struct {
 	Txs []types.Tx
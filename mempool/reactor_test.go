@@ -10,6 +10,7 @@ import (
 
 	"github.com/fortytw2/leaktest"
 	"github.com/go-kit/kit/log/term"
+	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -30,14 +31,6 @@ const (
 	timeout = 120 * time.Second // ridiculously high because CircleCI is slow
 )
 
-type peerState struct {
-	height int64
-}
-
-func (ps peerState) GetHeight() int64 {
-	return ps.height
-}
-
 // Send a bunch of txs to the first reactor's mempool and wait for them all to
 // be received in the others.
 func TestReactorBroadcastTxsMessage(t *testing.T) {
@@ -55,11 +48,6 @@ func TestReactorBroadcastTxsMessage(t *testing.T) {
 			}
 		}
 	}()
-	for _, r := range reactors {
-		for _, peer := range r.Switch.Peers().List() {
-			peer.Set(types.PeerStateKey, peerState{1})
-		}
-	}
 
 	txs := checkTxs(t, reactors[0].mempool, numTxs, UnknownPeerID)
 	waitForTxsOnReactors(t, txs, reactors)
@@ -77,11 +65,6 @@ func TestReactorConcurrency(t *testing.T) {
 			}
 		}
 	}()
-	for _, r := range reactors {
-		for _, peer := range r.Switch.Peers().List() {
-			peer.Set(types.PeerStateKey, peerState{1})
-		}
-	}
 	var wg sync.WaitGroup
 
 	const numTxs = 5
@@ -138,11 +121,6 @@ func TestReactorNoBroadcastToSender(t *testing.T) {
 			}
 		}
 	}()
-	for _, r := range reactors {
-		for _, peer := range r.Switch.Peers().List() {
-			peer.Set(types.PeerStateKey, peerState{1})
-		}
-	}
 
 	const peerID = 1
 	checkTxs(t, reactors[0].mempool, numTxs, peerID)
@@ -161,11 +139,6 @@ func TestReactor_MaxTxBytes(t *testing.T) {
 			}
 		}
 	}()
-	for _, r := range reactors {
-		for _, peer := range r.Switch.Peers().List() {
-			peer.Set(types.PeerStateKey, peerState{1})
-		}
-	}
 
 	// Broadcast a tx, which has the max size
 	// => ensure it's received by the second reactor.
@@ -184,6 +157,111 @@ func TestReactor_MaxTxBytes(t *testing.T) {
 	require.Error(t, err)
 }
 
+// Txs admitted at a block height are only sent to peers which have seen at
+// least the block before, as told by their status messages.
+func TestReactorWaitsForLaggingPeer(t *testing.T) {
+	config := cfg.TestConfig()
+	const N = 2
+	reactors := makeAndConnectReactors(config, N)
+	defer func() {
+		for _, r := range reactors {
+			if err := r.Stop(); err != nil {
+				assert.NoError(t, err)
+			}
+		}
+	}()
+
+	reactors[0].mempool.Lock()
+	require.NoError(t, reactors[0].mempool.Update(5, nil, nil, nil, nil))
+	reactors[0].mempool.Unlock()
+	txs := checkTxs(t, reactors[0].mempool, 10, UnknownPeerID)
+	ensureNoTxs(t, reactors[1], time.Second)
+
+	reactors[1].mempool.Lock()
+	require.NoError(t, reactors[1].mempool.Update(4, nil, nil, nil, nil))
+	reactors[1].mempool.Unlock()
+	waitForTxsOnReactors(t, txs, reactors)
+}
+
+// A tx whose request is dropped by the peer which announced it first is
+// requested from the next peer which announced it.
+func TestReactorRequestsTxFromNextAnnouncer(t *testing.T) {
+	config := cfg.TestConfig()
+	app := kvstore.NewApplication()
+	cc := proxy.NewLocalClientCreator(app)
+	mempool, cleanup := newMempoolWithApp(cc)
+	defer cleanup()
+	reactor := NewReactor(config.Mempool, mempool)
+	reactor.SetLogger(log.TestingLogger())
+
+	first, next := newInventoryPeer(), newInventoryPeer()
+	key := TxKey([]byte("tx"))
+	have, err := proto.Marshal(&InventoryMessage{Have: [][]byte{key[:]}})
+	require.NoError(t, err)
+
+	reactor.Receive(MempoolInventoryChannel, first, have)
+	reactor.Receive(MempoolInventoryChannel, next, have)
+	assert.Equal(t, [][][]byte{{key[:]}}, first.wants())
+	assert.Empty(t, next.wants(), "requested twice")
+
+	// first drops the request
+	reactor.rerequestTxs(time.Now())
+	assert.Empty(t, next.wants(), "requested again before the timeout")
+	reactor.rerequestTxs(time.Now().Add(wantTimeout))
+	assert.Equal(t, [][][]byte{{key[:]}}, next.wants())
+	assert.Len(t, first.wants(), 1)
+
+	// no announcer left
+	reactor.rerequestTxs(time.Now().Add(2 * wantTimeout))
+	assert.Len(t, first.wants(), 1)
+	assert.Len(t, next.wants(), 1)
+}
+
+// inventoryPeer records the keys requested from it, never sending them.
+type inventoryPeer struct {
+	*mock.Peer
+	mtx  sync.Mutex
+	want [][][]byte
+}
+
+func newInventoryPeer() *inventoryPeer {
+	return &inventoryPeer{Peer: mock.NewPeer(nil)}
+}
+
+func (p *inventoryPeer) Send(chID byte, msgBytes []byte) bool {
+	msg := &InventoryMessage{}
+	if chID == MempoolInventoryChannel && proto.Unmarshal(msgBytes, msg) == nil && len(msg.Want) > 0 {
+		p.mtx.Lock()
+		p.want = append(p.want, msg.Want)
+		p.mtx.Unlock()
+	}
+	return true
+}
+
+func (p *inventoryPeer) wants() [][][]byte {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.want
+}
+
+func TestWantedTxs(t *testing.T) {
+	w := newWantedTxs()
+	key := TxKey([]byte("tx"))
+	now := time.Now()
+	first, next := mock.NewPeer(nil), mock.NewPeer(nil)
+
+	assert.True(t, w.request(key, first, now))
+	assert.False(t, w.request(key, next, now.Add(time.Second)), "requested twice")
+	assert.False(t, w.request(key, next, now.Add(time.Second)), "requested twice")
+	assert.Empty(t, w.rerequest(now.Add(time.Second)))
+	assert.Equal(t, map[p2p.Peer][][]byte{next: {key[:]}}, w.rerequest(now.Add(wantTimeout)), "next announcer asked once")
+	assert.Empty(t, w.rerequest(now.Add(2*wantTimeout)))
+	assert.True(t, w.request(key, first, now.Add(2*wantTimeout)), "not requested again after the timeout")
+
+	w.forget([][]byte{key[:]})
+	assert.True(t, w.request(key, first, now.Add(2*wantTimeout)), "not requested again once forgotten")
+}
+
 func TestBroadcastTxForPeerStopsWhenPeerStops(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
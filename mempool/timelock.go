@@ -0,0 +1,140 @@
+package mempool
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/tylerztl/fabric-mempool/protoutil"
+)
+
+// timeLock holds a tx back from reaping until both conditions are met.
+type timeLock struct {
+	notBefore       time.Time // zero means no time condition
+	notBeforeHeight int64     // zero means no height condition
+}
+
+func (l timeLock) due(now time.Time, height int64) bool {
+	return !now.Before(l.notBefore) && height >= l.notBeforeHeight
+}
+
+// lockedTx is a tx admitted to the mempool but not reapable yet.
+type lockedTx struct {
+	memTx *mempoolTx
+	lock  timeLock
+	seq   int64 // admission order, kept when promoted together
+}
+
+// txLock returns the time lock of tx, taken from txInfo and, if enabled by
+// WithTimestampLock, from the timestamp of its channel header.
+func (mem *CListMempool) txLock(tx []byte, txInfo TxInfo) (timeLock, error) {
+	lock := timeLock{notBefore: txInfo.NotBefore, notBeforeHeight: txInfo.NotBeforeHeight}
+	if mem.timestampLock {
+		if env, err := protoutil.UnmarshalEnvelope(tx); err == nil {
+			if chdr, err := protoutil.ChannelHeader(env); err == nil && chdr.Timestamp != nil {
+				if ts, err := ptypes.Timestamp(chdr.Timestamp); err == nil && ts.After(lock.notBefore) {
+					lock.notBefore = ts
+				}
+			}
+		}
+	}
+
+	if mem.maxTimeLock > 0 && lock.notBefore.After(txInfo.At().Add(mem.maxTimeLock)) {
+		return lock, ErrTimeLockTooFar{NotBefore: lock.notBefore, Max: mem.maxTimeLock}
+	}
+	return lock, nil
+}
+
+// holdTx adds memTx to the queue of txs waiting for their lock to be due.
+func (mem *CListMempool) holdTx(memTx *mempoolTx, lock timeLock) {
+	mem.lockedMtx.Lock()
+	defer mem.lockedMtx.Unlock()
+
+	mem.lockedSeq++
+	mem.locked[TxKey(memTx.tx)] = &lockedTx{memTx: memTx, lock: lock, seq: mem.lockedSeq}
+	mem.txIDs.Store(memTx.txID, TxKey(memTx.tx))
+	atomic.AddInt64(&mem.lockedBytes, int64(len(memTx.tx)))
+}
+
+// removeLockedTx removes a tx from the time-locked queue, it returns false if
+// the tx isn't waiting there.
+func (mem *CListMempool) removeLockedTx(txKey [TxKeySize]byte, removeFromCache bool) bool {
+	mem.lockedMtx.Lock()
+	defer mem.lockedMtx.Unlock()
+
+	locked, ok := mem.locked[txKey]
+	if !ok {
+		return false
+	}
+	delete(mem.locked, txKey)
+	mem.forgetTxID(locked.memTx)
+	atomic.AddInt64(&mem.lockedBytes, int64(-len(locked.memTx.tx)))
+	if removeFromCache {
+		mem.cache.Remove(locked.memTx.tx)
+	}
+	return true
+}
+
+// PromoteDueTxs moves the time-locked txs which became due to the reapable
+// txs, ordered by when they became due and then by admission. Reaping and
+// Update promote due txs themselves.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) PromoteDueTxs() {
+	mem.promoteDueTxs(time.Now())
+}
+
+// promoteDueTxs promotes the time-locked txs due by now.
+func (mem *CListMempool) promoteDueTxs(now time.Time) {
+	mem.lockedMtx.Lock()
+	defer mem.lockedMtx.Unlock()
+
+	if len(mem.locked) == 0 {
+		return
+	}
+	var (
+		height = atomic.LoadInt64(&mem.height)
+		due    []*lockedTx
+	)
+	for key, locked := range mem.locked {
+		if locked.lock.due(now, height) {
+			due = append(due, locked)
+			delete(mem.locked, key)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].lock.notBefore.Equal(due[j].lock.notBefore) {
+			return due[i].lock.notBefore.Before(due[j].lock.notBefore)
+		}
+		return due[i].seq < due[j].seq
+	})
+
+	for _, locked := range due {
+		atomic.AddInt64(&mem.lockedBytes, int64(-len(locked.memTx.tx)))
+		mem.addTx(locked.memTx)
+	}
+	mem.logger.Info("Promoted time-locked transactions", "txs", len(due), "poolSize", mem.Size())
+	mem.notifyTxsAvailable()
+	mem.metrics.Size.Set(float64(mem.Size()))
+}
+
+// LockedSize returns the number of txs waiting for their time lock, they
+// aren't counted by Size.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) LockedSize() int {
+	mem.lockedMtx.Lock()
+	defer mem.lockedMtx.Unlock()
+	return len(mem.locked)
+}
+
+// LockedTxsBytes returns the total size of the txs waiting for their time lock.
+//
+// Safe for concurrent use by multiple goroutines.
+func (mem *CListMempool) LockedTxsBytes() int64 {
+	return atomic.LoadInt64(&mem.lockedBytes)
+}
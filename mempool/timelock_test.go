@@ -0,0 +1,89 @@
+package mempool
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	cb "github.com/hyperledger/fabric/protos/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	cfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+func newTimeLockMempool(t *testing.T, options ...CListMempoolOption) (*CListMempool, cleanupFunc) {
+	config := cfg.ResetTestRoot("mempool_test")
+	config.Mempool.Size = 3
+	mempool := NewCListMempool(config.Mempool, 0, options...)
+	mempool.SetLogger(log.TestingLogger())
+	return mempool, func() { os.RemoveAll(config.RootDir) }
+}
+
+func TestTimeLockedTxs(t *testing.T) {
+	mempool, cleanup := newTimeLockMempool(t)
+	defer cleanup()
+
+	byTime := newFeeTx(t, "byTime", 10)
+	byHeight := newFeeTx(t, "byHeight", 10)
+	require.NoError(t, mempool.CheckTx(byTime, nil, TxInfo{NotBefore: time.Now().Add(50 * time.Millisecond)}))
+	require.NoError(t, mempool.CheckTx(byHeight, nil, TxInfo{NotBeforeHeight: 2}))
+	require.NoError(t, mempool.CheckTx(newFeeTx(t, "now", 10), nil, TxInfo{}))
+
+	assert.Equal(t, 1, mempool.Size())
+	assert.Equal(t, 2, mempool.LockedSize())
+	assert.Equal(t, types.Txs{newFeeTx(t, "now", 10)}, mempool.ReapMaxTxsBySort(-1))
+
+	// time-locked txs take room
+	assert.IsType(t, ErrMempoolIsFull{}, mempool.CheckTx(newFeeTx(t, "full", 10), nil, TxInfo{}))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, types.Txs{newFeeTx(t, "now", 10), byTime}, mempool.ReapMaxBytesMaxGas(-1, -1))
+
+	mempool.Lock()
+	require.NoError(t, mempool.Update(2, types.Txs{newFeeTx(t, "now", 10)}, nil, nil, nil))
+	mempool.Unlock()
+	assert.Equal(t, 0, mempool.LockedSize())
+	assert.Equal(t, types.Txs{byTime, byHeight}, mempool.ReapMaxTxs(-1))
+}
+
+func TestRemoveTimeLockedTx(t *testing.T) {
+	mempool, cleanup := newTimeLockMempool(t)
+	defer cleanup()
+
+	tx := newFeeTx(t, "locked", 10)
+	require.NoError(t, mempool.CheckTx(tx, nil, TxInfo{NotBeforeHeight: 5}))
+	mempool.RemoveTxByKey(TxKey(tx), true)
+	assert.Equal(t, 0, mempool.LockedSize())
+	assert.EqualValues(t, 0, mempool.LockedTxsBytes())
+	require.NoError(t, mempool.CheckTx(tx, nil, TxInfo{NotBeforeHeight: 5}))
+}
+
+func TestTimestampLock(t *testing.T) {
+	mempool, cleanup := newTimeLockMempool(t, WithTimestampLock(), WithMaxTimeLock(time.Hour))
+	defer cleanup()
+
+	newTimestampTx := func(txId string, ts time.Time) types.Tx {
+		timestamp, err := ptypes.TimestampProto(ts)
+		require.NoError(t, err)
+		chdr, err := proto.Marshal(&cb.ChannelHeader{TxId: txId, Timestamp: timestamp})
+		require.NoError(t, err)
+		payload, err := proto.Marshal(&cb.Payload{Header: &cb.Header{ChannelHeader: chdr}})
+		require.NoError(t, err)
+		env, err := proto.Marshal(&cb.Envelope{Payload: payload})
+		require.NoError(t, err)
+		return env
+	}
+
+	require.NoError(t, mempool.CheckTx(newTimestampTx("past", time.Now().Add(-time.Minute)), nil, TxInfo{}))
+	require.NoError(t, mempool.CheckTx(newTimestampTx("future", time.Now().Add(time.Minute)), nil, TxInfo{}))
+	assert.Equal(t, 1, mempool.Size())
+	assert.Equal(t, 1, mempool.LockedSize())
+
+	err := mempool.CheckTx(newTimestampTx("far", time.Now().Add(2*time.Hour)), nil, TxInfo{})
+	assert.IsType(t, ErrTimeLockTooFar{}, err)
+}
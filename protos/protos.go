@@ -0,0 +1,6 @@
+// Package protos holds the messages and the gRPC services of the mempool,
+// generated from the .proto files of this directory by protoc-gen-go v1.3.5
+// with the grpc plugin. The .proto files import the protos of the Fabric fork.
+package protos
+
+//go:generate sh -c "protoc -I . -I $(go list -m -f '{{.Dir}}' github.com/hyperledger/fabric)/protos --go_out=plugins=grpc,paths=source_relative:. *.proto"
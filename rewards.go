@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tylerztl/fabric-mempool/conf"
+	"github.com/tylerztl/fabric-mempool/handler"
+)
+
+var rewardsCmd = &cobra.Command{
+	Use:   "rewards",
+	Short: "Query the fee rewards of the orderers",
+}
+
+var rewardsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the fee credits, by orderer and time range (RFC3339)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := url.Values{}
+		if RewardsOrderer != "" {
+			query.Set("orderer", RewardsOrderer)
+		}
+		if RewardsFrom != "" {
+			query.Set("from", RewardsFrom)
+		}
+		if RewardsTo != "" {
+			query.Set("to", RewardsTo)
+		}
+		if RewardsLimit > 0 {
+			query.Set("limit", strconv.Itoa(RewardsLimit))
+		}
+		req, err := http.NewRequest(http.MethodGet, RewardsServer+"/rewards?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		return rewardsRequest(req)
+	},
+}
+
+var rewardsEpochsCmd = &cobra.Command{
+	Use:   "epochs",
+	Short: "List the reward epochs with the totals of the orderers",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req, err := http.NewRequest(http.MethodGet, RewardsServer+"/rewards/epochs", nil)
+		if err != nil {
+			return err
+		}
+		return rewardsRequest(req)
+	},
+}
+
+var rewardsRolloverCmd = &cobra.Command{
+	Use:   "rollover",
+	Short: "End the current reward epoch, signed by an admin",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if RewardsAdminKey == "" || RewardsAdminCert == "" {
+			return errors.New("the key and the cert of the admin are required")
+		}
+		admin, err := handler.LoadCrypto(&conf.UserInfo{MSPID: RewardsAdminMSPID, PrivateKey: RewardsAdminKey, SignCert: RewardsAdminCert})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, RewardsServer+"/rewards/epochs", nil)
+		if err != nil {
+			return err
+		}
+		if err := handler.SignAdminHTTPRequest(req, admin, handler.AdminRolloverRewardEpoch, nil); err != nil {
+			return err
+		}
+		return rewardsRequest(req)
+	},
+}
+
+// rewardsRequest sends req to the rest server of the mempool and prints the
+// data of its response.
+func rewardsRequest(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return errors.Errorf("unexpected response %s: %s", resp.Status, body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("request failed, %s: %s", resp.Status, result.Msg)
+	}
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, result.Data, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(out.String())
+	return nil
+}